@@ -0,0 +1,46 @@
+// Package backup writes and restores portable archives of the hub: the metadata
+// collections as JSON lines plus the GridFS files, with a checksum per entry.
+//
+// An archive is a gzip-compressed tar file. Each collection is stored as
+// "<collection>.jsonl" holding one document per line in canonical Extended JSON,
+// so ObjectIDs and dates survive the round trip. The file documents of each
+// bucket are stored the same way as "<bucket>.files.jsonl" and the content of
+// each file as "files/<bucket>/<file ID>". The last entry, "manifest.json",
+// lists the SHA-256 of every other entry.
+package backup
+
+import (
+	"time"
+)
+
+// Format identifies hub archives in their manifest
+const Format = "hub-export"
+
+// Version is the archive layout version written by Export
+const Version = 1
+
+// Collections are the exported collections, in restore order
+var Collections = []string{"users", "channels", "categories", "videos", "playlists", "comments"}
+
+// Buckets are the exported GridFS buckets. Renditions are left out; they are
+// derived from the source files and can be packaged again.
+var Buckets = []string{"video", "images"}
+
+// manifestName is the name of the manifest entry
+const manifestName = "manifest.json"
+
+// Manifest describes an archive
+type Manifest struct {
+	Format    string           `json:"format"`
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Counts    map[string]int64 `json:"counts"`  // Documents per collection and files per bucket
+	Entries   []ManifestEntry  `json:"entries"` // Every entry before the manifest
+}
+
+// ManifestEntry is the checksum of one archive entry
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exporter writes the entries of an archive and records their checksums
+type exporter struct {
+	tw       *tar.Writer
+	manifest Manifest
+}
+
+// Export writes an archive of the database to w. Collections are read one after
+// the other, so changes made while the export runs may be partly included.
+func Export(ctx context.Context, db *mongo.Database, w io.Writer) (Manifest, error) {
+	gz := gzip.NewWriter(w)
+	e := &exporter{
+		tw: tar.NewWriter(gz),
+		manifest: Manifest{
+			Format:    Format,
+			Version:   Version,
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+			Counts:    map[string]int64{},
+		},
+	}
+
+	for _, name := range Collections {
+		count, _, err := e.addCollection(ctx, name+".jsonl", db.Collection(name))
+		if err != nil {
+			return e.manifest, fmt.Errorf("export %s: %w", name, err)
+		}
+		e.manifest.Counts[name] = count
+	}
+	for _, name := range Buckets {
+		if err := e.addBucket(ctx, db, name); err != nil {
+			return e.manifest, fmt.Errorf("export bucket %s: %w", name, err)
+		}
+	}
+
+	// The manifest comes last so it can hold the checksums of everything before it
+	data, err := json.MarshalIndent(e.manifest, "", "  ")
+	if err != nil {
+		return e.manifest, err
+	}
+	header := &tar.Header{Name: manifestName, Mode: 0o644, Size: int64(len(data)), ModTime: e.manifest.CreatedAt}
+	if err := e.tw.WriteHeader(header); err != nil {
+		return e.manifest, err
+	}
+	if _, err := e.tw.Write(data); err != nil {
+		return e.manifest, err
+	}
+	if err := e.tw.Close(); err != nil {
+		return e.manifest, err
+	}
+	return e.manifest, gz.Close()
+}
+
+// addCollection writes every document of a collection as one line of Extended JSON.
+// The lines are spooled to a temporary file because tar needs the size up front.
+// It returns the number of documents and their ObjectIDs.
+func (e *exporter) addCollection(ctx context.Context, name string, collection *mongo.Collection) (int64, []primitive.ObjectID, error) {
+	spool, err := os.CreateTemp("", "hub-export-*.jsonl")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	var ids []primitive.ObjectID
+	for cursor.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return count, ids, err
+		}
+		if _, err := spool.Write(append(line, '\n')); err != nil {
+			return count, ids, err
+		}
+		if id, ok := cursor.Current.Lookup("_id").ObjectIDOK(); ok {
+			ids = append(ids, id)
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, ids, err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return count, ids, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return count, ids, err
+	}
+	return count, ids, e.addEntry(name, size, spool)
+}
+
+// addBucket writes the file documents of a GridFS bucket followed by the content of each file
+func (e *exporter) addBucket(ctx context.Context, db *mongo.Database, name string) error {
+	count, ids, err := e.addCollection(ctx, name+".files.jsonl", db.Collection(name+".files"))
+	if err != nil {
+		return err
+	}
+	e.manifest.Counts[name+".files"] = count
+
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(name))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := e.addFile(bucket, name, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFile writes the content of one GridFS file
+func (e *exporter) addFile(bucket *gridfs.Bucket, name string, id primitive.ObjectID) error {
+	stream, err := bucket.OpenDownloadStream(id)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", id.Hex(), err)
+	}
+	defer stream.Close()
+	return e.addEntry("files/"+name+"/"+id.Hex(), stream.GetFile().Length, stream)
+}
+
+// addEntry writes one entry and records its checksum in the manifest
+func (e *exporter) addEntry(name string, size int64, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: e.manifest.CreatedAt}
+	if err := e.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(e.tw, hash), r)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if written != size {
+		return fmt.Errorf("write %s: read %d of %d bytes", name, written, size)
+	}
+	e.manifest.Entries = append(e.manifest.Entries, ManifestEntry{Name: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
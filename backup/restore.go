@@ -0,0 +1,337 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// restoreBatch is the number of documents inserted at once
+const restoreBatch = 500
+
+// maxLine bounds one Extended JSON document; MongoDB documents are at most 16 MB
+const maxLine = 64 << 20
+
+// idMap maps the IDs of the archive to the IDs given to the restored documents
+type idMap map[primitive.ObjectID]primitive.ObjectID
+
+// Restore loads an archive into an empty database. Every document and file gets a
+// new ID and the references between them are rewritten, including user IDs stored
+// as hex strings. The archive is read twice, first to verify every checksum and
+// then to load it, so nothing is written from a damaged archive.
+func Restore(ctx context.Context, db *mongo.Database, archive io.ReadSeeker) (Manifest, error) {
+	if err := ensureEmpty(ctx, db); err != nil {
+		return Manifest{}, err
+	}
+
+	ids := idMap{}
+	manifest, err := verify(archive, ids)
+	if err != nil {
+		return manifest, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return manifest, err
+	}
+	return manifest, load(ctx, db, archive, ids)
+}
+
+// ensureEmpty refuses to restore over existing data
+func ensureEmpty(ctx context.Context, db *mongo.Database) error {
+	names := append([]string{}, Collections...)
+	for _, bucket := range Buckets {
+		names = append(names, bucket+".files")
+	}
+	for _, name := range names {
+		count, err := db.Collection(name).CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("database is not empty: %s has documents", name)
+		}
+	}
+	return nil
+}
+
+// openArchive returns a tar reader over the decompressed archive
+func openArchive(archive io.Reader) (*tar.Reader, *gzip.Reader, error) {
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a hub archive: %w", err)
+	}
+	return tar.NewReader(gz), gz, nil
+}
+
+// verify checks every entry against the manifest and assigns a new ID to every
+// document and file of the archive
+func verify(archive io.Reader, ids idMap) (Manifest, error) {
+	var manifest Manifest
+	tr, gz, err := openArchive(archive)
+	if err != nil {
+		return manifest, err
+	}
+	defer gz.Close()
+
+	sums := map[string]ManifestEntry{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("read archive: %w", err)
+		}
+		if header.Name == manifestName {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, fmt.Errorf("read manifest: %w", err)
+			}
+			continue
+		}
+
+		hash := sha256.New()
+		var content io.Reader = io.TeeReader(tr, hash)
+		if strings.HasSuffix(header.Name, ".jsonl") {
+			err = eachDocument(content, func(doc bson.D) error {
+				if id, ok := documentID(doc); ok {
+					ids[id] = primitive.NewObjectID()
+				}
+				return nil
+			})
+		} else {
+			_, err = io.Copy(io.Discard, content)
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+		sums[header.Name] = ManifestEntry{Name: header.Name, Size: header.Size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	}
+
+	if manifest.Format != Format {
+		return manifest, errors.New("not a hub archive: manifest missing")
+	}
+	if manifest.Version > Version {
+		return manifest, fmt.Errorf("archive version %d is newer than this hub supports", manifest.Version)
+	}
+	if len(sums) != len(manifest.Entries) {
+		return manifest, fmt.Errorf("archive has %d entries, manifest lists %d", len(sums), len(manifest.Entries))
+	}
+	for _, expected := range manifest.Entries {
+		if actual, ok := sums[expected.Name]; !ok || actual != expected {
+			return manifest, fmt.Errorf("checksum mismatch for %s", expected.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// load inserts the documents and files of a verified archive
+func load(ctx context.Context, db *mongo.Database, archive io.Reader, ids idMap) error {
+	tr, gz, err := openArchive(archive)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	// File documents are held until their content is read further on
+	fileDocs := map[string]map[primitive.ObjectID]bson.D{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+
+		name := header.Name
+		switch {
+		case name == manifestName:
+			continue
+		case strings.HasSuffix(name, ".files.jsonl"):
+			bucket := strings.TrimSuffix(name, ".files.jsonl")
+			if !contains(Buckets, bucket) {
+				return fmt.Errorf("restore %s: unknown bucket", name)
+			}
+			docs := map[primitive.ObjectID]bson.D{}
+			err = eachDocument(tr, func(doc bson.D) error {
+				if id, ok := documentID(doc); ok {
+					docs[id] = doc
+				}
+				return nil
+			})
+			fileDocs[bucket] = docs
+		case strings.HasPrefix(name, "files/"):
+			bucket, hexID, _ := strings.Cut(strings.TrimPrefix(name, "files/"), "/")
+			err = loadFile(ctx, db, bucket, hexID, tr, fileDocs[bucket], ids)
+		case strings.HasSuffix(name, ".jsonl") && contains(Collections, strings.TrimSuffix(name, ".jsonl")):
+			err = loadCollection(ctx, db.Collection(strings.TrimSuffix(name, ".jsonl")), tr, ids)
+		default:
+			err = errors.New("unknown entry")
+		}
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", name, err)
+		}
+	}
+}
+
+// loadCollection inserts the documents of one collection with their IDs remapped
+func loadCollection(ctx context.Context, collection *mongo.Collection, r io.Reader, ids idMap) error {
+	var batch []interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := collection.InsertMany(ctx, batch)
+		batch = batch[:0]
+		return err
+	}
+	err := eachDocument(r, func(doc bson.D) error {
+		doc = ids.rewrite(doc).(bson.D)
+		// Renditions are not archived; the restored videos can be packaged again
+		if collection.Name() == "videos" {
+			doc = withoutField(doc, "renditions")
+		}
+		batch = append(batch, doc)
+		if len(batch) == restoreBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// loadFile stores the content of one GridFS file under its new ID with its
+// original name, metadata and upload date
+func loadFile(ctx context.Context, db *mongo.Database, bucketName, hexID string, r io.Reader, docs map[primitive.ObjectID]bson.D, ids idMap) error {
+	oldID, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return err
+	}
+	doc, ok := docs[oldID]
+	if !ok {
+		return errors.New("file document missing")
+	}
+	var file struct {
+		Filename   string    `bson:"filename"`
+		UploadDate time.Time `bson:"uploadDate"`
+		Metadata   bson.D    `bson:"metadata"`
+	}
+	raw, err := bson.Marshal(ids.rewrite(doc))
+	if err != nil {
+		return err
+	}
+	if err := bson.Unmarshal(raw, &file); err != nil {
+		return err
+	}
+
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return err
+	}
+	newID := ids[oldID]
+	uploadOpts := options.GridFSUpload()
+	if file.Metadata != nil {
+		uploadOpts.SetMetadata(file.Metadata)
+	}
+	if err := bucket.UploadFromStreamWithID(newID, file.Filename, r, uploadOpts); err != nil {
+		return err
+	}
+	_, err = db.Collection(bucketName+".files").UpdateOne(ctx, bson.M{"_id": newID}, bson.M{"$set": bson.M{"uploadDate": file.UploadDate}})
+	return err
+}
+
+// eachDocument decodes the Extended JSON lines of an entry
+func eachDocument(r io.Reader, fn func(doc bson.D) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// contains reports whether name is one of names
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// documentID returns the ObjectID of a document
+func documentID(doc bson.D) (primitive.ObjectID, bool) {
+	for _, field := range doc {
+		if field.Key == "_id" {
+			id, ok := field.Value.(primitive.ObjectID)
+			return id, ok
+		}
+	}
+	return primitive.NilObjectID, false
+}
+
+// withoutField returns the document without the named top-level field
+func withoutField(doc bson.D, key string) bson.D {
+	kept := doc[:0]
+	for _, field := range doc {
+		if field.Key != key {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// rewrite replaces every archived ObjectID in a value with its new ID. Users are
+// referenced by the hex form of their ID, so matching hex strings are replaced too.
+func (ids idMap) rewrite(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		if id, ok := ids[v]; ok {
+			return id
+		}
+	case string:
+		if len(v) == 24 {
+			if old, err := primitive.ObjectIDFromHex(v); err == nil {
+				if id, ok := ids[old]; ok {
+					return id.Hex()
+				}
+			}
+		}
+	case bson.D:
+		for i := range v {
+			v[i].Value = ids.rewrite(v[i].Value)
+		}
+	case bson.A:
+		for i := range v {
+			v[i] = ids.rewrite(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"hub/backup"
+	"hub/config"
+)
+
+// Export writes an archive of the database to a file or standard output
+func Export(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "hub-export-"+time.Now().Format("20060102-150405")+".tar.gz", `archive to write, "-" for standard output`)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: hub export [-o archive.tar.gz]")
+		fmt.Fprintln(os.Stderr, "\nWrites users, channels, categories, videos, playlists, comments and their media files to a portable archive.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Printf("Failed to create %s: %v", *output, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	manifest, err := backup.Export(context.Background(), config.DB, w)
+	if err != nil {
+		log.Printf("Export failed: %v", err)
+		if *output != "-" {
+			os.Remove(*output)
+		}
+		return 1
+	}
+	log.Printf("Exported %d entries to %s: %v", len(manifest.Entries), *output, manifest.Counts)
+	return 0
+}
+
+// Restore loads an archive written by export into an empty database
+func Restore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: hub restore <archive.tar.gz>")
+		fmt.Fprintln(os.Stderr, "\nVerifies the checksums of an archive and loads it into an empty database.")
+		fmt.Fprintln(os.Stderr, "Every document and file gets a new ID; references between them are rewritten.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Printf("Failed to open %s: %v", flags.Arg(0), err)
+		return 1
+	}
+	defer file.Close()
+
+	manifest, err := backup.Restore(context.Background(), config.DB, file)
+	if err != nil {
+		log.Printf("Restore failed: %v", err)
+		return 1
+	}
+	log.Printf("Restored the archive of %s: %v", manifest.CreatedAt.Format(time.RFC3339), manifest.Counts)
+	if config.SearchBackend == config.SearchBackendMemory {
+		log.Println("Restart running servers to add the restored videos to their search index")
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+// Package cli implements the hub subcommands that run against the database
+// instead of starting the server.
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands maps subcommand names to their entry points. Each returns the exit status.
+var commands = map[string]func(args []string) int{
+	"import":  Import,
+	"export":  Export,
+	"restore": Restore,
+}
+
+// Run runs the subcommand named by args[0] and returns the process exit status
+func Run(args []string) int {
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		return 2
+	}
+	return command(args[1:])
+}
+
+// usage lists the subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: hub [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nWithout a command hub starts the server. Commands:")
+	fmt.Fprintln(os.Stderr, "  import   upload a directory or manifest of media files")
+	fmt.Fprintln(os.Stderr, "  export   write users, metadata and media to a portable archive")
+	fmt.Fprintln(os.Stderr, "  restore  load an archive into an empty database")
+}
@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/controller"
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// videoExtensions are the video files picked up when importing a directory;
+// audio files are recognised by models.AudioContentType
+var videoExtensions = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".mkv":  true,
+	".webm": true,
+	".avi":  true,
+}
+
+// Import uploads the media files of a directory or manifest as videos of one owner.
+// Progress is appended to a journal so an interrupted import resumes where it stopped.
+func Import(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	owner := flags.String("owner", "", "username of the user who will own the videos (required)")
+	workers := flags.Int("workers", 4, "number of files uploaded concurrently")
+	journalPath := flags.String("journal", "hub-import.journal", "progress journal; finished files listed in it are not imported again")
+	visibility := flags.String("visibility", models.VisibilityPublic, "visibility of videos whose manifest entry sets none")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: hub import [flags] <directory | manifest.csv | manifest.json>")
+		fmt.Fprintln(os.Stderr, "\nA CSV manifest has a header row naming its columns: path (required), title, description,")
+		fmt.Fprintln(os.Stderr, "tags (comma-separated) and visibility. A JSON manifest is an array of objects with the same")
+		fmt.Fprintln(os.Stderr, "fields, tags as an array. Relative paths are resolved against the manifest's directory.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *owner == "" || *workers < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(ctx, bson.M{"username": *owner}).Decode(&user); err != nil {
+		log.Printf("Owner %q not found: %v", *owner, err)
+		return 1
+	}
+
+	entries, err := readImportSource(flags.Arg(0))
+	if err != nil {
+		log.Printf("Failed to read %s: %v", flags.Arg(0), err)
+		return 1
+	}
+	journal, err := openJournal(*journalPath)
+	if err != nil {
+		log.Printf("Failed to open journal %s: %v", *journalPath, err)
+		return 1
+	}
+	defer journal.Close()
+
+	pending := make(chan models.ImportEntry)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range pending {
+				if entry.Visibility == "" {
+					entry.Visibility = *visibility
+				}
+				journal.record(importEntry(user, entry))
+			}
+		}()
+	}
+	resumed := 0
+	for _, entry := range entries {
+		if journal.finished(entry.Path) {
+			resumed++
+			continue
+		}
+		pending <- entry
+	}
+	close(pending)
+	wg.Wait()
+
+	counts := journal.counts
+	log.Printf("Imported %d, skipped %d, failed %d; %d already done in an earlier run",
+		counts[models.ImportImported], counts[models.ImportSkipped], counts[models.ImportFailed], resumed)
+	if counts[models.ImportImported] > 0 && config.SearchBackend == config.SearchBackendMemory {
+		log.Println("Restart running servers to add the imported videos to their search index")
+	}
+	if counts[models.ImportFailed] > 0 {
+		return 1
+	}
+	return 0
+}
+
+// importEntry imports one file and describes the outcome for the journal
+func importEntry(user models.User, entry models.ImportEntry) models.ImportRecord {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	record := models.ImportRecord{Path: entry.Path}
+	video, skipped, err := controller.ImportFile(ctx, user, entry)
+	switch {
+	case err != nil:
+		record.Status, record.Error = models.ImportFailed, err.Error()
+		log.Printf("Failed to import %s: %v", entry.Path, err)
+	case skipped:
+		record.Status, record.VideoID = models.ImportSkipped, video.ID.Hex()
+		log.Printf("Skipped %s: already imported as video %s", entry.Path, video.ID.Hex())
+	default:
+		record.Status, record.VideoID = models.ImportImported, video.ID.Hex()
+		log.Printf("Imported %s as video %s", entry.Path, video.ID.Hex())
+	}
+	record.At = time.Now()
+	return record
+}
+
+// readImportSource lists the files to import from a directory or a manifest.
+// Paths are made absolute so journal entries survive a change of directory.
+func readImportSource(source string) ([]models.ImportEntry, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.ImportEntry
+	switch {
+	case info.IsDir():
+		entries, err = walkDirectory(source)
+	case strings.EqualFold(filepath.Ext(source), ".csv"):
+		entries, err = readCSVManifest(source)
+	case strings.EqualFold(filepath.Ext(source), ".json"):
+		entries, err = readJSONManifest(source)
+	default:
+		return nil, errors.New("expected a directory or a .csv or .json manifest")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if !info.IsDir() && !filepath.IsAbs(entries[i].Path) {
+			entries[i].Path = filepath.Join(filepath.Dir(source), entries[i].Path)
+		}
+		if entries[i].Path, err = filepath.Abs(entries[i].Path); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// walkDirectory lists the video and audio files below dir in lexical order
+func walkDirectory(dir string) ([]models.ImportEntry, error) {
+	var entries []models.ImportEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Hidden directories hold tool state, not media
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		_, audio := models.AudioContentType(path)
+		if audio || videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			entries = append(entries, models.ImportEntry{Path: path})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// readCSVManifest reads a manifest whose header row names the columns
+func readCSVManifest(path string) ([]models.ImportEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["path"]; !ok {
+		return nil, errors.New("manifest has no path column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var entries []models.ImportEntry
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := models.ImportEntry{
+			Path:        field(row, "path"),
+			Title:       field(row, "title"),
+			Description: field(row, "description"),
+			Visibility:  field(row, "visibility"),
+		}
+		if entry.Path == "" {
+			continue
+		}
+		if tags := field(row, "tags"); tags != "" {
+			entry.Tags = strings.Split(tags, ",")
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// readJSONManifest reads a manifest holding an array of entries
+func readJSONManifest(path string) ([]models.ImportEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.ImportEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	valid := entries[:0]
+	for _, entry := range entries {
+		if entry.Path != "" {
+			valid = append(valid, entry)
+		}
+	}
+	return valid, nil
+}
+
+// importJournal appends one JSON record per processed file. Files whose latest
+// record says imported or skipped are finished; failed files are retried.
+type importJournal struct {
+	mu     sync.Mutex
+	file   *os.File
+	done   map[string]bool
+	counts map[string]int
+}
+
+// openJournal reads the records of earlier runs and opens the journal for appending
+func openJournal(path string) (*importJournal, error) {
+	journal := &importJournal{done: map[string]bool{}, counts: map[string]int{}}
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			var record models.ImportRecord
+			// A line cut short by a crash is ignored; that file is simply imported again
+			if json.Unmarshal(scanner.Bytes(), &record) != nil {
+				continue
+			}
+			journal.done[record.Path] = record.Status != models.ImportFailed
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	journal.file = file
+	return journal, nil
+}
+
+// finished reports whether an earlier run imported or skipped the file
+func (j *importJournal) finished(path string) bool {
+	return j.done[path]
+}
+
+// record appends a record and syncs it, so a crash loses at most the files in flight
+func (j *importJournal) record(record models.ImportRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.counts[record.Status]++
+	line, _ := json.Marshal(record)
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write journal: %v", err)
+		return
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Printf("Failed to sync journal: %v", err)
+	}
+}
+
+// Close closes the journal file
+func (j *importJournal) Close() error {
+	return j.file.Close()
+}
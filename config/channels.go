@@ -0,0 +1,6 @@
+package config
+
+var (
+	// MaxImageBytes is the largest accepted channel avatar or banner image
+	MaxImageBytes = getEnvInt64("HUB_MAX_IMAGE_BYTES", 5<<20)
+)
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+var (
+	// CommentRateLimit is the number of comments a user may post per CommentRateWindow
+	CommentRateLimit = getEnvInt("HUB_COMMENT_RATE_LIMIT", 10)
+
+	// CommentRateWindow is the period CommentRateLimit applies to
+	CommentRateWindow = time.Duration(getEnvInt("HUB_COMMENT_RATE_WINDOW_SECONDS", 60)) * time.Second
+)
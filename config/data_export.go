@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+var (
+	// DataExportRetention is how long a finished data export archive and its link stay available
+	DataExportRetention = time.Duration(getEnvInt("HUB_DATA_EXPORT_RETENTION_HOURS", 72)) * time.Hour
+
+	// DataExportPollInterval is how often workers look for requested exports and expired archives
+	DataExportPollInterval = time.Duration(getEnvInt("HUB_DATA_EXPORT_POLL_SECONDS", 30)) * time.Second
+
+	// DataExportLease is how long a claimed export stays with a worker that stops renewing it
+	DataExportLease = time.Duration(getEnvInt("HUB_DATA_EXPORT_LEASE_MINUTES", 30)) * time.Minute
+)
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+var (
+	// FeedHeavySubscriptions is the number of subscriptions from which a user's
+	// feed is served from a cached timeline instead of being queried on every read
+	FeedHeavySubscriptions = getEnvInt("HUB_FEED_HEAVY_SUBSCRIPTIONS", 100)
+
+	// FeedTimelineSize is the number of videos kept in a cached timeline
+	FeedTimelineSize = getEnvInt("HUB_FEED_TIMELINE_SIZE", 500)
+
+	// FeedTimelineTTL is how long a cached timeline is served before it is rebuilt
+	FeedTimelineTTL = time.Duration(getEnvInt("HUB_FEED_TIMELINE_SECONDS", 120)) * time.Second
+)
@@ -0,0 +1,8 @@
+package config
+
+import "time"
+
+var (
+	// HistoryRetention is how long a watch history entry is kept after its last heartbeat
+	HistoryRetention = time.Duration(getEnvInt("HUB_HISTORY_RETENTION_DAYS", 180)) * 24 * time.Hour
+)
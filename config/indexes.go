@@ -0,0 +1,196 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the handlers rely on
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		// Uploaded files are deduplicated by content hash
+		"video.files": {
+			{
+				Keys: bson.D{{Key: "metadata.sha256", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"metadata.sha256": bson.M{"$exists": true}}),
+			},
+		},
+		"videos": {
+			{Keys: bson.D{{Key: "fileId", Value: 1}}},
+			{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+			{Keys: bson.D{{Key: "visibility", Value: 1}}},
+			{Keys: bson.D{{Key: "tags", Value: 1}}},
+			{Keys: bson.D{{Key: "categoryId", Value: 1}}},
+			// Channel listings and the subscription feed read a channel's videos newest first
+			{Keys: bson.D{{Key: "channelId", Value: 1}, {Key: "_id", Value: -1}}},
+			// The audio library browses tracks by album artist, album and track order
+			{Keys: bson.D{{Key: "audio.albumArtist", Value: 1}, {Key: "audio.album", Value: 1}, {Key: "audio.disc", Value: 1}, {Key: "audio.track", Value: 1}},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"mediaType": "audio"})},
+		},
+		// Expired sessions are removed by MongoDB
+		"sessions": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"playlists": {
+			{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+			{Keys: bson.D{{Key: "items.videoId", Value: 1}}},
+		},
+		// Threads are listed per video and an author's comments newest first
+		"comments": {
+			{Keys: bson.D{{Key: "videoId", Value: 1}, {Key: "parentId", Value: 1}, {Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "threadId", Value: 1}, {Key: "createdAt", Value: 1}}},
+			{Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+		// Comment rate limit counters expire with their window
+		"comment_rates": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		// One reaction per user and video
+		"reactions": {
+			{Keys: bson.D{{Key: "videoId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		// A viewer counted for a video is remembered until the de-duplication window ends
+		"view_marks": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		// One entry per user and video; entries expire after the retention period
+		"history": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "videoId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: -1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		// Category names are unique among siblings; subtrees are found through ancestors
+		"categories": {
+			{Keys: bson.D{{Key: "parentId", Value: 1}, {Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+		},
+		"channels": {
+			{Keys: bson.D{{Key: "handle", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+			{Keys: bson.D{{Key: "team", Value: 1}}},
+			// Encoders are matched to their channel by stream key
+			{
+				Keys: bson.D{{Key: "live.streamKey", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"live.streamKey": bson.M{"$exists": true}}),
+			},
+		},
+		"live_sessions": {
+			{Keys: bson.D{{Key: "channelId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "heartbeatAt", Value: 1}}},
+		},
+		// One subscription per user and channel; subscribers are looked up per channel
+		"subscriptions": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "channelId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "channelId", Value: 1}}},
+		},
+		// Cached feeds are removed by MongoDB once they expire
+		"timelines": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		// Notifications are listed per user newest first and expire after the retention period
+		"notifications": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}}},
+			{Keys: bson.D{{Key: "videoId", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"webhooks": {
+			{Keys: bson.D{{Key: "events", Value: 1}}},
+		},
+		// Due deliveries are claimed oldest first; the log is read per webhook and expires
+		"webhook_deliveries": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+			{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"captions": {
+			{Keys: bson.D{{Key: "videoId", Value: 1}, {Key: "language", Value: 1}}},
+		},
+		// Exports are listed per user newest first and claimed oldest first
+		"data_exports": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		},
+		// The audit log is a hash chain ordered by sequence; a unique sequence keeps
+		// concurrent appends from forking it. It is read newest first per actor or target.
+		"audit": {
+			{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "seq", Value: -1}}},
+			{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "seq", Value: -1}}},
+			{Keys: bson.D{{Key: "action", Value: 1}, {Key: "seq", Value: -1}}},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := DB.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			log.Fatalf("Failed to create indexes on %s: %v", collection, err)
+		}
+	}
+
+	// Search uses a weighted text index unless the in-memory fallback is configured
+	if SearchBackend == SearchBackendMongo {
+		ensureSearchIndex(ctx)
+	}
+}
+
+// ensureSearchIndex creates the weighted text index over videos. MongoDB refuses an
+// index that clashes with an existing one of other options, as happens when the
+// weights or HUB_SEARCH_LANGUAGE change; the old index is then dropped and rebuilt.
+// A failure is logged rather than fatal, since only search depends on the index.
+func ensureSearchIndex(ctx context.Context) {
+	// Keep the weights in a stable order so restarts see the same index options
+	fields := make([]string, 0, len(SearchWeights))
+	for field := range SearchWeights {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	weights := bson.D{}
+	for _, field := range fields {
+		weights = append(weights, bson.E{Key: field, Value: SearchWeights[field]})
+	}
+	model := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "description", Value: "text"},
+			{Key: "tags", Value: "text"},
+			{Key: "captionText", Value: "text"},
+		},
+		Options: options.Index().SetName("search").SetWeights(weights).
+			SetDefaultLanguage(SearchLanguage).SetLanguageOverride("language"),
+	}
+
+	videos := DB.Collection("videos").Indexes()
+	_, err := videos.CreateOne(ctx, model)
+	if indexConflict(err) {
+		log.Printf("Search index options changed, rebuilding the index")
+		if _, err = videos.DropOne(ctx, "search"); err == nil {
+			_, err = videos.CreateOne(ctx, model)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to create the search index, search will fail until it exists: %v", err)
+	}
+}
+
+// indexConflict reports whether an index could not be created because one with the
+// same name or keys but other options exists
+func indexConflict(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	// IndexOptionsConflict and IndexKeySpecsConflict
+	return cmdErr.Code == 85 || cmdErr.Code == 86
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultLiveCommand segments an FLV stream read from stdin into a sliding-window
+// HLS playlist and records it at the same time, without transcoding.
+// Placeholders in braces are substituted per session before running.
+const defaultLiveCommand = "-hide_banner -loglevel error -y -f flv -i pipe:0 " +
+	"-map 0:v:0 -map 0:a:0? -c copy " +
+	"-f hls -hls_time {segment} -hls_list_size {window} -hls_flags delete_segments+independent_segments " +
+	"-hls_segment_filename {output}/live_%05d.ts {output}/index.m3u8 " +
+	"-map 0:v:0 -map 0:a:0? -c copy -movflags +faststart {output}/recording.mp4"
+
+var (
+	// LiveRTMPAddr is the address of the RTMP ingest listener; "off" disables live streaming
+	LiveRTMPAddr = getEnv("HUB_LIVE_RTMP_ADDR", ":1935")
+
+	// LiveRTMPURL is the ingest URL shown to channel managers for their encoder
+	LiveRTMPURL = getEnv("HUB_LIVE_RTMP_URL", "rtmp://localhost:1935/live")
+
+	// LiveApp is the RTMP application encoders publish to
+	LiveApp = getEnv("HUB_LIVE_APP", "live")
+
+	// LiveDir holds the playlists, segments and recordings of running streams
+	LiveDir = getEnv("HUB_LIVE_DIR", filepath.Join(os.TempDir(), "hub-live"))
+
+	// LiveCommand is the ffmpeg argument template run for each live session
+	LiveCommand = getEnv("HUB_LIVE_COMMAND", defaultLiveCommand)
+
+	// LiveSegmentSeconds is the target duration of live segments
+	LiveSegmentSeconds = getEnvInt("HUB_LIVE_SEGMENT_SECONDS", 2)
+
+	// LiveWindowSegments is the number of segments kept in the live playlist
+	LiveWindowSegments = getEnvInt("HUB_LIVE_WINDOW_SEGMENTS", 6)
+
+	// LiveStaleAfter is how long a session may go without a heartbeat from the
+	// process serving it before it is taken to have died with that process
+	LiveStaleAfter = time.Duration(getEnvInt("HUB_LIVE_STALE_SECONDS", 60)) * time.Second
+)
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rung describes one rendition of the adaptive bitrate ladder
+type Rung struct {
+	Name         string // Rendition name, used in storage paths and playlists
+	Width        int    // Output width in pixels
+	Height       int    // Output height in pixels
+	VideoBitrate int    // Target video bitrate in kbit/s
+	AudioBitrate int    // Target audio bitrate in kbit/s
+}
+
+// Bandwidth returns the peak bandwidth advertised for the rung, in bit/s
+func (r Rung) Bandwidth() int {
+	return (r.VideoBitrate + r.AudioBitrate) * 1000
+}
+
+// defaultLadder is used when HUB_HLS_LADDER is not set
+const defaultLadder = "1080p:1920x1080:5000:192,720p:1280x720:2800:128,480p:854x480:1400:128,360p:640x360:800:96"
+
+// defaultHLSCommand packages one rung into fragmented-MP4 HLS segments.
+// Placeholders in braces are substituted per rung before running. Keyframes are
+// forced on segment boundaries by time, so segments line up at any frame rate.
+const defaultHLSCommand = "-hide_banner -loglevel error -y -i {input} " +
+	"-map 0:v:0 -map 0:a:0? -c:v libx264 -preset veryfast -profile:v main " +
+	"-vf scale=w={width}:h={height}:force_original_aspect_ratio=decrease:force_divisible_by=2 " +
+	"-b:v {vbitrate}k -maxrate {vbitrate}k -bufsize {vbuffer}k -force_key_frames expr:gte(t,n_forced*{segment}) -sc_threshold 0 " +
+	"-c:a aac -b:a {abitrate}k -ac 2 " +
+	"-f hls -hls_time {segment} -hls_playlist_type vod -hls_segment_type fmp4 " +
+	"-hls_fmp4_init_filename init.mp4 -hls_segment_filename {output}/segment_%05d.m4s {output}/index.m3u8"
+
+var (
+	// FFmpegPath is the ffmpeg binary used for packaging
+	FFmpegPath = getEnv("HUB_FFMPEG", "ffmpeg")
+
+	// FFprobePath is the ffprobe binary used to read duration and chapters
+	FFprobePath = getEnv("HUB_FFPROBE", "ffprobe")
+
+	// HLSCommand is the ffmpeg argument template used for each rendition
+	HLSCommand = getEnv("HUB_HLS_COMMAND", defaultHLSCommand)
+
+	// HLSSegmentSeconds is the target segment duration
+	HLSSegmentSeconds = getEnvInt("HUB_HLS_SEGMENT_SECONDS", 6)
+
+	// HLSLadder is the list of renditions produced for every video
+	HLSLadder = parseLadder(getEnv("HUB_HLS_LADDER", defaultLadder))
+
+	// HLSStaleAfter is how long packaging may go without a sign of life before
+	// it is taken to have died with its process and may be started again
+	HLSStaleAfter = time.Duration(getEnvInt("HUB_HLS_STALE_SECONDS", 600)) * time.Second
+)
+
+// parseLadder parses "name:WxH:videoKbps:audioKbps" entries separated by commas
+func parseLadder(spec string) []Rung {
+	var ladder []Rung
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 4 {
+			log.Printf("Ignoring invalid ladder entry %q", entry)
+			continue
+		}
+		size := strings.SplitN(parts[1], "x", 2)
+		if len(size) != 2 {
+			log.Printf("Ignoring invalid ladder entry %q", entry)
+			continue
+		}
+		width, errW := strconv.Atoi(size[0])
+		height, errH := strconv.Atoi(size[1])
+		video, errV := strconv.Atoi(parts[2])
+		audio, errA := strconv.Atoi(parts[3])
+		if errW != nil || errH != nil || errV != nil || errA != nil {
+			log.Printf("Ignoring invalid ladder entry %q", entry)
+			continue
+		}
+		ladder = append(ladder, Rung{Name: parts[0], Width: width, Height: height, VideoBitrate: video, AudioBitrate: audio})
+	}
+	return ladder
+}
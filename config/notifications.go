@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+var (
+	// NotificationRetention is how long a notification is kept
+	NotificationRetention = time.Duration(getEnvInt("HUB_NOTIFICATION_RETENTION_DAYS", 90)) * 24 * time.Hour
+
+	// NotificationKeepAlive is the interval of keep-alive comments on notification streams,
+	// which stops proxies from closing idle connections
+	NotificationKeepAlive = time.Duration(getEnvInt("HUB_NOTIFICATION_KEEPALIVE_SECONDS", 25)) * time.Second
+)
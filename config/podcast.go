@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+var (
+	// PodcastMaxItems caps the number of episodes in a podcast feed
+	PodcastMaxItems = getEnvInt("HUB_PODCAST_MAX_ITEMS", 100)
+
+	// PodcastCategory is the iTunes category announced by every podcast feed
+	PodcastCategory = getEnv("HUB_PODCAST_CATEGORY", "Technology")
+
+	// PodcastURLTTL is the lifetime of the signed enclosure URLs of episodes that
+	// anonymous listeners could not stream. A feed keeps the same URLs for half of it.
+	PodcastURLTTL = time.Duration(getEnvInt("HUB_PODCAST_URL_TTL_SECONDS", 24*60*60)) * time.Second
+)
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"log"
+	"net"
+	"strings"
+)
+
+// TrustedProxies are the networks of reverse proxies whose X-Forwarded-For,
+// X-Forwarded-Proto and X-Forwarded-Host headers are believed. Requests from
+// anywhere else are taken at their connection address, scheme and host.
+var TrustedProxies = parseNetworks(getEnv("HUB_TRUSTED_PROXIES", ""))
+
+// parseNetworks parses comma-separated CIDR networks or single addresses
+func parseNetworks(spec string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
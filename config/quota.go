@@ -0,0 +1,49 @@
+package config
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// MaxUploadBytes is the largest video file accepted by a single upload
+	MaxUploadBytes = getEnvInt64("HUB_MAX_UPLOAD_BYTES", 2<<30)
+
+	// DefaultQuotaBytes applies to roles without an entry in RoleQuotas, 0 means unlimited
+	DefaultQuotaBytes = getEnvInt64("HUB_DEFAULT_QUOTA_BYTES", 10<<30)
+
+	// RoleQuotas maps a role to its storage quota in bytes, 0 means unlimited
+	RoleQuotas = parseRoleQuotas(getEnv("HUB_ROLE_QUOTAS", "admin:0"))
+
+	// SessionTTL is how long a login stays valid
+	SessionTTL = time.Duration(getEnvInt("HUB_SESSION_TTL_HOURS", 24*7)) * time.Hour
+)
+
+// QuotaForRole returns the storage quota of a role, 0 means unlimited
+func QuotaForRole(role string) int64 {
+	if quota, ok := RoleQuotas[role]; ok {
+		return quota
+	}
+	return DefaultQuotaBytes
+}
+
+// parseRoleQuotas parses "role:bytes" entries separated by commas
+func parseRoleQuotas(spec string) map[string]int64 {
+	quotas := map[string]int64{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, value, ok := strings.Cut(entry, ":")
+		bytes, err := strconv.ParseInt(value, 10, 64)
+		if !ok || err != nil || bytes < 0 {
+			log.Printf("Ignoring invalid role quota %q", entry)
+			continue
+		}
+		quotas[role] = bytes
+	}
+	return quotas
+}
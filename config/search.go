@@ -0,0 +1,24 @@
+package config
+
+// Search backends
+const (
+	SearchBackendMongo  = "mongo"  // MongoDB text index
+	SearchBackendMemory = "memory" // In-process inverted index for databases without text indexes
+)
+
+var (
+	// SearchBackend selects how GET /search finds videos
+	SearchBackend = getEnv("HUB_SEARCH_BACKEND", SearchBackendMongo)
+
+	// SearchLanguage is the default stemming language of the text index and of queries.
+	// The in-memory backend and highlighting only stem English.
+	SearchLanguage = getEnv("HUB_SEARCH_LANGUAGE", "english")
+
+	// SearchWeights ranks matches per video field; a title match counts the most
+	SearchWeights = map[string]int{
+		"title":       10,
+		"tags":        5,
+		"description": 3,
+		"captionText": 1,
+	}
+)
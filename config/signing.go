@@ -0,0 +1,56 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// SigningKeys maps key IDs to the HMAC secrets accepted for signed playback URLs.
+	// Keep retired keys here until the URLs they signed have expired.
+	SigningKeys = parseSigningKeys(getEnv("HUB_SIGNING_KEYS", ""))
+
+	// SigningKeyID selects the key used to mint new signed URLs
+	SigningKeyID = getEnv("HUB_SIGNING_KEY_ID", "")
+
+	// SignedURLMaxTTL caps the lifetime of a signed playback URL
+	SignedURLMaxTTL = time.Duration(getEnvInt("HUB_SIGNED_URL_MAX_TTL_SECONDS", 24*60*60)) * time.Second
+)
+
+func init() {
+	if len(SigningKeys) > 0 {
+		if _, ok := SigningKeys[SigningKeyID]; !ok {
+			log.Fatalf("HUB_SIGNING_KEY_ID %q is not one of HUB_SIGNING_KEYS", SigningKeyID)
+		}
+		return
+	}
+
+	// Without configured keys, signed URLs only live as long as the process
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal(err)
+	}
+	SigningKeyID = "ephemeral"
+	SigningKeys = map[string][]byte{SigningKeyID: secret}
+	log.Println("HUB_SIGNING_KEYS not set; signed URLs will not survive a restart")
+}
+
+// parseSigningKeys parses "id:secret" entries separated by commas
+func parseSigningKeys(spec string) map[string][]byte {
+	keys := map[string][]byte{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(secret) < 16 {
+			log.Printf("Ignoring invalid signing key entry for %q (secrets need at least 16 characters)", id)
+			continue
+		}
+		keys[id] = []byte(secret)
+	}
+	return keys
+}
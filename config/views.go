@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+var (
+	// ViewDedupWindow is how long repeated plays by the same viewer count as one view
+	ViewDedupWindow = time.Duration(getEnvInt("HUB_VIEW_DEDUP_SECONDS", 1800)) * time.Second
+
+	// ViewFlushInterval is how often buffered view counts are written to the database
+	ViewFlushInterval = time.Duration(getEnvInt("HUB_VIEW_FLUSH_SECONDS", 15)) * time.Second
+)
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+var (
+	// WebhookMaxAttempts is the number of times a delivery is tried before it is given up
+	WebhookMaxAttempts = getEnvInt("HUB_WEBHOOK_MAX_ATTEMPTS", 6)
+
+	// WebhookBackoff is the delay before the first retry; it doubles with every further attempt
+	WebhookBackoff = time.Duration(getEnvInt("HUB_WEBHOOK_BACKOFF_SECONDS", 10)) * time.Second
+
+	// WebhookTimeout bounds a single delivery request
+	WebhookTimeout = time.Duration(getEnvInt("HUB_WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second
+
+	// WebhookPollInterval is how often due deliveries are looked for
+	WebhookPollInterval = time.Duration(getEnvInt("HUB_WEBHOOK_POLL_SECONDS", 5)) * time.Second
+
+	// WebhookLogRetention is how long deliveries are kept in the log
+	WebhookLogRetention = time.Duration(getEnvInt("HUB_WEBHOOK_LOG_DAYS", 30)) * 24 * time.Hour
+)
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"hub/config"
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// storeArtwork saves the embedded artwork of an uploaded track in the images bucket.
+// Artwork that is too large or not a supported image is skipped.
+func storeArtwork(ctx context.Context, fileID primitive.ObjectID, artwork *media.Artwork) *primitive.ObjectID {
+	if int64(len(artwork.Data)) > config.MaxImageBytes {
+		return nil
+	}
+	// Trust the bytes rather than the tag's MIME type
+	contentType := http.DetectContentType(artwork.Data)
+	if !imageTypes[contentType] {
+		return nil
+	}
+
+	bucket, err := imagesBucket()
+	if err != nil {
+		log.Printf("Failed to store artwork of file %s: %v", fileID.Hex(), err)
+		return nil
+	}
+	name := fmt.Sprintf("artwork/%s", fileID.Hex())
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": contentType})
+	artworkID, err := bucket.UploadFromStream(name, bytes.NewReader(artwork.Data), uploadOpts)
+	if err != nil {
+		log.Printf("Failed to store artwork of file %s: %v", fileID.Hex(), err)
+		return nil
+	}
+	return &artworkID
+}
+
+// GetArtwork serves the artwork embedded in an audio track
+// @Summary Get track artwork
+// @Description Returns the artwork read from the tags of an audio track
+// @Tags Audio
+// @Param id path string true "Video ID"
+// @Produce image/png
+// @Success 200 {file} file "Image"
+// @Failure 404 {string} string "Artwork not found"
+// @Router /videos/{id}/artwork [get]
+func GetArtwork(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if video.Audio == nil || video.Audio.ArtworkFileID == nil {
+		http.Error(w, "Artwork not found", http.StatusNotFound)
+		return
+	}
+	fileID := video.Audio.ArtworkFileID
+
+	// Artwork is stored once per upload, so the image behind a file ID never changes
+	etag := `"` + fileID.Hex() + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	bucket, err := imagesBucket()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+	downloadStream, err := bucket.OpenDownloadStream(*fileID)
+	if err != nil {
+		http.Error(w, "Artwork not found", http.StatusNotFound)
+		return
+	}
+	defer downloadStream.Close()
+
+	var metadata struct {
+		ContentType string `bson:"contentType"`
+	}
+	if raw := downloadStream.GetFile().Metadata; raw != nil {
+		bson.Unmarshal(raw, &metadata)
+	}
+	if metadata.ContentType != "" {
+		w.Header().Set("Content-Type", metadata.ContentType)
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if _, err := io.Copy(w, downloadStream); err != nil {
+		log.Printf("Failed to stream artwork %s: %v", fileID.Hex(), err)
+	}
+}
+
+// audioFilter matches the audio tracks the user may list
+func audioFilter(user *models.User) bson.M {
+	return bson.M{"$and": bson.A{bson.M{"mediaType": models.MediaAudio}, listableFilter(user)}}
+}
+
+// ListArtists lists the album artists of the audio library
+// @Summary List artists
+// @Description Lists the album artists of listable audio tracks with their album and track counts, by name
+// @Tags Audio
+// @Param prefix query string false "Only artists whose name starts with this text"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of artists to skip"
+// @Produce json
+// @Success 200 {array} models.Artist
+// @Router /audio/artists [get]
+func ListArtists(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	match := audioFilter(optionalUser(ctx, r))
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		match["audio.albumArtist"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}
+	} else {
+		match["audio.albumArtist"] = bson.M{"$gt": ""}
+	}
+	limit, offset := pagination(r)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$audio.albumArtist",
+			"albums": bson.M{"$addToSet": "$audio.album"},
+			"tracks": bson.M{"$sum": 1},
+		}}},
+		// Tracks without an album tag do not make an album
+		{{Key: "$set", Value: bson.M{"albums": bson.M{"$size": bson.M{"$setDifference": bson.A{"$albums", bson.A{nil, ""}}}}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := config.DB.Collection("videos").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	artists := []models.Artist{}
+	if err := cursor.All(ctx, &artists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(artists)
+}
+
+// ListAlbums lists the albums of the audio library
+// @Summary List albums
+// @Description Lists the albums of listable audio tracks by artist and title. artworkId names a track whose artwork represents the album.
+// @Tags Audio
+// @Param artist query string false "Only albums of this album artist"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of albums to skip"
+// @Produce json
+// @Success 200 {array} models.Album
+// @Router /audio/albums [get]
+func ListAlbums(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	match := audioFilter(optionalUser(ctx, r))
+	match["audio.album"] = bson.M{"$gt": ""}
+	if artist := r.URL.Query().Get("artist"); artist != "" {
+		match["audio.albumArtist"] = artist
+	}
+	limit, offset := pagination(r)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		// Sorting by track first makes the earliest track with artwork the cover
+		{{Key: "$sort", Value: bson.D{{Key: "audio.disc", Value: 1}, {Key: "audio.track", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"artist": "$audio.albumArtist", "title": "$audio.album"},
+			"year":    bson.M{"$max": "$audio.year"},
+			"tracks":  bson.M{"$sum": 1},
+			"artwork": bson.M{"$push": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$type": "$audio.artworkFileId"}, "objectId"}}, "$_id", "$$REMOVE"}}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"artist":    bson.M{"$ifNull": bson.A{"$_id.artist", ""}},
+			"title":     "$_id.title",
+			"year":      1,
+			"tracks":    1,
+			"artworkId": bson.M{"$first": "$artwork"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "artist", Value: 1}, {Key: "title", Value: 1}}}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := config.DB.Collection("videos").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	albums := []models.Album{}
+	if err := cursor.All(ctx, &albums); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(albums)
+}
+
+// ListTracks lists audio tracks in album order
+// @Summary List tracks
+// @Description Lists listable audio tracks, optionally of one album artist or album, ordered by album, disc and track number
+// @Tags Audio
+// @Param artist query string false "Only tracks of this album artist"
+// @Param album query string false "Only tracks of this album"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of tracks to skip"
+// @Produce json
+// @Success 200 {array} models.Video
+// @Router /audio/tracks [get]
+func ListTracks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := optionalUser(ctx, r)
+	filter := audioFilter(user)
+	if artist := r.URL.Query().Get("artist"); artist != "" {
+		filter["audio.albumArtist"] = artist
+	}
+	if album := r.URL.Query().Get("album"); album != "" {
+		filter["audio.album"] = album
+	}
+	limit, offset := pagination(r)
+	sort := bson.D{
+		{Key: "audio.albumArtist", Value: 1},
+		{Key: "audio.album", Value: 1},
+		{Key: "audio.disc", Value: 1},
+		{Key: "audio.track", Value: 1},
+		{Key: "title", Value: 1},
+	}
+	opts := options.Find().SetSort(sort).SetLimit(limit).SetSkip(offset)
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	tracks := []models.Video{}
+	if err := cursor.All(ctx, &tracks); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attachResumePositions(ctx, user, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tracks)
+}
@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// requestIDHeader carries the ID that ties audit entries to a request
+const requestIDHeader = "X-Request-ID"
+
+// auditMu serialises appends from this process; the unique sequence index
+// catches appends racing in from other processes
+var auditMu sync.Mutex
+
+// RequestID gives every request an ID, keeping a well-formed one sent by a proxy,
+// and echoes it in the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+			r.Header.Set(requestIDHeader, id)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListAuditEntries lists the audit log
+// @Summary List audit entries
+// @Description Lists the audit log newest first, filtered by actor, action, target and time (admin only).
+// @Tags Audit
+// @Produce json
+// @Param actor query string false "Acting user ID"
+// @Param action query string false "Action, e.g. video.deleted"
+// @Param targetType query string false "Kind of target"
+// @Param targetId query string false "Target ID"
+// @Param since query string false "Earliest time (RFC 3339)"
+// @Param until query string false "Latest time (RFC 3339)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {string} string "Invalid time"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Router /audit [get]
+func ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := bson.M{}
+	for param, field := range map[string]string{"actor": "actorId", "action": "action", "targetType": "targetType", "targetId": "targetId"} {
+		if value := query.Get(param); value != "" {
+			filter[field] = value
+		}
+	}
+	at := bson.M{}
+	for param, op := range map[string]string{"since": "$gte", "until": "$lte"} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid time", http.StatusBadRequest)
+				return
+			}
+			at[op] = t
+		}
+	}
+	if len(at) > 0 {
+		filter["at"] = at
+	}
+
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(limit).SetSkip(offset)
+	cursor, err := config.DB.Collection("audit").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// VerifyAuditLog checks the hash chain of the audit log
+// @Summary Verify the audit log
+// @Description Recomputes the hash of every audit entry in order and reports the first entry that was changed, removed or inserted out of order (admin only).
+// @Tags Audit
+// @Produce json
+// @Success 200 {object} models.AuditVerification
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Router /audit/verify [get]
+func VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+
+	cursor, err := config.DB.Collection("audit").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	result := models.AuditVerification{Valid: true}
+	var prev models.AuditEntry
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Checked++
+		if reason := auditBreak(prev, entry); reason != "" {
+			result.Valid, result.BrokenAt, result.BrokenID, result.Reason = false, entry.Seq, entry.ID.Hex(), reason
+			break
+		}
+		prev = entry
+	}
+	if err := cursor.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// recordAudit appends an entry to the audit log. The request is nil for actions
+// the system takes on its own; otherwise its address, user agent and ID are kept.
+// Failures are logged rather than failing the action.
+func recordAudit(ctx context.Context, r *http.Request, entry models.AuditEntry) {
+	// MongoDB keeps milliseconds, so the hash is taken over what is stored
+	entry.At = time.Now().UTC().Truncate(time.Millisecond)
+	if r != nil {
+		entry.IP = clientIP(r)
+		entry.UserAgent = r.UserAgent()
+		entry.RequestID = r.Header.Get(requestIDHeader)
+	}
+	if err := appendAudit(ctx, entry); err != nil {
+		log.Printf("Failed to record audit entry %s on %s %s: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+// appendAudit links the entry to the last one in the chain and inserts it.
+// An entry can only be hashed once its predecessor is known, so appends are
+// serialised rather than numbered from a counter: auditMu orders appends within
+// this process, and when another process takes the same sequence number first
+// the unique index refuses the insert and the append is retried on top of the
+// new last entry. After five lost races the entry is given up and the error
+// returned, which recordAudit logs.
+func appendAudit(ctx context.Context, entry models.AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	collection := config.DB.Collection("audit")
+	for attempt := 0; attempt < 5; attempt++ {
+		var last models.AuditEntry
+		err := collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		entry.Hash = auditHash(entry)
+		_, err = collection.InsertOne(ctx, entry)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return errors.New("audit log is contended")
+}
+
+// auditBreak returns why an entry does not follow prev in the chain, or an empty
+// string when it does. The zero entry stands before the first one. Entries
+// without a sequence number sort first and are never part of the chain.
+func auditBreak(prev, entry models.AuditEntry) string {
+	switch {
+	case entry.Seq < 1:
+		return "entry has no sequence number"
+	case entry.Seq != prev.Seq+1:
+		return "sequence gap"
+	case entry.PrevHash != prev.Hash:
+		return "previous hash does not match"
+	case entry.Hash != auditHash(entry):
+		return "entry was modified"
+	}
+	return ""
+}
+
+// auditHash returns the SHA-256 of an entry's JSON form without its ID and hash.
+// The previous hash is part of that form, which chains the entries.
+func auditHash(entry models.AuditEntry) string {
+	entry.ID = primitive.NilObjectID
+	entry.Hash = ""
+	entry.At = entry.At.UTC()
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditRedacted are fields whose values never go into the audit log. A change to
+// one is recorded without its values.
+var auditRedacted = map[string]bool{"password": true, "secret": true}
+
+// auditChanges returns the fields whose JSON values differ between two versions
+// of a resource
+func auditChanges(before, after interface{}) []models.AuditChange {
+	oldFields, newFields := auditFields(before), auditFields(after)
+	names := map[string]bool{}
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+
+	var changes []models.AuditChange
+	for name := range names {
+		if string(oldFields[name]) == string(newFields[name]) {
+			continue
+		}
+		if auditRedacted[name] {
+			changes = append(changes, models.AuditChange{Field: name})
+			continue
+		}
+		changes = append(changes, models.AuditChange{Field: name, Before: string(oldFields[name]), After: string(newFields[name])})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// auditFields returns the top-level JSON fields of a value
+func auditFields(value interface{}) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if data, err := json.Marshal(value); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields
+}
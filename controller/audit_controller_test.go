@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// auditChain links the entries the way appendAudit does
+func auditChain(entries ...models.AuditEntry) []models.AuditEntry {
+	var prev models.AuditEntry
+	for i := range entries {
+		entries[i].Seq = prev.Seq + 1
+		entries[i].PrevHash = prev.Hash
+		entries[i].Hash = auditHash(entries[i])
+		prev = entries[i]
+	}
+	return entries
+}
+
+// firstBreak returns the sequence number and reason of the first entry that does
+// not follow its predecessor, as VerifyAuditLog reports it
+func firstBreak(entries []models.AuditEntry) (int64, string) {
+	var prev models.AuditEntry
+	for _, entry := range entries {
+		if reason := auditBreak(prev, entry); reason != "" {
+			return entry.Seq, reason
+		}
+		prev = entry
+	}
+	return 0, ""
+}
+
+func testAuditEntries() []models.AuditEntry {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return auditChain(
+		models.AuditEntry{At: at, ActorID: "u1", Action: models.AuditVideoUploaded, TargetType: "video", TargetID: "v1"},
+		models.AuditEntry{At: at.Add(time.Minute), ActorID: "u1", Action: models.AuditVideoVisibilityChanged, TargetType: "video", TargetID: "v1",
+			Changes: []models.AuditChange{{Field: "visibility", Before: `"public"`, After: `"private"`}}},
+		models.AuditEntry{At: at.Add(2 * time.Minute), ActorID: "u2", Action: models.AuditVideoDeleted, TargetType: "video", TargetID: "v1"},
+		models.AuditEntry{At: at.Add(3 * time.Minute), Action: models.AuditDataExportCompleted, TargetType: "data_export", TargetID: "e1"},
+	)
+}
+
+func TestAuditHash(t *testing.T) {
+	entry := testAuditEntries()[1]
+	hash := auditHash(entry)
+	if len(hash) != 64 {
+		t.Fatalf("hash %q is not hex SHA-256", hash)
+	}
+
+	// The ID and stored hash are assigned around hashing; the time zone is not kept by MongoDB
+	stored := entry
+	stored.ID = primitive.NewObjectID()
+	stored.Hash = "something else"
+	stored.At = entry.At.In(time.FixedZone("CET", 3600))
+	if got := auditHash(stored); got != hash {
+		t.Errorf("hash of the stored entry = %s, want %s", got, hash)
+	}
+
+	for name, change := range map[string]func(*models.AuditEntry){
+		"actor":     func(e *models.AuditEntry) { e.ActorID = "u9" },
+		"time":      func(e *models.AuditEntry) { e.At = e.At.Add(time.Millisecond) },
+		"change":    func(e *models.AuditEntry) { e.Changes[0].After = `"public"` },
+		"sequence":  func(e *models.AuditEntry) { e.Seq++ },
+		"prev hash": func(e *models.AuditEntry) { e.PrevHash = "" },
+	} {
+		changed := entry
+		changed.Changes = append([]models.AuditChange(nil), entry.Changes...)
+		change(&changed)
+		if auditHash(changed) == hash {
+			t.Errorf("changing the %s keeps the hash", name)
+		}
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]models.AuditEntry) []models.AuditEntry
+		seq    int64
+		reason string
+	}{
+		{"intact", func(e []models.AuditEntry) []models.AuditEntry { return e }, 0, ""},
+		{"edited", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].ActorID = "u9"
+			return e
+		}, 2, "entry was modified"},
+		{"edited and rehashed", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].ActorID = "u9"
+			e[1].Hash = auditHash(e[1])
+			return e
+		}, 3, "previous hash does not match"},
+		{"removed", func(e []models.AuditEntry) []models.AuditEntry {
+			return append(e[:1], e[2:]...)
+		}, 3, "sequence gap"},
+		{"removed and renumbered", func(e []models.AuditEntry) []models.AuditEntry {
+			e = append(e[:1], e[2:]...)
+			for i := 1; i < len(e); i++ {
+				e[i].Seq = int64(i + 1)
+			}
+			return e
+		}, 2, "previous hash does not match"},
+		{"first removed", func(e []models.AuditEntry) []models.AuditEntry {
+			return e[1:]
+		}, 2, "sequence gap"},
+		{"last removed", func(e []models.AuditEntry) []models.AuditEntry {
+			return e[:len(e)-1]
+		}, 0, ""},
+		{"inserted without a sequence", func(e []models.AuditEntry) []models.AuditEntry {
+			forged := models.AuditEntry{At: e[0].At, Action: models.AuditLoginSucceeded, TargetType: "user", TargetID: "u1"}
+			return append([]models.AuditEntry{forged}, e...)
+		}, 0, "entry has no sequence number"},
+		{"inserted", func(e []models.AuditEntry) []models.AuditEntry {
+			forged := models.AuditEntry{Seq: 2, At: e[0].At, Action: models.AuditLoginSucceeded, TargetType: "user", TargetID: "u1", PrevHash: e[0].Hash}
+			forged.Hash = auditHash(forged)
+			return append(e[:1], append([]models.AuditEntry{forged}, e[1:]...)...)
+		}, 2, "sequence gap"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seq, reason := firstBreak(test.tamper(testAuditEntries()))
+			if seq != test.seq || reason != test.reason {
+				t.Errorf("first break = %d %q, want %d %q", seq, reason, test.seq, test.reason)
+			}
+		})
+	}
+}
@@ -2,15 +2,27 @@ package controller
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"hub/config"
 	"hub/models"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
+
+// sessionCookie is the cookie carrying the session token for browser clients
+const sessionCookie = "hub_session"
+
+// errUnauthenticated is returned when a request carries no valid session
+var errUnauthenticated = errors.New("unauthenticated")
+
 // LoginHandler handles user login by username and password
 // @Summary Login User
 // @Description Authenticate a user by username and password
@@ -19,6 +31,7 @@ import (
 // @Produce json
 // @Param credentials body models.LoginCredentials true "User Credentials"
 // @Success 200 {object} models.User
+// @Header 200 {string} X-Auth-Token "Session token to send as a Bearer token"
 // @Failure 400 {string} string "Invalid request body"
 // @Failure 401 {string} string "Invalid username or password"
 // @Router /login [post]
@@ -41,11 +54,113 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
     filter := bson.M{"username": loginData.Username, "password": loginData.Password}
     err := collection.FindOne(ctx, filter).Decode(&user)
     if err != nil {
+        recordAudit(ctx, r, models.AuditEntry{Action: models.AuditLoginFailed, TargetType: "username", TargetID: loginData.Username})
         http.Error(w, "Invalid username or password", http.StatusUnauthorized)
         return
     }
 
+    // Start a session and hand out its token as a header and a cookie
+    session, err := newSession(ctx, user.ID)
+    if err != nil {
+        http.Error(w, "Failed to create session", http.StatusInternalServerError)
+        return
+    }
+    recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditLoginSucceeded, TargetType: "user", TargetID: user.ID})
+    w.Header().Set("X-Auth-Token", session.Token)
+    http.SetCookie(w, &http.Cookie{
+        Name:     sessionCookie,
+        Value:    session.Token,
+        Path:     "/",
+        Expires:  session.ExpiresAt,
+        HttpOnly: true,
+        SameSite: http.SameSiteLaxMode,
+    })
+
     // Return the user data as JSON
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(user)
 }
+
+// newSession stores a new session for the user
+func newSession(ctx context.Context, userID string) (models.Session, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return models.Session{}, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		Token:     hex.EncodeToString(token),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.SessionTTL),
+	}
+	_, err := config.DB.Collection("sessions").InsertOne(ctx, session)
+	return session, err
+}
+
+// sessionToken returns the token from the Authorization header or the session cookie
+func sessionToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// currentUser resolves the user of the request's session
+func currentUser(ctx context.Context, r *http.Request) (models.User, error) {
+	var user models.User
+	token := sessionToken(r)
+	if token == "" {
+		return user, errUnauthenticated
+	}
+
+	var session models.Session
+	err := config.DB.Collection("sessions").FindOne(ctx, bson.M{"_id": token, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&session)
+	if err != nil {
+		return user, errUnauthenticated
+	}
+
+	user, err = findUser(ctx, session.UserID)
+	if err != nil {
+		return user, errUnauthenticated
+	}
+	return user, nil
+}
+
+// requireUser writes a 401 response and returns false when the request is not authenticated
+func requireUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return user, false
+	}
+	return user, true
+}
+
+// requireAdmin writes a 401 or 403 response and returns false unless the request comes from an admin
+func requireAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return user, false
+	}
+	if user.Role != models.RoleAdmin {
+		http.Error(w, "Admin role required", http.StatusForbidden)
+		return user, false
+	}
+	return user, true
+}
+
+// findUser loads the user with the given hex ID
+func findUser(ctx context.Context, id string) (models.User, error) {
+	var user models.User
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return user, err
+	}
+	err = config.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	return user, err
+}
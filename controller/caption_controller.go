@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxCaptionSize limits the size of uploaded caption files
+const maxCaptionSize = 5 << 20
+
+// UploadCaption adds a caption track to a video
+// @Summary Upload a caption track
+// @Description Uploads an SRT or WebVTT caption file. SRT is converted to WebVTT and cue timing is validated.
+// @Tags Captions
+// @Accept multipart/form-data
+// @Param id path string true "Video ID"
+// @Param caption formData file true "SRT or WebVTT file"
+// @Param language formData string true "BCP 47 language tag"
+// @Param label formData string false "Track label"
+// @Param default formData bool false "Make this the default track"
+// @Produce json
+// @Success 201 {object} models.Caption
+// @Failure 400 {string} string "Invalid caption file"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/captions [post]
+func UploadCaption(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCaptionSize+1<<20)
+	file, _, err := r.FormFile("caption")
+	if err != nil {
+		http.Error(w, "Unable to read caption file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	language := strings.TrimSpace(r.FormValue("language"))
+	if language == "" {
+		http.Error(w, "Language is required", http.StatusBadRequest)
+		return
+	}
+	label := strings.TrimSpace(r.FormValue("label"))
+	if label == "" {
+		label = language
+	}
+	isDefault, _ := strconv.ParseBool(r.FormValue("default"))
+
+	data, err := io.ReadAll(io.LimitReader(file, maxCaptionSize+1))
+	if err != nil || len(data) > maxCaptionSize {
+		http.Error(w, "Caption file is too large", http.StatusBadRequest)
+		return
+	}
+
+	// Normalise SRT and WebVTT input to WebVTT
+	cues, err := media.ParseCaptions(string(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid caption file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	caption := models.Caption{
+		VideoID:   video.ID,
+		Language:  language,
+		Label:     label,
+		Default:   isDefault,
+		CueCount:  len(cues),
+		Duration:  cues[len(cues)-1].End.Seconds(),
+		Content:   media.RenderWebVTT(cues),
+		CreatedAt: time.Now(),
+	}
+
+	collection := config.DB.Collection("captions")
+	if isDefault {
+		// Only one track per video can be the default
+		_, err = collection.UpdateMany(ctx, bson.M{"videoId": video.ID}, bson.M{"$set": bson.M{"default": false}})
+		if err != nil {
+			http.Error(w, "Failed to update captions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result, err := collection.InsertOne(ctx, caption)
+	if err != nil {
+		http.Error(w, "Failed to save caption", http.StatusInternalServerError)
+		return
+	}
+	caption.ID = result.InsertedID.(primitive.ObjectID)
+	if err := refreshCaptionText(ctx, video.ID); err != nil {
+		log.Printf("Failed to index captions of video %s: %v", video.ID.Hex(), err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(caption)
+}
+
+// ListCaptions lists the caption tracks of a video
+// @Summary List caption tracks
+// @Description Returns the caption tracks of a video without their contents
+// @Tags Captions
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {array} models.Caption
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/captions [get]
+func ListCaptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	captions, err := findCaptions(ctx, video.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(captions)
+}
+
+// GetCaption serves a caption track as WebVTT
+// @Summary Get a caption track
+// @Description Returns a caption track as WebVTT
+// @Tags Captions
+// @Param id path string true "Video ID"
+// @Param captionId path string true "Caption ID"
+// @Produce text/vtt
+// @Success 200 {string} string "WebVTT document"
+// @Failure 404 {string} string "Caption not found"
+// @Router /videos/{id}/captions/{captionId} [get]
+func GetCaption(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"]); !ok {
+		return
+	}
+	caption, err := findCaption(ctx, mux.Vars(r)["id"], mux.Vars(r)["captionId"])
+	if err != nil {
+		http.Error(w, "Caption not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	io.WriteString(w, caption.Content)
+}
+
+// DeleteCaption removes a caption track
+// @Summary Delete a caption track
+// @Description Deletes a caption track of a video
+// @Tags Captions
+// @Param id path string true "Video ID"
+// @Param captionId path string true "Caption ID"
+// @Success 204 {string} string "Caption deleted"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Caption not found"
+// @Router /videos/{id}/captions/{captionId} [delete]
+func DeleteCaption(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	caption, err := findCaption(ctx, mux.Vars(r)["id"], mux.Vars(r)["captionId"])
+	if err != nil {
+		http.Error(w, "Caption not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := config.DB.Collection("captions").DeleteOne(ctx, bson.M{"_id": caption.ID}); err != nil {
+		http.Error(w, "Failed to delete caption", http.StatusInternalServerError)
+		return
+	}
+	if err := refreshCaptionText(ctx, video.ID); err != nil {
+		log.Printf("Failed to index captions of video %s: %v", video.ID.Hex(), err)
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditCaptionDeleted, TargetType: "caption", TargetID: caption.ID.Hex(), Changes: auditChanges(caption, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSubtitlePlaylist serves the HLS media playlist wrapping a caption track
+// @Summary Get an HLS subtitle playlist
+// @Description Returns a single-segment HLS media playlist referencing the WebVTT track
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Param captionId path string true "Caption ID"
+// @Produce application/vnd.apple.mpegurl
+// @Success 200 {string} string "Subtitle playlist"
+// @Failure 404 {string} string "Caption not found"
+// @Router /videos/{id}/hls/captions/{captionId}.m3u8 [get]
+func GetSubtitlePlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"]); !ok {
+		return
+	}
+	caption, err := findCaption(ctx, mux.Vars(r)["id"], mux.Vars(r)["captionId"])
+	if err != nil {
+		http.Error(w, "Caption not found", http.StatusNotFound)
+		return
+	}
+
+	// The playlist lives under /hls/captions/, the track under /captions/
+	uri := "../../captions/" + caption.ID.Hex()
+	duration := time.Duration(caption.Duration * float64(time.Second))
+
+	w.Header().Set("Content-Type", media.ContentType("index.m3u8"))
+	io.WriteString(w, media.SubtitlePlaylist(uri, duration))
+}
+
+// findCaptions returns the caption tracks of a video ordered by language
+func findCaptions(ctx context.Context, videoID primitive.ObjectID) ([]models.Caption, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "language", Value: 1}}).SetProjection(bson.M{"content": 0})
+	cursor, err := config.DB.Collection("captions").Find(ctx, bson.M{"videoId": videoID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	captions := []models.Caption{}
+	if err := cursor.All(ctx, &captions); err != nil {
+		return nil, err
+	}
+	return captions, nil
+}
+
+// findCaption loads a caption track and checks that it belongs to the video
+func findCaption(ctx context.Context, videoID, captionID string) (models.Caption, error) {
+	var caption models.Caption
+	vid, err := primitive.ObjectIDFromHex(videoID)
+	if err != nil {
+		return caption, err
+	}
+	cid, err := primitive.ObjectIDFromHex(captionID)
+	if err != nil {
+		return caption, err
+	}
+	err = config.DB.Collection("captions").FindOne(ctx, bson.M{"_id": cid, "videoId": vid}).Decode(&caption)
+	return caption, err
+}
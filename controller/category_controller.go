@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListCategories returns the category tree with video counts
+// @Summary Get the category tree
+// @Description Returns the categories as a tree ordered by name. Counts only include videos the caller may see in listings.
+// @Tags Categories
+// @Produce json
+// @Success 200 {array} models.CategoryNode
+// @Router /categories [get]
+func ListCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.DB.Collection("categories").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Count the visible videos filed directly in each category
+	match := bson.M{"$and": bson.A{listableFilter(optionalUser(ctx, r)), bson.M{"categoryId": bson.M{"$exists": true}}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$categoryId", "count": bson.M{"$sum": 1}}}},
+	}
+	countCursor, err := config.DB.Collection("videos").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer countCursor.Close(ctx)
+
+	var counts []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int64              `bson:"count"`
+	}
+	if err := countCursor.All(ctx, &counts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	direct := make(map[primitive.ObjectID]int64, len(counts))
+	for _, count := range counts {
+		direct[count.ID] = count.Count
+	}
+
+	children := map[primitive.ObjectID][]models.Category{}
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var build func(category models.Category) models.CategoryNode
+	build = func(category models.Category) models.CategoryNode {
+		node := models.CategoryNode{Category: category, VideoCount: direct[category.ID], Children: []models.CategoryNode{}}
+		node.TotalCount = node.VideoCount
+		for _, child := range children[category.ID] {
+			childNode := build(child)
+			node.TotalCount += childNode.TotalCount
+			node.Children = append(node.Children, childNode)
+		}
+		return node
+	}
+	tree := []models.CategoryNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// CreateCategory adds a category to the tree
+// @Summary Create a category
+// @Description Creates a category, optionally below a parent (admin only). Names must be unique among siblings.
+// @Tags Categories
+// @Accept json
+// @Produce json
+// @Param category body models.CategoryInput true "Category"
+// @Success 201 {object} models.Category
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 409 {string} string "Category already exists"
+// @Router /categories [post]
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+
+	var input models.CategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Name == nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	category := models.Category{
+		Name:      strings.TrimSpace(*input.Name),
+		Slug:      models.NormalizeTag(*input.Name),
+		CreatedAt: time.Now(),
+	}
+	if category.Slug == "" {
+		http.Error(w, "Category name is required", http.StatusBadRequest)
+		return
+	}
+	if input.ParentID != nil && *input.ParentID != "" {
+		parent, err := findCategory(ctx, *input.ParentID)
+		if err != nil {
+			http.Error(w, "Parent category not found", http.StatusBadRequest)
+			return
+		}
+		category.ParentID = &parent.ID
+		category.Ancestors = append(parent.Ancestors, parent.ID)
+	}
+
+	result, err := config.DB.Collection("categories").InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create category", http.StatusInternalServerError)
+		return
+	}
+	category.ID = result.InsertedID.(primitive.ObjectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory renames a category or moves it in the tree
+// @Summary Rename or move a category
+// @Description Renames a category and/or moves it below another parent (admin only). An empty parentId moves it to the top level.
+// @Tags Categories
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID"
+// @Param category body models.CategoryInput true "Changes"
+// @Success 200 {object} models.Category
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Category not found"
+// @Failure 409 {string} string "Category already exists"
+// @Router /categories/{id} [put]
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+	category, err := findCategory(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	var input models.CategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	if input.Name != nil {
+		category.Name = strings.TrimSpace(*input.Name)
+		category.Slug = models.NormalizeTag(*input.Name)
+		if category.Slug == "" {
+			http.Error(w, "Category name is required", http.StatusBadRequest)
+			return
+		}
+		set["name"], set["slug"] = category.Name, category.Slug
+	}
+
+	moved := false
+	if input.ParentID != nil {
+		category.ParentID, category.Ancestors = nil, nil
+		if *input.ParentID != "" {
+			parent, err := findCategory(ctx, *input.ParentID)
+			if err != nil {
+				http.Error(w, "Parent category not found", http.StatusBadRequest)
+				return
+			}
+			// A category cannot move below itself or one of its descendants
+			if parent.ID == category.ID || containsID(parent.Ancestors, category.ID) {
+				http.Error(w, "A category cannot be moved below itself", http.StatusBadRequest)
+				return
+			}
+			category.ParentID = &parent.ID
+			category.Ancestors = append(parent.Ancestors, parent.ID)
+		}
+		if category.ParentID == nil {
+			unset["parentId"], unset["ancestors"] = "", ""
+		} else {
+			set["parentId"], set["ancestors"] = category.ParentID, category.Ancestors
+		}
+		moved = true
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		http.Error(w, "Nothing to change", http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err = config.DB.Collection("categories").UpdateOne(ctx, bson.M{"_id": category.ID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update category", http.StatusInternalServerError)
+		return
+	}
+	if moved {
+		if err := moveDescendants(ctx, category); err != nil {
+			http.Error(w, "Failed to move subcategories", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// DeleteCategory removes an empty branch of the tree
+// @Summary Delete a category
+// @Description Deletes a category without subcategories (admin only). Its videos become uncategorized.
+// @Tags Categories
+// @Param id path string true "Category ID"
+// @Success 204 {string} string "Category deleted"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Category not found"
+// @Failure 409 {string} string "Category has subcategories"
+// @Router /categories/{id} [delete]
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return
+	}
+	category, err := findCategory(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	children, err := config.DB.Collection("categories").CountDocuments(ctx, bson.M{"parentId": category.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if children > 0 {
+		http.Error(w, "Category has subcategories", http.StatusConflict)
+		return
+	}
+
+	if _, err := config.DB.Collection("videos").UpdateMany(ctx, bson.M{"categoryId": category.ID}, bson.M{"$unset": bson.M{"categoryId": ""}}); err != nil {
+		http.Error(w, "Failed to update videos", http.StatusInternalServerError)
+		return
+	}
+	if _, err := config.DB.Collection("categories").DeleteOne(ctx, bson.M{"_id": category.ID}); err != nil {
+		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: admin.ID, Action: models.AuditCategoryDeleted, TargetType: "category", TargetID: category.ID.Hex(), Changes: auditChanges(category, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetVideoCategory files a video in a category
+// @Summary Set video category
+// @Description Files a video in a category or, with an empty categoryId, removes it from its category (owner or admin only)
+// @Tags Categories
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param category body models.CategoryUpdate true "Category"
+// @Success 200 {object} models.CategoryUpdate
+// @Failure 400 {string} string "Category not found"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/category [put]
+func SetVideoCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var body models.CategoryUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"categoryId": ""}}
+	if body.CategoryID != "" {
+		category, err := findCategory(ctx, body.CategoryID)
+		if err != nil {
+			http.Error(w, "Category not found", http.StatusBadRequest)
+			return
+		}
+		update = bson.M{"$set": bson.M{"categoryId": category.ID}}
+	}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, update); err != nil {
+		http.Error(w, "Failed to update video", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// findCategory loads the category with the given hex ID
+func findCategory(ctx context.Context, id string) (models.Category, error) {
+	var category models.Category
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return category, err
+	}
+	err = config.DB.Collection("categories").FindOne(ctx, bson.M{"_id": objectID}).Decode(&category)
+	return category, err
+}
+
+// categoryScope returns the category and all of its descendants
+func categoryScope(ctx context.Context, id string) ([]primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := config.DB.Collection("categories").Distinct(ctx, "_id", bson.M{"ancestors": objectID})
+	if err != nil {
+		return nil, err
+	}
+	scope := []primitive.ObjectID{objectID}
+	for _, descendant := range descendants {
+		if id, ok := descendant.(primitive.ObjectID); ok {
+			scope = append(scope, id)
+		}
+	}
+	return scope, nil
+}
+
+// moveDescendants rewrites the ancestor paths below a category that moved
+func moveDescendants(ctx context.Context, category models.Category) error {
+	cursor, err := config.DB.Collection("categories").Find(ctx, bson.M{"ancestors": category.ID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var descendants []models.Category
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return err
+	}
+	prefix := append(append([]primitive.ObjectID{}, category.Ancestors...), category.ID)
+	for _, descendant := range descendants {
+		// Keep the part of the path below the moved category
+		below := descendant.Ancestors
+		for i, id := range below {
+			if id == category.ID {
+				below = below[i+1:]
+				break
+			}
+		}
+		ancestors := append(append([]primitive.ObjectID{}, prefix...), below...)
+		if _, err := config.DB.Collection("categories").UpdateOne(ctx, bson.M{"_id": descendant.ID}, bson.M{"$set": bson.M{"ancestors": ancestors}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,607 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// channelLatestCount is the number of uploads shown on a channel page
+const channelLatestCount = 12
+
+// imageTypes are the accepted avatar and banner formats
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imagesBucket returns the GridFS bucket holding channel images
+func imagesBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("images"))
+}
+
+// CreateChannel creates a channel owned by the caller or one of their teams
+// @Summary Create a channel
+// @Description Creates a channel. With a team, every member of that group can manage it; otherwise only the creator can.
+// @Tags Channels
+// @Accept json
+// @Produce json
+// @Param channel body models.ChannelInput true "Channel"
+// @Success 201 {object} models.Channel
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Not a member of the team"
+// @Failure 409 {string} string "Handle is taken"
+// @Router /channels [post]
+func CreateChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ChannelInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	channel := models.Channel{
+		Handle:    strings.ToLower(strings.TrimPrefix(strings.TrimSpace(input.Handle), "@")),
+		OwnerID:   user.ID,
+		CreatedAt: time.Now(),
+	}
+	if !models.ValidHandle(channel.Handle) {
+		http.Error(w, "Handles are 3 to 30 lower-case letters, digits, dots, dashes or underscores", http.StatusBadRequest)
+		return
+	}
+	channel.Name = channel.Handle
+	if input.Name != nil && strings.TrimSpace(*input.Name) != "" {
+		channel.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		channel.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Team != nil && *input.Team != "" {
+		if user.Role != models.RoleAdmin && !inGroup(user, *input.Team) {
+			http.Error(w, "Not a member of the team", http.StatusForbidden)
+			return
+		}
+		channel.Team = *input.Team
+	}
+
+	result, err := config.DB.Collection("channels").InsertOne(ctx, channel)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Handle is taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create channel", http.StatusInternalServerError)
+		return
+	}
+	channel.ID = result.InsertedID.(primitive.ObjectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channel)
+}
+
+// ListChannels lists channels
+// @Summary List channels
+// @Description Lists channels ordered by handle
+// @Tags Channels
+// @Param owner query string false "Only channels created by this user ID"
+// @Param team query string false "Only channels of this team"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of channels to skip"
+// @Produce json
+// @Success 200 {array} models.Channel
+// @Router /channels [get]
+func ListChannels(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		filter["ownerId"] = owner
+	}
+	if team := r.URL.Query().Get("team"); team != "" {
+		filter["team"] = team
+	}
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "handle", Value: 1}}).SetLimit(limit).SetSkip(offset)
+
+	cursor, err := config.DB.Collection("channels").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	channels := []models.Channel{}
+	if err := cursor.All(ctx, &channels); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+// GetChannel returns a channel and its latest uploads
+// @Summary Get a channel
+// @Description Resolves a handle to its channel and the newest videos on it the caller may see in listings
+// @Tags Channels
+// @Param handle path string true "Channel handle"
+// @Produce json
+// @Success 200 {object} models.ChannelPage
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle} [get]
+func GetChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(channelLatestCount)
+	videos, err := findChannelVideos(ctx, channel, optionalUser(ctx, r), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := models.ChannelPage{Channel: channel, Latest: make([]models.VideoSummary, 0, len(videos))}
+	for _, video := range videos {
+		page.Latest = append(page.Latest, summarizeVideo(video))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ListChannelVideos lists the videos of a channel
+// @Summary List channel videos
+// @Description Lists the videos on a channel the caller may see in listings, newest first
+// @Tags Channels
+// @Param handle path string true "Channel handle"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of videos to skip"
+// @Produce json
+// @Success 200 {array} models.Video
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/videos [get]
+func ListChannelVideos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	user := optionalUser(ctx, r)
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset)
+	videos, err := findChannelVideos(ctx, channel, user, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attachResumePositions(ctx, user, videos)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(videos)
+}
+
+// UpdateChannel changes the name, description or team of a channel
+// @Summary Update a channel
+// @Description Changes the name, description or team of a channel (managers only). The handle cannot change.
+// @Tags Channels
+// @Accept json
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Param channel body models.ChannelInput true "Changes"
+// @Success 200 {object} models.Channel
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Not allowed to manage this channel"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle} [put]
+func UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, user, ok := findManagedChannel(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ChannelInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	before := channel
+	set := bson.M{}
+	if input.Name != nil {
+		if strings.TrimSpace(*input.Name) == "" {
+			http.Error(w, "Channel name is required", http.StatusBadRequest)
+			return
+		}
+		channel.Name = strings.TrimSpace(*input.Name)
+		set["name"] = channel.Name
+	}
+	if input.Description != nil {
+		channel.Description = strings.TrimSpace(*input.Description)
+		set["description"] = channel.Description
+	}
+	if input.Team != nil {
+		if *input.Team != "" && user.Role != models.RoleAdmin && !inGroup(user, *input.Team) {
+			http.Error(w, "Not a member of the team", http.StatusForbidden)
+			return
+		}
+		channel.Team = *input.Team
+		set["team"] = channel.Team
+	}
+	if len(set) == 0 {
+		http.Error(w, "Nothing to change", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := config.DB.Collection("channels").UpdateOne(ctx, bson.M{"_id": channel.ID}, bson.M{"$set": set}); err != nil {
+		http.Error(w, "Failed to update channel", http.StatusInternalServerError)
+		return
+	}
+	if input.Team != nil && before.Team != channel.Team {
+		recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditChannelTeamChanged, TargetType: "channel", TargetID: channel.ID.Hex(), Changes: auditChanges(before, channel)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+// DeleteChannel removes a channel; its videos stay with their owners
+// @Summary Delete a channel
+// @Description Deletes a channel, its images and subscriptions (creator or admin only). Videos on it are kept but no longer published on a channel.
+// @Tags Channels
+// @Param handle path string true "Channel handle"
+// @Success 204 {string} string "Channel deleted"
+// @Failure 403 {string} string "Only the creator can delete a channel"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle} [delete]
+func DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, user, ok := findManagedChannel(ctx, w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && channel.OwnerID != user.ID {
+		http.Error(w, "Only the creator can delete a channel", http.StatusForbidden)
+		return
+	}
+
+	if _, err := config.DB.Collection("channels").DeleteOne(ctx, bson.M{"_id": channel.ID}); err != nil {
+		http.Error(w, "Failed to delete channel", http.StatusInternalServerError)
+		return
+	}
+	if _, err := config.DB.Collection("videos").UpdateMany(ctx, bson.M{"channelId": channel.ID}, bson.M{"$unset": bson.M{"channelId": ""}}); err != nil {
+		log.Printf("Failed to detach videos from channel %s: %v", channel.Handle, err)
+	}
+	removeSubscriptions(ctx, channel.ID)
+	for _, fileID := range []*primitive.ObjectID{channel.AvatarFileID, channel.BannerFileID} {
+		deleteImage(ctx, fileID)
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditChannelDeleted, TargetType: "channel", TargetID: channel.ID.Hex(), Changes: auditChanges(channel, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadChannelImage replaces the avatar or banner of a channel
+// @Summary Upload a channel avatar or banner
+// @Description Replaces the avatar or banner image of a channel (managers only). PNG, JPEG, GIF and WebP images are accepted.
+// @Tags Channels
+// @Accept multipart/form-data
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Param kind path string true "avatar or banner"
+// @Param image formData file true "Image file"
+// @Success 200 {object} models.Channel
+// @Failure 400 {string} string "Unsupported image type"
+// @Failure 403 {string} string "Not allowed to manage this channel"
+// @Failure 404 {string} string "Channel not found"
+// @Failure 413 {string} string "Image too large"
+// @Router /channels/{handle}/{kind} [put]
+func UploadChannelImage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	channel, _, ok := findManagedChannel(ctx, w, r)
+	if !ok {
+		return
+	}
+	kind := mux.Vars(r)["kind"]
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxImageBytes+1<<20)
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Unable to read image", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > config.MaxImageBytes {
+		http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Trust the bytes rather than the client's content type
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Unable to read image", http.StatusBadRequest)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !imageTypes[contentType] {
+		http.Error(w, "Unsupported image type", http.StatusBadRequest)
+		return
+	}
+
+	bucket, err := imagesBucket()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+	name := fmt.Sprintf("channels/%s/%s", channel.ID.Hex(), kind)
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": contentType})
+	fileID, err := bucket.UploadFromStream(name, bytes.NewReader(data), uploadOpts)
+	if err != nil {
+		http.Error(w, "Failed to save image", http.StatusInternalServerError)
+		return
+	}
+
+	field, previous := "avatarFileId", channel.AvatarFileID
+	if kind == "banner" {
+		field, previous = "bannerFileId", channel.BannerFileID
+	}
+	if _, err := config.DB.Collection("channels").UpdateOne(ctx, bson.M{"_id": channel.ID}, bson.M{"$set": bson.M{field: fileID}}); err != nil {
+		deleteImage(ctx, &fileID)
+		http.Error(w, "Failed to update channel", http.StatusInternalServerError)
+		return
+	}
+	deleteImage(ctx, previous)
+
+	if kind == "banner" {
+		channel.BannerFileID = &fileID
+	} else {
+		channel.AvatarFileID = &fileID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+// GetChannelImage serves the avatar or banner of a channel
+// @Summary Get a channel avatar or banner
+// @Description Returns the avatar or banner image of a channel
+// @Tags Channels
+// @Param handle path string true "Channel handle"
+// @Param kind path string true "avatar or banner"
+// @Produce image/png
+// @Success 200 {file} file "Image"
+// @Failure 404 {string} string "Image not found"
+// @Router /channels/{handle}/{kind} [get]
+func GetChannelImage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	fileID := channel.AvatarFileID
+	if mux.Vars(r)["kind"] == "banner" {
+		fileID = channel.BannerFileID
+	}
+	if fileID == nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	// Each upload gets a new file, so the image behind a file ID never changes
+	etag := `"` + fileID.Hex() + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	bucket, err := imagesBucket()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+	downloadStream, err := bucket.OpenDownloadStream(*fileID)
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	defer downloadStream.Close()
+
+	var metadata struct {
+		ContentType string `bson:"contentType"`
+	}
+	if raw := downloadStream.GetFile().Metadata; raw != nil {
+		bson.Unmarshal(raw, &metadata)
+	}
+	if metadata.ContentType != "" {
+		w.Header().Set("Content-Type", metadata.ContentType)
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if _, err := io.Copy(w, downloadStream); err != nil {
+		log.Printf("Failed to stream image %s: %v", fileID.Hex(), err)
+	}
+}
+
+// SetVideoChannel publishes a video on a channel
+// @Summary Set video channel
+// @Description Publishes a video on a channel the caller manages or, with an empty channelId, removes it from its channel (video owner or admin only)
+// @Tags Channels
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param channel body models.ChannelUpdate true "Channel"
+// @Success 200 {object} models.ChannelUpdate
+// @Failure 400 {string} string "Channel not found"
+// @Failure 403 {string} string "Not allowed to manage this channel"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/channel [put]
+func SetVideoChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var body models.ChannelUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"channelId": ""}}
+	if body.ChannelID != "" {
+		channel, err := findChannelByID(ctx, body.ChannelID)
+		if err != nil {
+			http.Error(w, "Channel not found", http.StatusBadRequest)
+			return
+		}
+		if !canManageChannel(channel, &user) {
+			http.Error(w, "Not allowed to manage this channel", http.StatusForbidden)
+			return
+		}
+		update = bson.M{"$set": bson.M{"channelId": channel.ID}}
+	}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, update); err != nil {
+		http.Error(w, "Failed to update video", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// findChannel loads a channel by handle
+func findChannel(ctx context.Context, handle string) (models.Channel, error) {
+	var channel models.Channel
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	err := config.DB.Collection("channels").FindOne(ctx, bson.M{"handle": handle}).Decode(&channel)
+	return channel, err
+}
+
+// findChannelByID loads the channel with the given hex ID
+func findChannelByID(ctx context.Context, id string) (models.Channel, error) {
+	var channel models.Channel
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return channel, err
+	}
+	err = config.DB.Collection("channels").FindOne(ctx, bson.M{"_id": objectID}).Decode(&channel)
+	return channel, err
+}
+
+// findManagedChannel loads the channel of the request and checks the requester manages it
+func findManagedChannel(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.Channel, models.User, bool) {
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return models.Channel{}, user, false
+	}
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return channel, user, false
+	}
+	if !canManageChannel(channel, &user) {
+		http.Error(w, "Not allowed to manage this channel", http.StatusForbidden)
+		return channel, user, false
+	}
+	return channel, user, true
+}
+
+// canManageChannel reports whether the user may change a channel and publish on it
+func canManageChannel(channel models.Channel, user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	return user.Role == models.RoleAdmin || channel.OwnerID == user.ID || (channel.Team != "" && inGroup(*user, channel.Team))
+}
+
+// inGroup reports whether the user belongs to the group
+func inGroup(user models.User, group string) bool {
+	for _, member := range user.Groups {
+		if member == group {
+			return true
+		}
+	}
+	return false
+}
+
+// findChannelVideos returns the videos of a channel the user may see in listings
+func findChannelVideos(ctx context.Context, channel models.Channel, user *models.User, opts *options.FindOptions) ([]models.Video, error) {
+	filter := bson.M{"$and": bson.A{bson.M{"channelId": channel.ID}, listableFilter(user)}}
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	videos := []models.Video{}
+	if err := cursor.All(ctx, &videos); err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+// deleteImage removes a replaced or orphaned channel image
+func deleteImage(ctx context.Context, fileID *primitive.ObjectID) {
+	if fileID == nil {
+		return
+	}
+	bucket, err := imagesBucket()
+	if err == nil {
+		err = bucket.DeleteContext(ctx, *fileID)
+	}
+	if err != nil {
+		log.Printf("Failed to delete image %s: %v", fileID.Hex(), err)
+	}
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"hub/config"
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetChapters returns the chapters of a video
+// @Summary Get video chapters
+// @Description Returns the chapter markers of a video ordered by start time
+// @Tags Chapters
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {array} models.Chapter
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/chapters [get]
+func GetChapters(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	chapters := video.Chapters
+	if chapters == nil {
+		chapters = []models.Chapter{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chapters)
+}
+
+// UpdateChapters replaces the chapters of a video
+// @Summary Replace video chapters
+// @Description Replaces the chapter markers of a video. Chapters must be ordered and start within the probed duration.
+// @Tags Chapters
+// @Accept json
+// @Param id path string true "Video ID"
+// @Param chapters body []models.Chapter true "Chapters"
+// @Produce json
+// @Success 200 {array} models.Chapter
+// @Failure 400 {string} string "Invalid chapters"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/chapters [put]
+func UpdateChapters(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var chapters []models.Chapter
+	if err := json.NewDecoder(r.Body).Decode(&chapters); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := media.ValidateChapters(chapters, video.Duration); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid chapters: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := setChapters(ctx, video.ID, chapters); err != nil {
+		http.Error(w, "Failed to update chapters", http.StatusInternalServerError)
+		return
+	}
+
+	if chapters == nil {
+		chapters = []models.Chapter{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chapters)
+}
+
+// ImportChapters replaces the chapters of a video with those of its MP4 chapter track
+// @Summary Import chapters from the video file
+// @Description Reads the chapter track of the stored video file with ffprobe and stores it as the video's chapters
+// @Tags Chapters
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {array} models.Chapter
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Failure 422 {string} string "Video file has no chapters"
+// @Router /videos/{id}/chapters/import [post]
+func ImportChapters(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	// Probing downloads the source, so it gets a longer deadline than the lookup
+	probeCtx, probeCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer probeCancel()
+
+	result, err := probeSource(probeCtx, video)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to probe video: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(result.Chapters) == 0 {
+		http.Error(w, "Video file has no chapters", http.StatusUnprocessableEntity)
+		return
+	}
+	if err := media.ValidateChapters(result.Chapters, result.Duration); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid chapters in video file: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	update := bson.M{"chapters": result.Chapters, "duration": result.Duration}
+	if _, err := config.DB.Collection("videos").UpdateOne(probeCtx, bson.M{"_id": video.ID}, bson.M{"$set": update}); err != nil {
+		http.Error(w, "Failed to update chapters", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result.Chapters)
+}
+
+// GetChaptersVTT exports the chapters of a video as a WebVTT chapters track
+// @Summary Export chapters as WebVTT
+// @Description Returns the chapters of a video as a WebVTT chapters track
+// @Tags Chapters
+// @Param id path string true "Video ID"
+// @Produce text/vtt
+// @Success 200 {string} string "WebVTT chapters"
+// @Failure 404 {string} string "Video has no chapters"
+// @Failure 409 {string} string "Video duration is not known yet"
+// @Router /videos/{id}/chapters.vtt [get]
+func GetChaptersVTT(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if len(video.Chapters) == 0 {
+		http.Error(w, "Video has no chapters", http.StatusNotFound)
+		return
+	}
+
+	// The last chapter ends with the video, so the duration has to be known
+	if video.Duration == 0 {
+		http.Error(w, "Video duration is not known yet", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	io.WriteString(w, media.RenderChaptersVTT(video.Chapters, video.Duration))
+}
+
+// setChapters stores the chapters of a video
+func setChapters(ctx context.Context, videoID primitive.ObjectID, chapters []models.Chapter) error {
+	update := bson.M{"$set": bson.M{"chapters": chapters}}
+	if len(chapters) == 0 {
+		update = bson.M{"$unset": bson.M{"chapters": ""}}
+	}
+	_, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": videoID}, update)
+	return err
+}
+
+// probeSource downloads the source file of a video and runs ffprobe on it. The
+// file must be seekable: ffprobe only reads MP4 chapter tracks from seekable input.
+func probeSource(ctx context.Context, video models.Video) (media.ProbeResult, error) {
+	workDir, err := os.MkdirTemp("", "hub-probe-")
+	if err != nil {
+		return media.ProbeResult{}, err
+	}
+	defer os.RemoveAll(workDir)
+
+	source := filepath.Join(workDir, "source")
+	if err := downloadSource(ctx, video.FileID, source); err != nil {
+		return media.ProbeResult{}, err
+	}
+	return media.Probe(ctx, source)
+}
+
+// probeVideo records the duration of a new upload and imports its MP4 chapters
+// when the description did not provide any. Chapters from the description that
+// run past the probed duration are dropped.
+func probeVideo(video models.Video) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result, err := probeSource(ctx, video)
+	if err != nil {
+		log.Printf("Probing video %s failed: %v", video.ID.Hex(), err)
+		return
+	}
+
+	set := bson.M{"duration": result.Duration}
+	update := bson.M{"$set": set}
+	switch {
+	case len(video.Chapters) > 0 && media.ValidateChapters(video.Chapters, result.Duration) != nil:
+		update["$unset"] = bson.M{"chapters": ""}
+	case len(video.Chapters) == 0 && len(result.Chapters) > 0 && media.ValidateChapters(result.Chapters, result.Duration) == nil:
+		set["chapters"] = result.Chapters
+	}
+
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, update); err != nil {
+		log.Printf("Failed to store probe result of video %s: %v", video.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// chapterTrackFile encodes a short MP4 whose chapters are stored in a QuickTime
+// chapter track, with the index at the end of the file as ffmpeg writes it
+func chapterTrackFile(t *testing.T) string {
+	t.Helper()
+	for _, tool := range []string{config.FFmpegPath, config.FFprobePath} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	dir := t.TempDir()
+	metadata := filepath.Join(dir, "chapters.txt")
+	err := os.WriteFile(metadata, []byte(";FFMETADATA1\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=2000\ntitle=Intro\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=2000\nEND=4000\ntitle=Main part\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "chapters.mp4")
+	cmd := exec.Command(config.FFmpegPath, "-v", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=4:size=64x64:rate=10",
+		"-f", "ffmetadata", "-i", metadata, "-map", "0", "-map_chapters", "1",
+		"-c:v", "mpeg4", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg: %v: %s", err, out)
+	}
+	return output
+}
+
+func TestImportChapters(t *testing.T) {
+	source := chapterTrackFile(t)
+	useTestDB(t)
+	owner, token := testUser(t, models.RoleUser)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fileID, err := bucket.UploadFromStream("chapters.mp4", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := models.Video{ID: primitive.NewObjectID(), Title: "Chapters", FileID: fileID, OwnerID: owner.ID, Visibility: models.VisibilityPublic}
+	if _, err := config.DB.Collection("videos").InsertOne(ctx, video); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/videos/"+video.ID.Hex()+"/chapters/import", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = mux.SetURLVars(r, map[string]string{"id": video.ID.Hex()})
+	w := httptest.NewRecorder()
+	ImportChapters(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var chapters []models.Chapter
+	if err := json.NewDecoder(w.Body).Decode(&chapters); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Chapter{{Start: 0, Title: "Intro"}, {Start: 2, Title: "Main part"}}
+	if len(chapters) != len(want) {
+		t.Fatalf("chapters = %+v, want %+v", chapters, want)
+	}
+	for i := range want {
+		if chapters[i] != want[i] {
+			t.Errorf("chapter %d = %+v, want %+v", i+1, chapters[i], want[i])
+		}
+	}
+
+	stored, err := findVideo(ctx, video.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Chapters) != len(want) || stored.Duration < 3.9 {
+		t.Errorf("stored chapters %+v and duration %v", stored.Chapters, stored.Duration)
+	}
+}
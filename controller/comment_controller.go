@@ -0,0 +1,569 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxCommentLength limits the size of a comment body in characters
+	maxCommentLength = 5000
+
+	// threadReplyPreview is the number of replies embedded in each listed thread
+	threadReplyPreview = 3
+)
+
+// ListComments lists the comment threads of a video
+// @Summary List comments
+// @Description Lists the top-level comments of a video, pinned first and then newest first, each with its first replies.
+// @Description Hidden comments are only shown to their author and moderators.
+// @Tags Comments
+// @Param id path string true "Video ID"
+// @Param limit query int false "Threads per page (default 20, max 100)"
+// @Param offset query int false "Number of threads to skip"
+// @Produce json
+// @Success 200 {array} models.CommentThread
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/comments [get]
+func ListComments(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"videoId": video.ID, "parentId": bson.M{"$exists": false}}
+	addHiddenFilter(filter, video, user)
+	limit, offset := pagination(r)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}}).
+		SetLimit(limit).SetSkip(offset)
+
+	roots, err := findComments(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	previews, err := threadPreviews(ctx, roots, video, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	threads := make([]models.CommentThread, 0, len(roots))
+	for _, root := range roots {
+		thread := previews[root.ID]
+		thread.Comment = root
+		if thread.Replies == nil {
+			thread.Replies = []models.Comment{}
+		}
+		threads = append(threads, thread)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threads)
+}
+
+// ListReplies lists the replies of a comment thread
+// @Summary List thread replies
+// @Description Lists the replies of a thread, oldest first
+// @Tags Comments
+// @Param id path string true "Top-level comment ID"
+// @Param limit query int false "Replies per page (default 20, max 100)"
+// @Param offset query int false "Number of replies to skip"
+// @Produce json
+// @Success 200 {array} models.Comment
+// @Failure 404 {string} string "Comment not found"
+// @Router /comments/{id}/replies [get]
+func ListReplies(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	comment, video, user, ok := findViewableComment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"threadId": comment.ThreadID, "parentId": bson.M{"$exists": true}}
+	addHiddenFilter(filter, video, user)
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit).SetSkip(offset)
+
+	replies, err := findComments(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replies)
+}
+
+// CreateComment posts a comment or a reply on a video
+// @Summary Post a comment
+// @Description Posts a top-level comment, or a reply when parentId is set. A timestamp anchors the comment to a position in the video.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param comment body models.CommentInput true "Comment"
+// @Success 201 {object} models.Comment
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Comments are disabled"
+// @Failure 429 {string} string "Too many comments"
+// @Router /videos/{id}/comments [post]
+func CreateComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if video.CommentsDisabled {
+		http.Error(w, "Comments are disabled", http.StatusForbidden)
+		return
+	}
+
+	var input models.CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	body, err := validateCommentBody(input.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.Timestamp != nil && (*input.Timestamp < 0 || (video.Duration > 0 && *input.Timestamp > video.Duration)) {
+		http.Error(w, "Timestamp is outside the video", http.StatusBadRequest)
+		return
+	}
+
+	if limited, err := commentRateLimited(ctx, user.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if limited {
+		w.Header().Set("Retry-After", fmt.Sprint(int(config.CommentRateWindow.Seconds())))
+		http.Error(w, "Too many comments", http.StatusTooManyRequests)
+		return
+	}
+
+	comment := models.Comment{
+		ID:         primitive.NewObjectID(),
+		VideoID:    video.ID,
+		AuthorID:   user.ID,
+		AuthorName: user.Name,
+		Body:       body,
+		Timestamp:  input.Timestamp,
+		CreatedAt:  time.Now(),
+	}
+	comment.ThreadID = comment.ID
+
+	// Replies join the thread of the comment they answer
+	var parent *models.Comment
+	if input.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(input.ParentID)
+		if err != nil {
+			http.Error(w, "Invalid parent comment", http.StatusBadRequest)
+			return
+		}
+		parent = &models.Comment{}
+		err = config.DB.Collection("comments").FindOne(ctx, bson.M{"_id": parentID, "videoId": video.ID}).Decode(parent)
+		if err != nil || parent.Deleted {
+			http.Error(w, "Parent comment not found", http.StatusBadRequest)
+			return
+		}
+		comment.ParentID = &parent.ID
+		comment.ThreadID = parent.ThreadID
+	}
+
+	if _, err := config.DB.Collection("comments").InsertOne(ctx, comment); err != nil {
+		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
+		return
+	}
+	go notifyComment(video, comment, parent)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// UpdateComment edits the body of a comment, keeping the previous version in its history
+// @Summary Edit a comment
+// @Description Replaces the body of the caller's comment. Earlier versions are kept in the edit history.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Param comment body models.CommentInput true "New body"
+// @Success 200 {object} models.Comment
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Only the author can edit a comment"
+// @Failure 404 {string} string "Comment not found"
+// @Router /comments/{id} [put]
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	comment, _, _, ok := findViewableComment(ctx, w, r)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID {
+		http.Error(w, "Only the author can edit a comment", http.StatusForbidden)
+		return
+	}
+	if comment.Deleted {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	var input models.CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	body, err := validateCommentBody(input.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	revision := models.CommentRevision{Body: comment.Body, EditedAt: now}
+	update := bson.M{
+		"$set":  bson.M{"body": body, "editedAt": now},
+		"$push": bson.M{"history": revision},
+	}
+	// Match the body too so two concurrent edits cannot lose a revision
+	filter := bson.M{"_id": comment.ID, "body": comment.Body}
+	result, err := config.DB.Collection("comments").UpdateOne(ctx, filter, update)
+	if err != nil {
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Comment was changed concurrently", http.StatusConflict)
+		return
+	}
+
+	comment.Body = body
+	comment.EditedAt = &now
+	comment.History = append(comment.History, revision)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DeleteComment removes a comment; replies stay in their thread
+// @Summary Delete a comment
+// @Description Deletes a comment (author, video owner or admin). The comment is blanked so its replies stay threaded.
+// @Tags Comments
+// @Param id path string true "Comment ID"
+// @Success 204 {string} string "Comment deleted"
+// @Failure 403 {string} string "Not allowed to delete this comment"
+// @Failure 404 {string} string "Comment not found"
+// @Router /comments/{id} [delete]
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	comment, video, _, ok := findViewableComment(ctx, w, r)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID && !isModerator(video, &user) {
+		http.Error(w, "Not allowed to delete this comment", http.StatusForbidden)
+		return
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"deleted": true, "body": "", "pinned": false},
+		"$unset": bson.M{"history": ""},
+	}
+	if _, err := config.DB.Collection("comments").UpdateOne(ctx, bson.M{"_id": comment.ID}, update); err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditCommentDeleted, TargetType: "comment", TargetID: comment.ID.Hex(), Changes: auditChanges(comment, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ModerateComment hides or pins a comment
+// @Summary Moderate a comment
+// @Description Hides, unhides, pins or unpins a comment (video owner or admin). Only top-level comments can be pinned.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Param moderation body models.CommentModeration true "Moderation flags"
+// @Success 200 {object} models.Comment
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Only the video owner can moderate comments"
+// @Failure 404 {string} string "Comment not found"
+// @Router /comments/{id}/moderation [put]
+func ModerateComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	comment, video, _, ok := findViewableComment(ctx, w, r)
+	if !ok {
+		return
+	}
+	if !isModerator(video, &user) {
+		http.Error(w, "Only the video owner can moderate comments", http.StatusForbidden)
+		return
+	}
+
+	var input models.CommentModeration
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || (input.Hidden == nil && input.Pinned == nil) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	before := comment
+	set := bson.M{}
+	if input.Hidden != nil {
+		set["hidden"] = *input.Hidden
+		comment.Hidden = *input.Hidden
+	}
+	if input.Pinned != nil {
+		if *input.Pinned && comment.ParentID != nil {
+			http.Error(w, "Only top-level comments can be pinned", http.StatusBadRequest)
+			return
+		}
+		set["pinned"] = *input.Pinned
+		comment.Pinned = *input.Pinned
+	}
+
+	// A video has at most one pinned comment
+	if comment.Pinned && input.Pinned != nil {
+		_, err := config.DB.Collection("comments").UpdateMany(ctx, bson.M{"videoId": video.ID, "pinned": true}, bson.M{"$set": bson.M{"pinned": false}})
+		if err != nil {
+			http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := config.DB.Collection("comments").UpdateOne(ctx, bson.M{"_id": comment.ID}, bson.M{"$set": set}); err != nil {
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditCommentModerated, TargetType: "comment", TargetID: comment.ID.Hex(), Changes: auditChanges(before, comment)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// SetCommentSettings turns comments on a video on or off
+// @Summary Enable or disable comments
+// @Description Turns comments on a video on or off (owner or admin only). Existing comments stay visible.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param settings body models.CommentSettings true "Comment settings"
+// @Success 200 {object} models.CommentSettings
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/comments/settings [put]
+func SetCommentSettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var settings models.CommentSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, bson.M{"$set": bson.M{"commentsDisabled": settings.Disabled}}); err != nil {
+		http.Error(w, "Failed to update video", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// validateCommentBody trims a comment body and checks its length
+func validateCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("Comment body is required")
+	}
+	if len([]rune(body)) > maxCommentLength {
+		return "", fmt.Errorf("Comment is longer than %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// commentRateLimited takes one comment from the user's allowance for the current
+// window and reports whether it was already used up. The allowance is a counter
+// per user and window, so concurrent posts cannot all pass the check.
+func commentRateLimited(ctx context.Context, userID string) (bool, error) {
+	if config.CommentRateLimit <= 0 || config.CommentRateWindow <= 0 {
+		return false, nil
+	}
+	window := time.Now().Truncate(config.CommentRateWindow)
+	filter := bson.M{"_id": fmt.Sprintf("%s:%d", userID, window.Unix())}
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expiresAt": window.Add(config.CommentRateWindow)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := config.DB.Collection("comment_rates").FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another post created the counter first; it exists now
+		err = config.DB.Collection("comment_rates").FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return false, err
+	}
+	return counter.Count > config.CommentRateLimit, nil
+}
+
+// isModerator reports whether the user moderates comments on the video
+func isModerator(video models.Video, user *models.User) bool {
+	return user != nil && (user.Role == models.RoleAdmin || (video.OwnerID != "" && video.OwnerID == user.ID))
+}
+
+// addHiddenFilter limits a comment query to comments the user may see.
+// Moderators see hidden comments, authors see their own.
+func addHiddenFilter(filter bson.M, video models.Video, user *models.User) {
+	if isModerator(video, user) {
+		return
+	}
+	if user == nil {
+		filter["hidden"] = bson.M{"$ne": true}
+		return
+	}
+	filter["$or"] = bson.A{bson.M{"hidden": bson.M{"$ne": true}}, bson.M{"authorId": user.ID}}
+}
+
+// threadPreviews counts the replies of the given threads and collects the first
+// of them in a single aggregation, keyed by thread
+func threadPreviews(ctx context.Context, roots []models.Comment, video models.Video, user *models.User) (map[primitive.ObjectID]models.CommentThread, error) {
+	previews := map[primitive.ObjectID]models.CommentThread{}
+	if len(roots) == 0 {
+		return previews, nil
+	}
+	rootIDs := make([]primitive.ObjectID, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+
+	filter := bson.M{"threadId": bson.M{"$in": rootIDs}, "parentId": bson.M{"$exists": true}}
+	addHiddenFilter(filter, video, user)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$threadId",
+			"count":   bson.M{"$sum": 1},
+			"replies": bson.M{"$push": "$$ROOT"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"count":   1,
+			"replies": bson.M{"$slice": bson.A{"$replies", threadReplyPreview}},
+		}}},
+	}
+	cursor, err := config.DB.Collection("comments").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var row struct {
+			ThreadID primitive.ObjectID `bson:"_id"`
+			Count    int64              `bson:"count"`
+			Replies  []models.Comment   `bson:"replies"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		previews[row.ThreadID] = models.CommentThread{Replies: row.Replies, ReplyCount: row.Count}
+	}
+	return previews, cursor.Err()
+}
+
+// findComments runs a comment query
+func findComments(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Comment, error) {
+	cursor, err := config.DB.Collection("comments").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := []models.Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// findViewableComment loads the comment of the request together with its video,
+// checking that the requester may see both
+func findViewableComment(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.Comment, models.Video, *models.User, bool) {
+	var comment models.Comment
+	commentID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err == nil {
+		err = config.DB.Collection("comments").FindOne(ctx, bson.M{"_id": commentID}).Decode(&comment)
+	}
+	if err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return comment, models.Video{}, nil, false
+	}
+
+	user := optionalUser(ctx, r)
+	video, err := findVideo(ctx, comment.VideoID.Hex())
+	if err != nil || !canView(video, user) {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return comment, video, user, false
+	}
+	if comment.Hidden && !isModerator(video, user) && (user == nil || user.ID != comment.AuthorID) {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return comment, video, user, false
+	}
+	return comment, video, user, true
+}
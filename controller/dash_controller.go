@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetDASHManifest serves an MPEG-DASH manifest for the packaged fMP4 renditions
+// @Summary Get DASH manifest
+// @Description Returns an MPD manifest referencing the fragmented-MP4 renditions stored for HLS
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Produce application/dash+xml
+// @Success 200 {string} string "MPD manifest"
+// @Failure 404 {string} string "No DASH renditions available"
+// @Router /videos/{id}/dash/manifest.mpd [get]
+func GetDASHManifest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	videoID := mux.Vars(r)["id"]
+	video, user, ok := findViewableVideo(ctx, w, r, videoID)
+	if !ok {
+		return
+	}
+
+	// Reuse the media playlists written by HLS packaging to learn the segment timeline
+	var representations []media.Representation
+	for _, rendition := range video.Renditions {
+		if rendition.Status != models.RenditionReady {
+			continue
+		}
+		data, err := readRenditionFile(videoID, rendition.Name, "index.m3u8")
+		if err != nil {
+			log.Printf("Failed to read playlist of %s/%s: %v", videoID, rendition.Name, err)
+			continue
+		}
+		playlist, err := media.ParseMediaPlaylist(data)
+		if err != nil {
+			// Renditions packaged as MPEG-TS cannot be described in DASH
+			continue
+		}
+		representations = append(representations, media.Representation{
+			ID:        rendition.Name,
+			Width:     rendition.Width,
+			Height:    rendition.Height,
+			Bandwidth: rendition.Bandwidth,
+			Codecs:    rendition.Codecs,
+			Playlist:  playlist,
+		})
+	}
+
+	if len(representations) == 0 {
+		http.Error(w, "No DASH renditions available", http.StatusNotFound)
+		return
+	}
+
+	manifest, err := media.DASHManifest(representations)
+	if err != nil {
+		http.Error(w, "Failed to build DASH manifest", http.StatusInternalServerError)
+		return
+	}
+	recordView(r, video.ID, user)
+
+	w.Header().Set("Content-Type", "application/dash+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, manifest)
+}
+
+// GetDASHFile serves an init or media segment referenced by the DASH manifest
+// @Summary Get a DASH segment
+// @Description Streams an init or media segment of a packaged rendition
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Param rendition path string true "Rendition name"
+// @Param file path string true "Segment file name"
+// @Success 200 {file} file "Segment"
+// @Failure 404 {string} string "File not found"
+// @Router /videos/{id}/dash/{rendition}/{file} [get]
+func GetDASHFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serveRenditionFile(w, r, vars["id"], vars["rendition"], vars["file"])
+}
+
+// readRenditionFile returns the contents of a small stored rendition file such as a playlist
+func readRenditionFile(videoID, rendition, file string) (string, error) {
+	bucket, err := renditionBucket()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if _, err := bucket.DownloadToStreamByName(path.Join(videoID, rendition, file), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,549 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"time"
+
+	"hub/config"
+	"hub/models"
+	"hub/signing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dataExportWake starts an export round before the next poll
+var dataExportWake = make(chan struct{}, 1)
+
+// exportsBucket returns the GridFS bucket holding data export archives
+func exportsBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("exports"))
+}
+
+// RequestDataExport starts an export of the caller's data
+// @Summary Request a data export
+// @Description Starts collecting the caller's profile, videos with their files, comments, playlists, watch history and reactions into a ZIP archive.
+// @Description The user is notified when it is ready; the export then carries a signed download link until it expires.
+// @Tags Users
+// @Produce json
+// @Success 202 {object} models.DataExport
+// @Failure 401 {string} string "Authentication required"
+// @Failure 409 {string} string "An export is already in progress"
+// @Router /users/me/exports [post]
+func RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	startDataExport(ctx, w, r, user, user)
+}
+
+// RequestUserDataExport starts an export of another user's data
+// @Summary Request a data export for a user
+// @Description Starts a data export on behalf of a user (admin only). The archive is delivered to that user like a self-service export.
+// @Tags Users
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 202 {object} models.DataExport
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "An export is already in progress"
+// @Router /users/{id}/exports [post]
+func RequestUserDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return
+	}
+	user, err := findUser(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	startDataExport(ctx, w, r, admin, user)
+}
+
+// startDataExport queues an export of the user's data unless one is in progress
+func startDataExport(ctx context.Context, w http.ResponseWriter, r *http.Request, actor, user models.User) {
+	active := bson.M{"userId": user.ID, "status": bson.M{"$in": bson.A{models.DataExportPending, models.DataExportRunning}}}
+	if err := config.DB.Collection("data_exports").FindOne(ctx, active).Err(); err == nil {
+		http.Error(w, "An export is already in progress", http.StatusConflict)
+		return
+	} else if err != mongo.ErrNoDocuments {
+		http.Error(w, "Failed to start export", http.StatusInternalServerError)
+		return
+	}
+
+	job := models.DataExport{
+		ID:          primitive.NewObjectID(),
+		UserID:      user.ID,
+		RequestedBy: actor.ID,
+		Status:      models.DataExportPending,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := config.DB.Collection("data_exports").InsertOne(ctx, job); err != nil {
+		http.Error(w, "Failed to start export", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: actor.ID, Action: models.AuditDataExportRequested, TargetType: "user", TargetID: user.ID})
+	select {
+	case dataExportWake <- struct{}{}:
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListDataExports lists the caller's data exports
+// @Summary List my data exports
+// @Description Lists the caller's data exports, newest first. Ready exports carry a signed download link.
+// @Tags Users
+// @Produce json
+// @Success 200 {array} models.DataExport
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/exports [get]
+func ListDataExports(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(20)
+	cursor, err := config.DB.Collection("data_exports").Find(ctx, bson.M{"userId": user.ID}, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []models.DataExport{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range jobs {
+		if jobs[i].Status == models.DataExportReady && jobs[i].FileID != nil && jobs[i].ExpiresAt != nil {
+			jobs[i].DownloadURL = dataExportURL(r, jobs[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// dataExportURL signs the download link of a ready export. The grant names the
+// export as its resource and the archive as its file, and expires with the archive.
+func dataExportURL(r *http.Request, job models.DataExport) string {
+	grant := signing.Grant{VideoID: job.ID.Hex(), FileID: job.FileID.Hex(), Expires: *job.ExpiresAt}
+	query := signing.Sign(grant, config.SigningKeyID, config.SigningKeys[config.SigningKeyID])
+	return requestBaseURL(r) + "/api/v1/exports/" + grant.VideoID + "/download?" + query.Encode()
+}
+
+// DownloadDataExport serves an export archive through its signed link
+// @Summary Download a data export
+// @Description Streams the ZIP archive of a data export. The signed link from the export listing authorises the download.
+// @Tags Users
+// @Param id path string true "Export ID"
+// @Param sig query string true "Signature of the download link"
+// @Produce application/zip
+// @Success 200 {file} file "Archive"
+// @Failure 403 {string} string "Invalid download link"
+// @Failure 404 {string} string "Export not found"
+// @Router /exports/{id}/download [get]
+func DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id := mux.Vars(r)["id"]
+	grant, err := signing.Verify(r.URL.Query(), id, net.ParseIP(clientIP(r)), config.SigningKeys, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid download link: %v", err), http.StatusForbidden)
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	var job models.DataExport
+	err = config.DB.Collection("data_exports").FindOne(ctx, bson.M{"_id": objectID}).Decode(&job)
+	if err != nil || job.Status != models.DataExportReady || job.FileID == nil || job.FileID.Hex() != grant.FileID {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+
+	bucket, err := exportsBucket()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+	downloadStream, err := bucket.OpenDownloadStream(*job.FileID)
+	if err != nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	file := downloadStream.GetFile()
+	content := &gridfsReadSeeker{bucket: bucket, id: *job.FileID, size: file.Length, chunkSize: int64(file.ChunkSize), stream: downloadStream}
+	defer content.Close()
+
+	// The link may have been passed on, so the download is recorded without an actor
+	if startsPlayback(r) {
+		recordAudit(ctx, r, models.AuditEntry{Action: models.AuditDataExportDownloaded, TargetType: "data_export", TargetID: job.ID.Hex()})
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="hub-data-`+job.CreatedAt.Format("20060102")+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", file.UploadDate, content)
+}
+
+// StartDataExports builds requested exports and deletes expired archives in the background
+func StartDataExports() {
+	go func() {
+		ticker := time.NewTicker(config.DataExportPollInterval)
+		defer ticker.Stop()
+		for {
+			runDataExports()
+			expireDataExports()
+			select {
+			case <-ticker.C:
+			case <-dataExportWake:
+			}
+		}
+	}()
+}
+
+// runDataExports builds every requested export
+func runDataExports() {
+	for {
+		job, ok := claimDataExport()
+		if !ok {
+			return
+		}
+		buildDataExport(job)
+	}
+}
+
+// claimDataExport leases the oldest requested export, or one whose worker stopped
+func claimDataExport() (models.DataExport, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var job models.DataExport
+	now := time.Now()
+	lease := now.Add(config.DataExportLease)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.DataExportPending},
+		bson.M{"status": models.DataExportRunning, "leaseUntil": bson.M{"$lte": now}},
+	}}
+	// A fresh token per claim tells a worker whose lease was taken over that its result is stale
+	update := bson.M{"$set": bson.M{"status": models.DataExportRunning, "leaseUntil": lease, "leaseToken": primitive.NewObjectID().Hex()}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetReturnDocument(options.After)
+	err := config.DB.Collection("data_exports").FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to claim data export: %v", err)
+		}
+		return job, false
+	}
+	return job, true
+}
+
+// buildDataExport writes the archive of a claimed export and tells the user it is ready.
+// The lease is renewed while the archive is written, so large exports are not taken
+// over by another worker. The result is only stored while the job still holds its
+// lease; otherwise the archive written here is discarded.
+func buildDataExport(job models.DataExport) {
+	buildCtx, stopBuild := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go renewDataExportLease(job, stopBuild, done)
+	fileID, size, err := writeDataExport(buildCtx, job)
+	close(done)
+	stopBuild()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	jobs := config.DB.Collection("data_exports")
+	leased := bson.M{"_id": job.ID, "leaseToken": job.LeaseToken}
+	if err != nil {
+		log.Printf("Data export %s failed: %v", job.ID.Hex(), err)
+		update := bson.M{"$set": bson.M{"status": models.DataExportFailed, "error": err.Error()}, "$unset": bson.M{"leaseUntil": "", "leaseToken": ""}}
+		if _, err := jobs.UpdateOne(ctx, leased, update); err != nil {
+			log.Printf("Failed to update data export %s: %v", job.ID.Hex(), err)
+		}
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(config.DataExportRetention)
+	update := bson.M{
+		"$set":   bson.M{"status": models.DataExportReady, "fileId": fileID, "size": size, "completedAt": now, "expiresAt": expires},
+		"$unset": bson.M{"leaseUntil": "", "leaseToken": ""},
+	}
+	result, err := jobs.UpdateOne(ctx, leased, update)
+	if err != nil {
+		log.Printf("Failed to update data export %s: %v", job.ID.Hex(), err)
+		deleteExportFile(ctx, fileID)
+		return
+	}
+	if result.MatchedCount == 0 {
+		log.Printf("Data export %s lost its lease; discarding the archive", job.ID.Hex())
+		deleteExportFile(ctx, fileID)
+		return
+	}
+	recordAudit(ctx, nil, models.AuditEntry{Action: models.AuditDataExportCompleted, TargetType: "data_export", TargetID: job.ID.Hex()})
+	notify(ctx, models.Notification{
+		UserID:  job.UserID,
+		Kind:    models.NotificationDataExport,
+		Message: fmt.Sprintf("Your data export is ready to download until %s", expires.UTC().Format(time.RFC1123)),
+	})
+}
+
+// renewDataExportLease extends the lease of a job being built until done is closed.
+// When the lease has been taken over by another worker the build is cancelled.
+func renewDataExportLease(job models.DataExport, stopBuild context.CancelFunc, done <-chan struct{}) {
+	interval := config.DataExportLease / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		leased := bson.M{"_id": job.ID, "leaseToken": job.LeaseToken}
+		update := bson.M{"$set": bson.M{"leaseUntil": time.Now().Add(config.DataExportLease)}}
+		result, err := config.DB.Collection("data_exports").UpdateOne(ctx, leased, update)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to renew the lease of data export %s: %v", job.ID.Hex(), err)
+			continue
+		}
+		if result.MatchedCount == 0 {
+			log.Printf("Data export %s lost its lease; stopping the build", job.ID.Hex())
+			stopBuild()
+			return
+		}
+	}
+}
+
+// exportWriter adds files to an export archive and records their checksums
+type exportWriter struct {
+	zw       *zip.Writer
+	manifest models.DataExportManifest
+}
+
+// add writes one file to the archive
+func (e *exportWriter) add(name string, r io.Reader) error {
+	w, err := e.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: e.manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), r)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	e.manifest.Files = append(e.manifest.Files, models.DataExportFileInfo{Name: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+// addJSON writes a value as an indented JSON file
+func (e *exportWriter) addJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.add(name, bytes.NewReader(data))
+}
+
+// addRecords writes the documents of a collection matching the filter as a JSON array
+func (e *exportWriter) addRecords(ctx context.Context, name, collection string, filter bson.M, records interface{}) error {
+	cursor, err := config.DB.Collection(collection).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, records); err != nil {
+		return err
+	}
+	return e.addJSON(name, records)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeDataExport streams the archive of a user's data into the exports bucket
+func writeDataExport(ctx context.Context, job models.DataExport) (primitive.ObjectID, int64, error) {
+	user, err := findUser(ctx, job.UserID)
+	if err != nil {
+		return primitive.NilObjectID, 0, fmt.Errorf("user not found: %w", err)
+	}
+	user.Password = ""
+
+	bucket, err := exportsBucket()
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	name := fmt.Sprintf("exports/%s/%s.zip", job.UserID, job.ID.Hex())
+	uploadStream, err := bucket.OpenUploadStream(name, options.GridFSUpload().SetMetadata(bson.M{"contentType": "application/zip"}))
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	defer uploadStream.Close()
+	counter := &countingWriter{w: uploadStream}
+	e := &exportWriter{
+		zw:       zip.NewWriter(counter),
+		manifest: models.DataExportManifest{UserID: job.UserID, CreatedAt: time.Now().UTC(), Counts: map[string]int{}},
+	}
+
+	if err := e.writeSections(ctx, user); err != nil {
+		uploadStream.Abort()
+		return primitive.NilObjectID, 0, err
+	}
+	if err := e.addJSON("manifest.json", e.manifest); err != nil {
+		uploadStream.Abort()
+		return primitive.NilObjectID, 0, err
+	}
+	if err := e.zw.Close(); err != nil {
+		uploadStream.Abort()
+		return primitive.NilObjectID, 0, err
+	}
+	if err := uploadStream.Close(); err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	fileID, ok := uploadStream.FileID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, 0, errors.New("invalid archive file ID")
+	}
+	return fileID, counter.n, nil
+}
+
+// writeSections writes the profile, the videos with their files and the records
+// the user created
+func (e *exportWriter) writeSections(ctx context.Context, user models.User) error {
+	if err := e.addJSON("profile.json", user); err != nil {
+		return err
+	}
+
+	videos := []models.Video{}
+	if err := e.addRecords(ctx, "videos.json", "videos", bson.M{"ownerId": user.ID}, &videos); err != nil {
+		return err
+	}
+	e.manifest.Counts["videos"] = len(videos)
+	source, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err != nil {
+		return err
+	}
+	for _, video := range videos {
+		stream, err := source.OpenDownloadStream(video.FileID)
+		if err != nil {
+			return fmt.Errorf("open file of video %s: %w", video.ID.Hex(), err)
+		}
+		err = e.add("videos/"+video.ID.Hex()+"/"+path.Base("/"+video.FileName), stream)
+		stream.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	comments := []models.Comment{}
+	if err := e.addRecords(ctx, "comments.json", "comments", bson.M{"authorId": user.ID}, &comments); err != nil {
+		return err
+	}
+	e.manifest.Counts["comments"] = len(comments)
+	playlists := []models.Playlist{}
+	if err := e.addRecords(ctx, "playlists.json", "playlists", bson.M{"ownerId": user.ID}, &playlists); err != nil {
+		return err
+	}
+	e.manifest.Counts["playlists"] = len(playlists)
+	history := []models.WatchEntry{}
+	if err := e.addRecords(ctx, "history.json", "history", bson.M{"userId": user.ID}, &history); err != nil {
+		return err
+	}
+	e.manifest.Counts["history"] = len(history)
+	reactions := []models.Reaction{}
+	if err := e.addRecords(ctx, "reactions.json", "reactions", bson.M{"userId": user.ID}, &reactions); err != nil {
+		return err
+	}
+	e.manifest.Counts["reactions"] = len(reactions)
+	return nil
+}
+
+// expireDataExports deletes the archives of exports past their retention
+func expireDataExports() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	jobs := config.DB.Collection("data_exports")
+	cursor, err := jobs.Find(ctx, bson.M{"status": models.DataExportReady, "expiresAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Printf("Failed to list expired data exports: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var job models.DataExport
+		if err := cursor.Decode(&job); err != nil {
+			continue
+		}
+		update := bson.M{"$set": bson.M{"status": models.DataExportExpired}, "$unset": bson.M{"fileId": ""}}
+		result, err := jobs.UpdateOne(ctx, bson.M{"_id": job.ID, "status": models.DataExportReady}, update)
+		if err != nil || result.ModifiedCount == 0 {
+			continue
+		}
+		if job.FileID != nil {
+			deleteExportFile(ctx, *job.FileID)
+		}
+	}
+}
+
+// deleteExportFile removes an export archive
+func deleteExportFile(ctx context.Context, fileID primitive.ObjectID) {
+	bucket, err := exportsBucket()
+	if err == nil {
+		err = bucket.DeleteContext(ctx, fileID)
+	}
+	if err != nil {
+		log.Printf("Failed to delete export archive %s: %v", fileID.Hex(), err)
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// useTestDB points config.DB at a fresh database for the test and drops it
+// afterwards. Tests that need MongoDB are skipped when none is reachable at
+// HUB_TEST_MONGO_URI (default mongodb://localhost:27017).
+func useTestDB(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("HUB_TEST_MONGO_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(2*time.Second))
+	if err == nil {
+		err = client.Ping(ctx, nil)
+	}
+	if err != nil {
+		t.Skipf("MongoDB is not available: %v", err)
+	}
+
+	saved := config.DB
+	config.DB = client.Database("hub_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.DB.Drop(ctx)
+		client.Disconnect(ctx)
+		config.DB = saved
+	})
+}
+
+// testUser stores a user and returns it with a session token
+func testUser(t *testing.T, role string) (models.User, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	user := models.User{ID: id.Hex(), Name: "Test " + role, Username: "test-" + id.Hex(), Role: role, CreatedAt: time.Now()}
+	doc := bson.M{"_id": id, "name": user.Name, "username": user.Username, "role": role, "createdAt": user.CreatedAt}
+	if _, err := config.DB.Collection("users").InsertOne(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	session, err := newSession(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, session.Token
+}
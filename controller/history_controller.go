@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// completedRatio is the share of a video after which it counts as watched
+	completedRatio = 0.95
+
+	// minResumePosition is the position below which a video is not worth resuming
+	minResumePosition = 5.0
+)
+
+// RecordHeartbeat stores the playback position reported by a player
+// @Summary Report playback position
+// @Description Stores the caller's playback position in a video. Players call this periodically while playing.
+// @Description Nothing is stored while the caller has paused their watch history.
+// @Tags History
+// @Accept json
+// @Param id path string true "Video ID"
+// @Param heartbeat body models.Heartbeat true "Playback position"
+// @Success 204 {string} string "Position stored"
+// @Failure 400 {string} string "Invalid position"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/heartbeat [post]
+func RecordHeartbeat(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var heartbeat models.Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil || heartbeat.Position < 0 {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
+	}
+	if video.Duration > 0 && heartbeat.Position > video.Duration {
+		heartbeat.Position = video.Duration
+	}
+	if user.HistoryPaused {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"position":  heartbeat.Position,
+		"completed": video.Duration > 0 && heartbeat.Position >= video.Duration*completedRatio,
+		"updatedAt": now,
+		"expiresAt": now.Add(config.HistoryRetention),
+	}}
+	filter := bson.M{"userId": user.ID, "videoId": video.ID}
+	if _, err := config.DB.Collection("history").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		http.Error(w, "Failed to store position", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMyHistory lists the videos the caller watched
+// @Summary List my watch history
+// @Description Lists the videos the authenticated user watched, most recently watched first
+// @Tags History
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of entries to skip"
+// @Produce json
+// @Success 200 {array} models.WatchEntry
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/history [get]
+func GetMyHistory(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, bson.M{})
+}
+
+// GetContinueWatching lists the videos the caller started but did not finish
+// @Summary List videos to continue watching
+// @Description Lists the videos the authenticated user stopped part way through, most recently watched first
+// @Tags History
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of entries to skip"
+// @Produce json
+// @Success 200 {array} models.WatchEntry
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/continue-watching [get]
+func GetContinueWatching(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, bson.M{"completed": false, "position": bson.M{"$gte": minResumePosition}})
+}
+
+// ClearMyHistory removes the caller's watch history
+// @Summary Clear my watch history
+// @Description Removes every entry of the authenticated user's watch history
+// @Tags History
+// @Success 204 {string} string "History cleared"
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/history [delete]
+func ClearMyHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	if _, err := config.DB.Collection("history").DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+		http.Error(w, "Failed to clear history", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteHistoryEntry removes one video from the caller's watch history
+// @Summary Remove a video from my watch history
+// @Description Removes one video from the authenticated user's watch history
+// @Tags History
+// @Param videoId path string true "Video ID"
+// @Success 204 {string} string "Entry removed"
+// @Failure 400 {string} string "Invalid video ID"
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/history/{videoId} [delete]
+func DeleteHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	videoID, err := primitive.ObjectIDFromHex(mux.Vars(r)["videoId"])
+	if err != nil {
+		http.Error(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := config.DB.Collection("history").DeleteOne(ctx, bson.M{"userId": user.ID, "videoId": videoID}); err != nil {
+		http.Error(w, "Failed to remove entry", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetHistorySettings pauses or resumes recording of the caller's watch history
+// @Summary Pause or resume watch history
+// @Description Pauses or resumes recording of the authenticated user's watch history. Existing entries are kept.
+// @Tags History
+// @Accept json
+// @Produce json
+// @Param settings body models.HistorySettings true "History settings"
+// @Success 200 {object} models.HistorySettings
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/history/settings [put]
+func SetHistorySettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var settings models.HistorySettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	userID, _ := primitive.ObjectIDFromHex(user.ID)
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"historyPaused": settings.Paused}}); err != nil {
+		http.Error(w, "Failed to update settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// listHistory writes a page of the caller's history entries matching the filter,
+// skipping videos that were deleted or are no longer visible to them
+func listHistory(w http.ResponseWriter, r *http.Request, filter bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// Entries whose video is gone or no longer visible are dropped before paging,
+	// so every page is full
+	filter["userId"] = user.ID
+	limit, offset := pagination(r)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": "videos",
+			"let":  bson.M{"videoId": "$videoId"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$videoId"}}}},
+				bson.M{"$match": viewableFilter(&user)},
+			},
+			"as": "video",
+		}}},
+		{{Key: "$unwind", Value: "$video"}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := config.DB.Collection("history").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	visible := []models.WatchEntry{}
+	for cursor.Next(ctx) {
+		var row struct {
+			models.WatchEntry `bson:",inline"`
+			Video             models.Video `bson:"video"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		summary := summarizeVideo(row.Video)
+		row.WatchEntry.Video = &summary
+		visible = append(visible, row.WatchEntry)
+	}
+	if err := cursor.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+// attachResumePositions sets ResumeAt on the videos the user stopped part way through
+func attachResumePositions(ctx context.Context, user *models.User, videos []models.Video) {
+	if user == nil || len(videos) == 0 {
+		return
+	}
+	ids := make([]primitive.ObjectID, len(videos))
+	for i, video := range videos {
+		ids[i] = video.ID
+	}
+
+	filter := bson.M{
+		"userId":    user.ID,
+		"videoId":   bson.M{"$in": ids},
+		"completed": false,
+		"position":  bson.M{"$gte": minResumePosition},
+	}
+	cursor, err := config.DB.Collection("history").Find(ctx, filter)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.WatchEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return
+	}
+	positions := make(map[primitive.ObjectID]float64, len(entries))
+	for _, entry := range entries {
+		positions[entry.VideoID] = entry.Position
+	}
+	for i := range videos {
+		if position, ok := positions[videos[i].ID]; ok {
+			videos[i].ResumeAt = &position
+		}
+	}
+}
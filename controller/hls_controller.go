@@ -0,0 +1,438 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// renditionBucket opens the GridFS bucket holding packaged playlists and segments
+func renditionBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("renditions"))
+}
+
+// PackageHLS starts HLS packaging of a video for every rung of the ladder
+// @Summary Package a video for HLS
+// @Description Starts packaging the video into the configured HLS bitrate ladder (owner or admin only). Progress is tracked per rendition.
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 202 {array} models.Rendition
+// @Failure 400 {string} string "Audio tracks are not packaged"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Failure 409 {string} string "Packaging already in progress"
+// @Router /videos/{id}/hls [post]
+func PackageHLS(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	if video.MediaType == models.MediaAudio {
+		http.Error(w, "Audio tracks are not packaged", http.StatusBadRequest)
+		return
+	}
+
+	if len(config.HLSLadder) == 0 {
+		http.Error(w, "No HLS ladder configured", http.StatusInternalServerError)
+		return
+	}
+
+	// Reset every rung of the ladder to pending. The filter claims the video in the
+	// same update, so concurrent requests cannot both start packaging. Running
+	// packaging keeps its renditions fresh; ones left behind by a process that
+	// died go stale and no longer block a new attempt.
+	renditions := make([]models.Rendition, 0, len(config.HLSLadder))
+	for _, rung := range config.HLSLadder {
+		renditions = append(renditions, models.Rendition{
+			Name:      rung.Name,
+			Width:     rung.Width,
+			Height:    rung.Height,
+			Bandwidth: rung.Bandwidth(),
+			Status:    models.RenditionPending,
+			UpdatedAt: time.Now(),
+		})
+	}
+	filter := bson.M{
+		"_id": video.ID,
+		"renditions": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"status":    bson.M{"$in": bson.A{models.RenditionPending, models.RenditionProcessing}},
+			"updatedAt": bson.M{"$gt": time.Now().Add(-config.HLSStaleAfter)},
+		}}},
+	}
+	result, err := config.DB.Collection("videos").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"renditions": renditions}})
+	if err != nil {
+		http.Error(w, "Failed to update video", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Packaging already in progress", http.StatusConflict)
+		return
+	}
+
+	go packageRenditions(video)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(renditions)
+}
+
+// GetHLSStatus returns the packaging status of every rendition
+// @Summary Get HLS packaging status
+// @Description Returns the renditions of a video and their packaging status
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {array} models.Rendition
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/hls [get]
+func GetHLSStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	renditions := video.Renditions
+	if renditions == nil {
+		renditions = []models.Rendition{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(renditions)
+}
+
+// GetHLSMaster serves the master playlist listing the ready renditions
+// @Summary Get HLS master playlist
+// @Description Returns the HLS master playlist referencing every ready rendition and caption track
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Produce application/vnd.apple.mpegurl
+// @Success 200 {string} string "Master playlist"
+// @Failure 404 {string} string "No HLS renditions available"
+// @Router /videos/{id}/hls/master.m3u8 [get]
+func GetHLSMaster(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	// Advertise caption tracks as WebVTT subtitle renditions
+	captions, err := findCaptions(ctx, video.ID)
+	if err != nil {
+		http.Error(w, "Failed to load captions", http.StatusInternalServerError)
+		return
+	}
+	subtitles := make([]media.SubtitleTrack, 0, len(captions))
+	for _, caption := range captions {
+		subtitles = append(subtitles, media.SubtitleTrack{
+			Name:     caption.Label,
+			Language: caption.Language,
+			URI:      "captions/" + caption.ID.Hex() + ".m3u8",
+			Default:  caption.Default,
+		})
+	}
+
+	playlist := media.MasterPlaylist(video.Renditions, subtitles)
+	if !strings.Contains(playlist, "#EXT-X-STREAM-INF") {
+		http.Error(w, "No HLS renditions available", http.StatusNotFound)
+		return
+	}
+	recordView(r, video.ID, user)
+
+	w.Header().Set("Content-Type", media.ContentType("master.m3u8"))
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, playlist)
+}
+
+// GetHLSFile serves a media playlist, init segment or media segment of a rendition
+// @Summary Get an HLS rendition file
+// @Description Streams a media playlist or segment of a packaged rendition
+// @Tags Streaming
+// @Param id path string true "Video ID"
+// @Param rendition path string true "Rendition name"
+// @Param file path string true "Playlist or segment file name"
+// @Success 200 {file} file "Rendition file"
+// @Failure 404 {string} string "File not found"
+// @Router /videos/{id}/hls/{rendition}/{file} [get]
+func GetHLSFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serveRenditionFile(w, r, vars["id"], vars["rendition"], vars["file"])
+}
+
+// serveRenditionFile streams a stored rendition file from GridFS
+func serveRenditionFile(w http.ResponseWriter, r *http.Request, videoID, rendition, file string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := findViewableVideo(ctx, w, r, videoID); !ok {
+		return
+	}
+
+	bucket, err := renditionBucket()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	downloadStream, err := bucket.OpenDownloadStreamByName(path.Join(videoID, rendition, file))
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer downloadStream.Close()
+
+	w.Header().Set("Content-Type", media.ContentType(file))
+	if _, err := io.Copy(w, downloadStream); err != nil {
+		log.Printf("Failed to stream %s: %v", file, err)
+	}
+}
+
+// packageRenditions downloads the source of a video and packages every rung in turn
+func packageRenditions(video models.Video) {
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	defer close(done)
+	go keepRenditionsAlive(video.ID, done)
+
+	workDir, err := os.MkdirTemp("", "hub-hls-")
+	if err != nil {
+		failRenditions(video.ID, fmt.Sprintf("create work directory: %v", err))
+		packagingFinished(video, len(config.HLSLadder))
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	source := filepath.Join(workDir, "source")
+	if err := downloadSource(ctx, video.FileID, source); err != nil {
+		failRenditions(video.ID, fmt.Sprintf("download source: %v", err))
+		packagingFinished(video, len(config.HLSLadder))
+		return
+	}
+
+	failed := 0
+	for _, rung := range config.HLSLadder {
+		setRenditionStatus(video.ID, rung.Name, bson.M{"status": models.RenditionProcessing})
+
+		output := filepath.Join(workDir, rung.Name)
+		if err := os.MkdirAll(output, 0o755); err != nil {
+			setRenditionStatus(video.ID, rung.Name, bson.M{"status": models.RenditionFailed, "error": err.Error()})
+			failed++
+			continue
+		}
+
+		if err := media.PackageHLS(ctx, rung, source, output); err != nil {
+			log.Printf("Packaging %s of video %s failed: %v", rung.Name, video.ID.Hex(), err)
+			setRenditionStatus(video.ID, rung.Name, bson.M{"status": models.RenditionFailed, "error": err.Error()})
+			failed++
+			continue
+		}
+
+		segments, err := storeRendition(ctx, video.ID, rung.Name, output)
+		if err != nil {
+			setRenditionStatus(video.ID, rung.Name, bson.M{"status": models.RenditionFailed, "error": err.Error()})
+			failed++
+			continue
+		}
+		fields := bson.M{"status": models.RenditionReady, "error": "", "segmentCount": segments}
+		// Players check the codecs up front, so they come from what ffmpeg wrote
+		if init, err := os.ReadFile(filepath.Join(output, "init.mp4")); err == nil {
+			fields["codecs"] = media.InitCodecs(init)
+		}
+		setRenditionStatus(video.ID, rung.Name, fields)
+	}
+	packagingFinished(video, failed)
+}
+
+// downloadSource copies the source file of a video from GridFS to a local path
+func downloadSource(ctx context.Context, fileID primitive.ObjectID, dest string) error {
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := bucket.DownloadToStream(fileID, out); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// storeRendition replaces the stored files of a rendition with the contents of dir and returns the segment count
+func storeRendition(ctx context.Context, videoID primitive.ObjectID, rendition, dir string) (int, error) {
+	bucket, err := renditionBucket()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := deleteRenditionFiles(ctx, videoID, rendition); err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	segments := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if err := uploadRenditionFile(bucket, videoID, rendition, filepath.Join(dir, name)); err != nil {
+			return 0, fmt.Errorf("store %s: %v", name, err)
+		}
+		if strings.HasSuffix(name, ".m4s") || strings.HasSuffix(name, ".ts") {
+			segments++
+		}
+	}
+	return segments, nil
+}
+
+// uploadRenditionFile stores one packaged file under "<video>/<rendition>/<name>"
+func uploadRenditionFile(bucket *gridfs.Bucket, videoID primitive.ObjectID, rendition, file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	name := path.Join(videoID.Hex(), rendition, filepath.Base(file))
+	metadata := bson.M{"videoId": videoID, "rendition": rendition}
+	_, err = bucket.UploadFromStream(name, in, options.GridFSUpload().SetMetadata(metadata))
+	return err
+}
+
+// deleteRenditionFiles removes every stored file of a rendition
+func deleteRenditionFiles(ctx context.Context, videoID primitive.ObjectID, rendition string) error {
+	bucket, err := renditionBucket()
+	if err != nil {
+		return err
+	}
+
+	cursor, err := bucket.FindContext(ctx, bson.M{"metadata.videoId": videoID, "metadata.rendition": rendition})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var file struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&file); err != nil {
+			return err
+		}
+		if err := bucket.DeleteContext(ctx, file.ID); err != nil && err != gridfs.ErrFileNotFound {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// setRenditionStatus updates fields of a single rendition of a video
+func setRenditionStatus(videoID primitive.ObjectID, rendition string, fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"renditions.$.updatedAt": time.Now()}
+	for key, value := range fields {
+		update["renditions.$."+key] = value
+	}
+	filter := bson.M{"_id": videoID, "renditions.name": rendition}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, filter, bson.M{"$set": update}); err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("Failed to update rendition %s of video %s: %v", rendition, videoID.Hex(), err)
+	}
+}
+
+// keepRenditionsAlive refreshes the unfinished renditions of a video until done
+// is closed, so PackageHLS can tell running packaging from abandoned packaging
+func keepRenditionsAlive(videoID primitive.ObjectID, done <-chan struct{}) {
+	interval := config.HLSStaleAfter / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		update := bson.M{"$set": bson.M{"renditions.$[unfinished].updatedAt": time.Now()}}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"unfinished.status": bson.M{"$in": bson.A{models.RenditionPending, models.RenditionProcessing}}},
+		}})
+		if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": videoID}, update, opts); err != nil {
+			log.Printf("Failed to refresh renditions of video %s: %v", videoID.Hex(), err)
+		}
+		cancel()
+	}
+}
+
+// failRenditions marks every rendition of a video as failed
+func failRenditions(videoID primitive.ObjectID, reason string) {
+	log.Printf("Packaging video %s failed: %s", videoID.Hex(), reason)
+	for _, rung := range config.HLSLadder {
+		setRenditionStatus(videoID, rung.Name, bson.M{"status": models.RenditionFailed, "error": reason})
+	}
+}
+
+// packagingFinished tells the owner and webhooks that packaging of a video finished
+func packagingFinished(video models.Video, failed int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	notification := models.Notification{
+		UserID:  video.OwnerID,
+		Kind:    models.NotificationProcessingComplete,
+		Message: fmt.Sprintf("%q is ready to stream", video.Title),
+		VideoID: &video.ID,
+	}
+	if failed > 0 {
+		notification.Kind = models.NotificationProcessingFailed
+		notification.Message = fmt.Sprintf("Processing of %q failed for %d of %d renditions", video.Title, failed, len(config.HLSLadder))
+	}
+	notify(ctx, notification)
+
+	data := videoEventData(video)
+	data.Ready = len(config.HLSLadder) - failed
+	data.Failed = failed
+	emitEvent(models.EventVideoProcessed, data)
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ImportFile imports a local media file as a video owned by the user, the way an
+// upload would. Content the owner already has on a video is not uploaded again;
+// that video is returned with skipped set.
+func ImportFile(ctx context.Context, owner models.User, entry models.ImportEntry) (models.Video, bool, error) {
+	var video models.Video
+
+	visibility := entry.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+	if !models.ValidVisibility(visibility) || visibility == models.VisibilityRestricted {
+		return video, false, fmt.Errorf("invalid visibility %q", visibility)
+	}
+	tags, err := models.NormalizeTags(entry.Tags)
+	if err != nil {
+		return video, false, fmt.Errorf("invalid tags: %v", err)
+	}
+
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return video, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return video, false, err
+	}
+	if info.Size() > config.MaxUploadBytes {
+		return video, false, fmt.Errorf("file exceeds the upload limit of %d bytes", config.MaxUploadBytes)
+	}
+
+	// Hash first so content that was imported before is never uploaded again
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return video, false, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	unlock := lockImport(owner.ID + ":" + sum)
+	defer unlock()
+	existing, err := findImported(ctx, owner.ID, sum)
+	if err == nil {
+		return existing, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return video, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return video, false, err
+	}
+
+	name := filepath.Base(entry.Path)
+	upload, err := readUploadTags(file, name, mime.TypeByExtension(filepath.Ext(name)))
+	if err != nil {
+		return video, false, err
+	}
+
+	if err := reserveStorage(ctx, owner, info.Size()); err != nil {
+		if err == errQuotaExceeded {
+			return video, false, errors.New("storage quota exceeded")
+		}
+		return video, false, err
+	}
+	fileID, stored, err := storeUpload(file, name, upload.ContentType)
+	if err == nil {
+		fileID, err = dedupeFile(ctx, fileID, stored)
+	}
+	if err != nil {
+		releaseStorage(owner.ID, info.Size())
+		return video, false, err
+	}
+
+	video = models.Video{
+		Title:       entry.Title,
+		Description: entry.Description,
+		FileName:    name,
+		FileID:      fileID,
+		Size:        info.Size(),
+		OwnerID:     owner.ID,
+		Visibility:  visibility,
+		Tags:        tags,
+		UploadDate:  time.Now().Format(time.RFC3339),
+	}
+	upload.apply(ctx, &video)
+
+	result, err := config.DB.Collection("videos").InsertOne(ctx, video)
+	if err != nil {
+		releaseFile(ctx, fileID)
+		if video.Audio != nil {
+			deleteImage(ctx, video.Audio.ArtworkFileID)
+		}
+		releaseStorage(owner.ID, info.Size())
+		return video, false, err
+	}
+	video.ID = result.InsertedID.(primitive.ObjectID)
+	indexVideo(video)
+
+	// An import runs to completion, so the follow-up work is not left to goroutines
+	probeVideo(video)
+	emitEvent(models.EventVideoUploaded, videoEventData(video))
+	recordAudit(ctx, nil, models.AuditEntry{ActorID: owner.ID, Action: models.AuditVideoUploaded, TargetType: "video", TargetID: video.ID.Hex(), Changes: auditChanges(nil, video)})
+	return video, false, nil
+}
+
+// importLock is held while content is checked for and imported
+type importLock struct {
+	sync.Mutex
+	users int // Imports holding or waiting for the lock
+}
+
+// importLocks serialises imports of the same content for the same owner, so two
+// copies of a file in one run cannot both miss findImported and both be imported
+var importLocks = struct {
+	sync.Mutex
+	held map[string]*importLock
+}{held: map[string]*importLock{}}
+
+// lockImport takes the import lock of a key and returns its release
+func lockImport(key string) func() {
+	importLocks.Lock()
+	lock, ok := importLocks.held[key]
+	if !ok {
+		lock = &importLock{}
+		importLocks.held[key] = lock
+	}
+	lock.users++
+	importLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		importLocks.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(importLocks.held, key)
+		}
+		importLocks.Unlock()
+	}
+}
+
+// findImported returns the owner's video of a file with the given content hash
+func findImported(ctx context.Context, ownerID, sum string) (models.Video, error) {
+	var video models.Video
+	var file struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	filter := bson.M{"metadata.sha256": sum, "metadata.refCount": bson.M{"$gt": 0}}
+	if err := config.DB.Collection("video.files").FindOne(ctx, filter).Decode(&file); err != nil {
+		return video, err
+	}
+	err := config.DB.Collection("videos").FindOne(ctx, bson.M{"fileId": file.ID, "ownerId": ownerID}).Decode(&video)
+	return video, err
+}
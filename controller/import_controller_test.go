@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockImport(t *testing.T) {
+	unlock := lockImport("owner:abc")
+
+	// Other content is not held up
+	done := make(chan struct{})
+	go func() {
+		lockImport("owner:def")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a different key waited for the lock")
+	}
+
+	// The same content waits until the first import is done
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	order := []string{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := lockImport("owner:abc")
+			mu.Lock()
+			order = append(order, "second")
+			mu.Unlock()
+			release()
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	order = append(order, "first")
+	mu.Unlock()
+	unlock()
+	wg.Wait()
+
+	if len(order) != 4 || order[0] != "first" {
+		t.Errorf("imports ran in order %v, want the first before the others", order)
+	}
+	importLocks.Lock()
+	defer importLocks.Unlock()
+	if len(importLocks.held) != 0 {
+		t.Errorf("%d locks left after every import finished", len(importLocks.held))
+	}
+}
@@ -0,0 +1,552 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/live"
+	"hub/media"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// liveStream is a session being published to this process
+type liveStream struct {
+	session models.LiveSession
+	dir     string
+	process *media.LiveProcess
+	done    chan struct{} // Closed once the session is closed
+}
+
+// Close ends the stream when the encoder disconnects and turns it into a video
+func (s *liveStream) Close() error {
+	err := s.process.Close()
+	go finishLiveStream(s)
+	return err
+}
+
+// Write passes the FLV stream to ffmpeg
+func (s *liveStream) Write(b []byte) (int, error) {
+	return s.process.Write(b)
+}
+
+// liveStreams holds the running streams by channel. Streams are served by the
+// process the encoder is connected to.
+var liveStreams = struct {
+	sync.Mutex
+	byChannel map[primitive.ObjectID]*liveStream
+}{byChannel: map[primitive.ObjectID]*liveStream{}}
+
+// StartLiveIngest starts the RTMP listener encoders publish to
+func StartLiveIngest() {
+	if config.LiveRTMPAddr == "off" {
+		return
+	}
+
+	// Sessions are served by the process the encoder is connected to, so only
+	// those whose process stopped sending heartbeats are closed here
+	go func() {
+		ticker := time.NewTicker(config.LiveStaleAfter)
+		defer ticker.Stop()
+		for {
+			failStaleLiveSessions()
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		log.Printf("Live ingest listening at %s", config.LiveRTMPAddr)
+		if err := live.ListenAndServe(config.LiveRTMPAddr, startLiveStream); err != nil {
+			log.Printf("Live ingest stopped: %v", err)
+		}
+	}()
+}
+
+// failStaleLiveSessions closes the sessions whose serving process went away
+func failStaleLiveSessions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	now := time.Now()
+	stale := bson.M{
+		"status": bson.M{"$in": bson.A{models.LiveStreaming, models.LiveSaving}},
+		"$or": bson.A{
+			bson.M{"heartbeatAt": bson.M{"$lt": now.Add(-config.LiveStaleAfter)}},
+			bson.M{"heartbeatAt": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"status": models.LiveFailed, "error": "server stopped during the stream", "endedAt": now}}
+	result, err := config.DB.Collection("live_sessions").UpdateMany(ctx, stale, update)
+	if err != nil {
+		log.Printf("Failed to close interrupted live sessions: %v", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Closed %d interrupted live sessions", result.ModifiedCount)
+	}
+}
+
+// keepLiveSessionAlive refreshes the heartbeat of a session until done is closed
+func keepLiveSessionAlive(sessionID primitive.ObjectID, done <-chan struct{}) {
+	interval := config.LiveStaleAfter / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := config.DB.Collection("live_sessions").UpdateOne(ctx, bson.M{"_id": sessionID}, bson.M{"$set": bson.M{"heartbeatAt": time.Now()}}); err != nil {
+			log.Printf("Failed to refresh live session %s: %v", sessionID.Hex(), err)
+		}
+		cancel()
+	}
+}
+
+// GetLiveStatus tells whether a channel is live
+// @Summary Get the live status of a channel
+// @Description Returns whether the channel is streaming, the live HLS playlist while it is, and the running or most recent session the caller may watch
+// @Tags Live
+// @Param handle path string true "Channel handle"
+// @Produce json
+// @Success 200 {object} models.LiveStatus
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/live [get]
+func GetLiveStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	user := optionalUser(ctx, r)
+
+	status := models.LiveStatus{}
+	if stream := runningStream(channel.ID); stream != nil && canView(liveVideo(stream.session), user) {
+		session := stream.session
+		status.Live = true
+		status.Session = &session
+		status.Playlist = fmt.Sprintf("/api/v1/channels/%s/live/index.m3u8", channel.Handle)
+	} else {
+		var session models.LiveSession
+		opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+		err := config.DB.Collection("live_sessions").FindOne(ctx, bson.M{"channelId": channel.ID, "status": bson.M{"$ne": models.LiveStreaming}}, opts).Decode(&session)
+		if err == nil && canView(liveVideo(session), user) {
+			status.Session = &session
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetLiveSettings returns the stream key and ingest URL of a channel
+// @Summary Get live settings
+// @Description Returns the encoder settings of a channel (managers only). A stream key is issued on first use.
+// @Tags Live
+// @Param handle path string true "Channel handle"
+// @Produce json
+// @Success 200 {object} models.LiveSettings
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Not allowed to manage this channel"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/live/settings [get]
+func GetLiveSettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, _, ok := findManagedChannel(ctx, w, r)
+	if !ok {
+		return
+	}
+	settings, err := ensureLiveSettings(ctx, channel, models.LiveSettingsInput{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateLiveSettings changes the title and audience of a channel's streams or rotates its key
+// @Summary Update live settings
+// @Description Sets the title and visibility used for streams and their recordings, or issues a new stream key (managers only). Changes apply from the next stream.
+// @Tags Live
+// @Accept json
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Param settings body models.LiveSettingsInput true "Settings to change"
+// @Success 200 {object} models.LiveSettings
+// @Failure 400 {string} string "Invalid visibility"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Not allowed to manage this channel"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/live/settings [put]
+func UpdateLiveSettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, _, ok := findManagedChannel(ctx, w, r)
+	if !ok {
+		return
+	}
+	var input models.LiveSettingsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if input.Visibility != nil && !models.ValidVisibility(*input.Visibility) {
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := ensureLiveSettings(ctx, channel, input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetLiveFile serves the live playlist and segments of a channel
+// @Summary Get live HLS playlist or segment
+// @Description Serves index.m3u8 and the segments of a running stream. The playlist keeps a sliding window of recent segments.
+// @Tags Live
+// @Param handle path string true "Channel handle"
+// @Param file path string true "index.m3u8 or a segment name"
+// @Success 200 {file} file
+// @Failure 404 {string} string "Channel is not live"
+// @Router /channels/{handle}/live/{file} [get]
+func GetLiveFile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	stream := runningStream(channel.ID)
+	if stream == nil || !canView(liveVideo(stream.session), optionalUser(ctx, r)) {
+		http.Error(w, "Channel is not live", http.StatusNotFound)
+		return
+	}
+
+	name := filepath.Base(mux.Vars(r)["file"])
+	if name != "index.m3u8" && !strings.HasSuffix(name, ".ts") {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(filepath.Join(stream.dir, name))
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// The playlist changes with every segment; segments never change
+	w.Header().Set("Content-Type", media.ContentType(name))
+	if name == "index.m3u8" {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Failed to stream live file %s: %v", name, err)
+	}
+}
+
+// ensureLiveSettings applies changes to the live settings of a channel, issuing
+// a stream key when it has none or a rotation is asked for
+func ensureLiveSettings(ctx context.Context, channel models.Channel, input models.LiveSettingsInput) (models.LiveSettings, error) {
+	settings := models.LiveSettings{Visibility: models.VisibilityPublic}
+	if channel.Live != nil {
+		settings = *channel.Live
+	}
+	changed := false
+	if settings.StreamKey == "" || input.RotateKey {
+		key, err := newSecret()
+		if err != nil {
+			return settings, err
+		}
+		settings.StreamKey = key
+		changed = true
+	}
+	if input.Title != nil {
+		settings.Title = strings.TrimSpace(*input.Title)
+		changed = true
+	}
+	if input.Visibility != nil {
+		settings.Visibility = *input.Visibility
+		changed = true
+	}
+	if input.AllowedUsers != nil {
+		settings.AllowedUsers = *input.AllowedUsers
+		changed = true
+	}
+	if input.AllowedGroups != nil {
+		settings.AllowedGroups = *input.AllowedGroups
+		changed = true
+	}
+	// Allow lists only mean something for restricted streams
+	if settings.Visibility != models.VisibilityRestricted {
+		settings.AllowedUsers, settings.AllowedGroups = nil, nil
+	}
+
+	if changed {
+		_, err := config.DB.Collection("channels").UpdateOne(ctx, bson.M{"_id": channel.ID}, bson.M{"$set": bson.M{"live": settings}})
+		if err != nil {
+			return settings, err
+		}
+	}
+	settings.IngestURL = config.LiveRTMPURL
+	return settings, nil
+}
+
+// runningStream returns the stream of a channel published to this process
+func runningStream(channelID primitive.ObjectID) *liveStream {
+	liveStreams.Lock()
+	defer liveStreams.Unlock()
+	stream := liveStreams.byChannel[channelID]
+	if stream == nil || stream.process == nil {
+		return nil
+	}
+	return stream
+}
+
+// liveVideo describes the audience of a session as a video for canView
+func liveVideo(session models.LiveSession) models.Video {
+	return models.Video{
+		OwnerID:       session.OwnerID,
+		Visibility:    session.Visibility,
+		AllowedUsers:  session.AllowedUsers,
+		AllowedGroups: session.AllowedGroups,
+	}
+}
+
+// startLiveStream accepts an encoder publishing a channel's stream key
+func startLiveStream(app, key string) (io.WriteCloser, error) {
+	if app != config.LiveApp {
+		return nil, fmt.Errorf("unknown application %q", app)
+	}
+	if key == "" {
+		return nil, errors.New("stream key required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var channel models.Channel
+	if err := config.DB.Collection("channels").FindOne(ctx, bson.M{"live.streamKey": key}).Decode(&channel); err != nil {
+		return nil, errors.New("invalid stream key")
+	}
+
+	// Claim the channel before the slower setup so a second encoder is refused
+	stream := &liveStream{}
+	liveStreams.Lock()
+	if liveStreams.byChannel[channel.ID] != nil {
+		liveStreams.Unlock()
+		return nil, errors.New("channel is already live")
+	}
+	liveStreams.byChannel[channel.ID] = stream
+	liveStreams.Unlock()
+
+	release := func() {
+		liveStreams.Lock()
+		delete(liveStreams.byChannel, channel.ID)
+		liveStreams.Unlock()
+	}
+
+	// The recording is charged to the channel owner, so owners out of space cannot go live
+	owner, err := findUser(ctx, channel.OwnerID)
+	if err != nil {
+		release()
+		return nil, errors.New("channel owner not found")
+	}
+	if quota := effectiveQuota(owner); quota > 0 && owner.UsedBytes >= quota {
+		release()
+		return nil, errQuotaExceeded
+	}
+
+	now := time.Now()
+	settings := *channel.Live
+	session := models.LiveSession{
+		ID:            primitive.NewObjectID(),
+		ChannelID:     channel.ID,
+		OwnerID:       channel.OwnerID,
+		Title:         settings.Title,
+		Visibility:    settings.Visibility,
+		AllowedUsers:  settings.AllowedUsers,
+		AllowedGroups: settings.AllowedGroups,
+		Status:        models.LiveStreaming,
+		StartedAt:     now,
+		HeartbeatAt:   now,
+	}
+	if session.Title == "" {
+		session.Title = fmt.Sprintf("%s live %s", channel.Name, now.Format("2006-01-02 15:04"))
+	}
+
+	dir := filepath.Join(config.LiveDir, session.ID.Hex())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		release()
+		return nil, err
+	}
+	process, err := media.StartLive(context.Background(), dir)
+	if err != nil {
+		release()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if _, err := config.DB.Collection("live_sessions").InsertOne(ctx, session); err != nil {
+		process.Close()
+		process.Wait()
+		release()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	liveStreams.Lock()
+	stream.session, stream.dir, stream.process = session, dir, process
+	stream.done = make(chan struct{})
+	liveStreams.Unlock()
+	go keepLiveSessionAlive(session.ID, stream.done)
+	log.Printf("Channel %s went live (session %s)", channel.Handle, session.ID.Hex())
+	return stream, nil
+}
+
+// finishLiveStream waits for ffmpeg to finish, stores the recording as a video
+// of the channel and closes the session
+func finishLiveStream(stream *liveStream) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	defer os.RemoveAll(stream.dir)
+	defer close(stream.done)
+
+	// The channel is off air once ffmpeg exits; storing the recording can take a
+	// while and must not keep it from going live again
+	err := stream.process.Wait()
+	ended := time.Now()
+	liveStreams.Lock()
+	delete(liveStreams.byChannel, stream.session.ChannelID)
+	liveStreams.Unlock()
+	saving := bson.M{"$set": bson.M{"status": models.LiveSaving, "endedAt": ended}}
+	if _, err := config.DB.Collection("live_sessions").UpdateOne(ctx, bson.M{"_id": stream.session.ID}, saving); err != nil {
+		log.Printf("Failed to end live session %s: %v", stream.session.ID.Hex(), err)
+	}
+
+	set := bson.M{"status": models.LiveEnded, "endedAt": ended}
+	if err == nil {
+		var video models.Video
+		video, err = storeRecording(ctx, stream.session, filepath.Join(stream.dir, "recording.mp4"))
+		if err == nil {
+			set["videoId"] = video.ID
+		}
+	}
+	if err != nil {
+		log.Printf("Live session %s failed: %v", stream.session.ID.Hex(), err)
+		set["status"] = models.LiveFailed
+		set["error"] = err.Error()
+	}
+	if _, err := config.DB.Collection("live_sessions").UpdateOne(ctx, bson.M{"_id": stream.session.ID}, bson.M{"$set": set}); err != nil {
+		log.Printf("Failed to close live session %s: %v", stream.session.ID.Hex(), err)
+	}
+}
+
+// storeRecording uploads the recording of a session and creates its video record
+func storeRecording(ctx context.Context, session models.LiveSession, path string) (models.Video, error) {
+	var video models.Video
+	file, err := os.Open(path)
+	if err != nil {
+		return video, fmt.Errorf("open recording: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return video, errors.New("recording is empty")
+	}
+
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err != nil {
+		return video, err
+	}
+	name := fmt.Sprintf("live-%s.mp4", session.StartedAt.Format("20060102-150405"))
+	uploadStream, err := bucket.OpenUploadStream(name, options.GridFSUpload().SetMetadata(bson.M{"contentType": "video/mp4"}))
+	if err != nil {
+		return video, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(uploadStream, io.TeeReader(file, hash)); err != nil {
+		uploadStream.Abort()
+		return video, fmt.Errorf("upload recording: %v", err)
+	}
+	if err := uploadStream.Close(); err != nil {
+		return video, fmt.Errorf("upload recording: %v", err)
+	}
+	fileID, ok := uploadStream.FileID.(primitive.ObjectID)
+	if !ok {
+		return video, errors.New("invalid recording file ID")
+	}
+	if fileID, err = dedupeFile(ctx, fileID, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return video, err
+	}
+
+	channelID := session.ChannelID
+	video = models.Video{
+		Title:         session.Title,
+		FileName:      name,
+		FileID:        fileID,
+		Size:          info.Size(),
+		OwnerID:       session.OwnerID,
+		Visibility:    session.Visibility,
+		AllowedUsers:  session.AllowedUsers,
+		AllowedGroups: session.AllowedGroups,
+		ChannelID:     &channelID,
+		UploadDate:    session.StartedAt.Format(time.RFC3339),
+	}
+	result, err := config.DB.Collection("videos").InsertOne(ctx, video)
+	if err != nil {
+		releaseFile(ctx, fileID)
+		return video, err
+	}
+	video.ID = result.InsertedID.(primitive.ObjectID)
+
+	// Recordings are charged even past the quota; the stream was already accepted
+	if ownerID, err := primitive.ObjectIDFromHex(session.OwnerID); err == nil {
+		if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": ownerID}, bson.M{"$inc": bson.M{"usedBytes": video.Size}}); err != nil {
+			log.Printf("Failed to charge recording of session %s: %v", session.ID.Hex(), err)
+		}
+	}
+
+	indexVideo(video)
+	go probeVideo(video)
+	go notifySubscribers(video)
+	go emitEvent(models.EventVideoUploaded, videoEventData(video))
+	return video, nil
+}
@@ -0,0 +1,465 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxMentions caps the users notified about a single comment
+	maxMentions = 10
+
+	// maxReplayedNotifications caps the notifications resent to a reconnecting stream
+	maxReplayedNotifications = 100
+)
+
+// mentionPattern matches @username in comment bodies
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// notificationBroker hands new notifications to the streams of their recipients.
+// It only reaches clients connected to this process.
+type notificationBroker struct {
+	mu      sync.Mutex
+	clients map[string]map[chan models.Notification]struct{}
+}
+
+var notifications = &notificationBroker{clients: map[string]map[chan models.Notification]struct{}{}}
+
+// subscribe registers a stream of a user
+func (b *notificationBroker) subscribe(userID string) chan models.Notification {
+	ch := make(chan models.Notification, 16)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clients[userID] == nil {
+		b.clients[userID] = map[chan models.Notification]struct{}{}
+	}
+	b.clients[userID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a stream of a user
+func (b *notificationBroker) unsubscribe(userID string, ch chan models.Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients[userID], ch)
+	if len(b.clients[userID]) == 0 {
+		delete(b.clients, userID)
+	}
+}
+
+// publish sends a notification to the streams of its recipient. Streams that
+// fall behind miss it and pick it up from the list instead.
+func (b *notificationBroker) publish(notification models.Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
+
+// ListNotifications lists the caller's notifications
+// @Summary List my notifications
+// @Description Lists the caller's notifications, newest first
+// @Tags Notifications
+// @Param unread query bool false "Only unread notifications"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of notifications to skip"
+// @Produce json
+// @Success 200 {array} models.Notification
+// @Failure 401 {string} string "Authentication required"
+// @Router /notifications [get]
+func ListNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"userId": user.ID}
+	if unread, _ := strconv.ParseBool(r.URL.Query().Get("unread")); unread {
+		filter["read"] = false
+	}
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset)
+	cursor, err := config.DB.Collection("notifications").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	list := []models.Notification{}
+	if err := cursor.All(ctx, &list); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetUnreadCount returns the number of unread notifications
+// @Summary Count unread notifications
+// @Tags Notifications
+// @Produce json
+// @Success 200 {object} models.UnreadCount
+// @Failure 401 {string} string "Authentication required"
+// @Router /notifications/unread-count [get]
+func GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	count, err := config.DB.Collection("notifications").CountDocuments(ctx, bson.M{"userId": user.ID, "read": false})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UnreadCount{Unread: count})
+}
+
+// UpdateNotification marks a notification as read or unread
+// @Summary Mark a notification read or unread
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param id path string true "Notification ID"
+// @Param update body models.NotificationUpdate true "Read state"
+// @Success 200 {object} models.Notification
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Notification not found"
+// @Router /notifications/{id} [put]
+func UpdateNotification(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	var body models.NotificationUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	var notification models.Notification
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = config.DB.Collection("notifications").FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "userId": user.ID},
+		bson.M{"$set": bson.M{"read": body.Read}}, opts).Decode(&notification)
+	if err != nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notification)
+}
+
+// MarkAllNotificationsRead marks every notification of the caller as read
+// @Summary Mark all notifications read
+// @Tags Notifications
+// @Success 204 {string} string "Notifications marked read"
+// @Failure 401 {string} string "Authentication required"
+// @Router /notifications/read [post]
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	_, err := config.DB.Collection("notifications").UpdateMany(ctx,
+		bson.M{"userId": user.ID, "read": false}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteNotification removes a notification
+// @Summary Delete a notification
+// @Tags Notifications
+// @Param id path string true "Notification ID"
+// @Success 204 {string} string "Notification deleted"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Notification not found"
+// @Router /notifications/{id} [delete]
+func DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	result, err := config.DB.Collection("notifications").DeleteOne(ctx, bson.M{"_id": id, "userId": user.ID})
+	if err != nil {
+		http.Error(w, "Failed to delete notification", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StreamNotifications pushes new notifications to the caller as Server-Sent Events
+// @Summary Stream notifications
+// @Description Keeps the connection open and sends each new notification as a "notification" event whose data is the notification JSON.
+// @Description Browsers reconnect with Last-Event-ID and receive the notifications they missed. The session cookie authenticates EventSource clients.
+// @Tags Notifications
+// @Produce text/event-stream
+// @Success 200 {string} string "Event stream"
+// @Failure 401 {string} string "Authentication required"
+// @Router /notifications/stream [get]
+func StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		cancel()
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		cancel()
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Register before replaying so nothing created in between is lost
+	ch := notifications.subscribe(user.ID)
+	defer notifications.unsubscribe(user.ID, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var last primitive.ObjectID
+	if lastID, err := primitive.ObjectIDFromHex(r.Header.Get("Last-Event-ID")); err == nil {
+		last = lastID
+		missed, err := missedNotifications(ctx, user.ID, lastID)
+		if err != nil {
+			log.Printf("Failed to replay notifications of user %s: %v", user.ID, err)
+		}
+		for _, notification := range missed {
+			writeNotificationEvent(w, notification)
+			last = notification.ID
+		}
+	}
+	cancel()
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(config.NotificationKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case notification := <-ch:
+			// Skip what the replay already sent
+			if notification.ID.Hex() <= last.Hex() {
+				continue
+			}
+			writeNotificationEvent(w, notification)
+			last = notification.ID
+		}
+		flusher.Flush()
+	}
+}
+
+// missedNotifications loads the notifications created after the given one, oldest first
+func missedNotifications(ctx context.Context, userID string, after primitive.ObjectID) ([]models.Notification, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(maxReplayedNotifications)
+	cursor, err := config.DB.Collection("notifications").Find(ctx, bson.M{"userId": userID, "_id": bson.M{"$gt": after}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var missed []models.Notification
+	err = cursor.All(ctx, &missed)
+	return missed, err
+}
+
+// writeNotificationEvent writes a notification as a Server-Sent Event
+func writeNotificationEvent(w http.ResponseWriter, notification models.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", notification.ID.Hex(), data)
+}
+
+// notify stores a notification and pushes it to the recipient's streams.
+// Users are not notified about their own actions.
+func notify(ctx context.Context, notification models.Notification) {
+	if notification.UserID == "" || notification.UserID == notification.ActorID {
+		return
+	}
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	notification.ExpiresAt = notification.CreatedAt.Add(config.NotificationRetention)
+	if _, err := config.DB.Collection("notifications").InsertOne(ctx, notification); err != nil {
+		log.Printf("Failed to store %s notification for user %s: %v", notification.Kind, notification.UserID, err)
+		return
+	}
+	notifications.publish(notification)
+}
+
+// notifyComment tells the video owner, the author of the answered comment and
+// mentioned users about a new comment
+func notifyComment(video models.Video, comment models.Comment, parent *models.Comment) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	notified := map[string]bool{comment.AuthorID: true}
+	send := func(userID, kind, message string) {
+		if notified[userID] {
+			return
+		}
+		notified[userID] = true
+		notify(ctx, models.Notification{
+			UserID:    userID,
+			Kind:      kind,
+			Message:   message,
+			ActorID:   comment.AuthorID,
+			VideoID:   &video.ID,
+			CommentID: &comment.ID,
+		})
+	}
+
+	if parent != nil {
+		send(parent.AuthorID, models.NotificationComment, fmt.Sprintf("%s replied to your comment on %q", comment.AuthorName, video.Title))
+	}
+	send(video.OwnerID, models.NotificationComment, fmt.Sprintf("%s commented on %q", comment.AuthorName, video.Title))
+
+	for _, user := range mentionedUsers(ctx, comment.Body) {
+		// Mentioned users who cannot watch the video are not told about it
+		if canView(video, &user) {
+			send(user.ID, models.NotificationMention, fmt.Sprintf("%s mentioned you in a comment on %q", comment.AuthorName, video.Title))
+		}
+	}
+}
+
+// mentionedUsers resolves the @usernames of a comment body
+func mentionedUsers(ctx context.Context, body string) []models.User {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	cursor, err := config.DB.Collection("users").Find(ctx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		log.Printf("Failed to resolve mentions: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Printf("Failed to resolve mentions: %v", err)
+	}
+	return users
+}
+
+// notifySubscribers tells the subscribers of a channel who asked for every
+// upload about a new video they can find in listings, and adds it to the cached
+// feed timelines of all subscribers
+func notifySubscribers(video models.Video) {
+	if video.ChannelID == nil || video.Visibility == models.VisibilityUnlisted || video.Visibility == models.VisibilityPrivate {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	addToTimelines(ctx, video)
+
+	var channel models.Channel
+	if err := config.DB.Collection("channels").FindOne(ctx, bson.M{"_id": *video.ChannelID}).Decode(&channel); err != nil {
+		return
+	}
+	cursor, err := config.DB.Collection("subscriptions").Find(ctx, bson.M{"channelId": channel.ID, "notify": models.NotifyAll})
+	if err != nil {
+		log.Printf("Failed to load subscribers of channel %s: %v", channel.Handle, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var subscription models.Subscription
+		if err := cursor.Decode(&subscription); err != nil {
+			continue
+		}
+		if video.Visibility == models.VisibilityRestricted {
+			user, err := findUser(ctx, subscription.UserID)
+			if err != nil || !canView(video, &user) {
+				continue
+			}
+		}
+		notify(ctx, models.Notification{
+			UserID:    subscription.UserID,
+			Kind:      models.NotificationUpload,
+			Message:   fmt.Sprintf("%s uploaded %q", channel.Name, video.Title),
+			ActorID:   video.OwnerID,
+			VideoID:   &video.ID,
+			ChannelID: &channel.ID,
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// minPositionGap is the smallest gap between positions before the playlist is renumbered
+const minPositionGap = 1e-9
+
+// CreatePlaylist creates a playlist owned by the caller
+// @Summary Create a playlist
+// @Description Creates an empty playlist owned by the authenticated user
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Param playlist body models.PlaylistInput true "Playlist"
+// @Success 201 {object} models.Playlist
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Router /playlists [post]
+func CreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var input models.PlaylistInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Title == nil || strings.TrimSpace(*input.Title) == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	playlist := models.Playlist{
+		OwnerID:    user.ID,
+		Visibility: models.VisibilityPublic,
+		Items:      []models.PlaylistItem{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if !applyPlaylistInput(&playlist, input) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	result, err := config.DB.Collection("playlists").InsertOne(ctx, playlist)
+	if err != nil {
+		http.Error(w, "Failed to create playlist", http.StatusInternalServerError)
+		return
+	}
+	playlist.ID = result.InsertedID.(primitive.ObjectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// GetPlaylist returns a playlist with summaries of its videos
+// @Summary Get a playlist
+// @Description Returns a playlist with its items in order and a summary of each video the caller may see.
+// @Description Items whose video was deleted are left out.
+// @Tags Playlists
+// @Param id path string true "Playlist ID"
+// @Produce json
+// @Success 200 {object} models.Playlist
+// @Failure 404 {string} string "Playlist not found"
+// @Router /playlists/{id} [get]
+func GetPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := optionalUser(ctx, r)
+	playlist, err := findPlaylist(ctx, mux.Vars(r)["id"])
+	if err != nil || !canViewPlaylist(playlist, user) {
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+
+	if err := embedPlaylistVideos(ctx, &playlist, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// UpdatePlaylist changes the title, description, visibility or collaborators of a playlist
+// @Summary Update a playlist
+// @Description Updates playlist details (owner or admin only). Omitted fields are left unchanged.
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Playlist ID"
+// @Param playlist body models.PlaylistInput true "Changes"
+// @Success 200 {object} models.Playlist
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Only the owner can change this playlist"
+// @Failure 404 {string} string "Playlist not found"
+// @Router /playlists/{id} [put]
+func UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	playlist, user, ok := findEditablePlaylist(ctx, w, r, true)
+	if !ok {
+		return
+	}
+
+	before := playlist
+	var input models.PlaylistInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || !applyPlaylistInput(&playlist, input) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	playlist.UpdatedAt = time.Now()
+
+	update := bson.M{"$set": bson.M{
+		"title":         playlist.Title,
+		"description":   playlist.Description,
+		"visibility":    playlist.Visibility,
+		"collaborators": playlist.Collaborators,
+		"updatedAt":     playlist.UpdatedAt,
+	}}
+	if _, err := config.DB.Collection("playlists").UpdateOne(ctx, bson.M{"_id": playlist.ID}, update); err != nil {
+		http.Error(w, "Failed to update playlist", http.StatusInternalServerError)
+		return
+	}
+	if input.Collaborators != nil {
+		recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditPlaylistCollaboratorsChanged, TargetType: "playlist", TargetID: playlist.ID.Hex(), Changes: auditChanges(before, playlist)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// DeletePlaylist deletes a playlist
+// @Summary Delete a playlist
+// @Description Deletes a playlist (owner or admin only). The videos are not affected.
+// @Tags Playlists
+// @Param id path string true "Playlist ID"
+// @Success 204 {string} string "Playlist deleted"
+// @Failure 403 {string} string "Only the owner can change this playlist"
+// @Failure 404 {string} string "Playlist not found"
+// @Router /playlists/{id} [delete]
+func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	playlist, user, ok := findEditablePlaylist(ctx, w, r, true)
+	if !ok {
+		return
+	}
+
+	if _, err := config.DB.Collection("playlists").DeleteOne(ctx, bson.M{"_id": playlist.ID}); err != nil {
+		http.Error(w, "Failed to delete playlist", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditPlaylistDeleted, TargetType: "playlist", TargetID: playlist.ID.Hex(), Changes: auditChanges(playlist, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddPlaylistItem adds a video to a playlist
+// @Summary Add a video to a playlist
+// @Description Inserts a video at the given index, or appends it (owner, collaborators or admin)
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Playlist ID"
+// @Param item body models.PlaylistItemInput true "Video and target index"
+// @Success 201 {object} models.Playlist
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Playlist not found"
+// @Failure 409 {string} string "Video is already in the playlist"
+// @Router /playlists/{id}/items [post]
+func AddPlaylistItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	playlist, user, ok := findEditablePlaylist(ctx, w, r, false)
+	if !ok {
+		return
+	}
+
+	var input models.PlaylistItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// Editors can only add videos they can watch themselves
+	video, err := findVideo(ctx, input.VideoID)
+	if err != nil || !canView(video, &user) {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return
+	}
+	for _, item := range playlist.Items {
+		if item.VideoID == video.ID {
+			http.Error(w, "Video is already in the playlist", http.StatusConflict)
+			return
+		}
+	}
+
+	position, ok := targetPosition(playlist.Items, input)
+	if !ok {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
+	}
+
+	item := models.PlaylistItem{VideoID: video.ID, Position: position, AddedBy: user.ID, AddedAt: time.Now()}
+	filter := bson.M{"_id": playlist.ID, "items.videoId": bson.M{"$ne": video.ID}}
+	update := bson.M{"$push": bson.M{"items": item}, "$set": bson.M{"updatedAt": item.AddedAt}}
+	result, err := config.DB.Collection("playlists").UpdateOne(ctx, filter, update)
+	if err != nil {
+		http.Error(w, "Failed to update playlist", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Video is already in the playlist", http.StatusConflict)
+		return
+	}
+
+	respondWithPlaylist(ctx, w, playlist.ID, &user, http.StatusCreated)
+}
+
+// MovePlaylistItem moves a video to a new place in a playlist
+// @Summary Reorder a playlist item
+// @Description Moves a video to the given index or fractional position (owner, collaborators or admin)
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Playlist ID"
+// @Param videoId path string true "Video ID"
+// @Param item body models.PlaylistItemInput true "Target index or position"
+// @Success 200 {object} models.Playlist
+// @Failure 400 {string} string "Invalid position"
+// @Failure 404 {string} string "Item not found"
+// @Router /playlists/{id}/items/{videoId} [put]
+func MovePlaylistItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	playlist, user, ok := findEditablePlaylist(ctx, w, r, false)
+	if !ok {
+		return
+	}
+
+	videoID, err := primitive.ObjectIDFromHex(mux.Vars(r)["videoId"])
+	if err != nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	var input models.PlaylistItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || (input.Index == nil && input.Position == nil) {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
+	}
+
+	// Compute the target among the other items so the moved item does not count itself
+	others := make([]models.PlaylistItem, 0, len(playlist.Items))
+	found := false
+	for _, item := range playlist.Items {
+		if item.VideoID == videoID {
+			found = true
+			continue
+		}
+		others = append(others, item)
+	}
+	if !found {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	position, ok := targetPosition(others, input)
+	if !ok {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"_id": playlist.ID, "items.videoId": videoID}
+	update := bson.M{"$set": bson.M{"items.$.position": position, "updatedAt": time.Now()}}
+	result, err := config.DB.Collection("playlists").UpdateOne(ctx, filter, update)
+	if err != nil {
+		http.Error(w, "Failed to update playlist", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	respondWithPlaylist(ctx, w, playlist.ID, &user, http.StatusOK)
+}
+
+// RemovePlaylistItem removes a video from a playlist
+// @Summary Remove a video from a playlist
+// @Description Removes a video from a playlist (owner, collaborators or admin)
+// @Tags Playlists
+// @Param id path string true "Playlist ID"
+// @Param videoId path string true "Video ID"
+// @Success 204 {string} string "Item removed"
+// @Failure 404 {string} string "Item not found"
+// @Router /playlists/{id}/items/{videoId} [delete]
+func RemovePlaylistItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	playlist, _, ok := findEditablePlaylist(ctx, w, r, false)
+	if !ok {
+		return
+	}
+
+	videoID, err := primitive.ObjectIDFromHex(mux.Vars(r)["videoId"])
+	if err != nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	filter := bson.M{"_id": playlist.ID, "items.videoId": videoID}
+	update := bson.M{"$pull": bson.M{"items": bson.M{"videoId": videoID}}, "$set": bson.M{"updatedAt": time.Now()}}
+	result, err := config.DB.Collection("playlists").UpdateOne(ctx, filter, update)
+	if err != nil {
+		http.Error(w, "Failed to update playlist", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPlaylistInput copies the provided fields onto the playlist and validates them
+func applyPlaylistInput(playlist *models.Playlist, input models.PlaylistInput) bool {
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			return false
+		}
+		playlist.Title = title
+	}
+	if input.Description != nil {
+		playlist.Description = *input.Description
+	}
+	if input.Visibility != nil {
+		switch *input.Visibility {
+		case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+			playlist.Visibility = *input.Visibility
+		default:
+			return false
+		}
+	}
+	if input.Collaborators != nil {
+		playlist.Collaborators = *input.Collaborators
+	}
+	return true
+}
+
+// targetPosition returns the fractional position for the requested index among items.
+// Positions are taken halfway between neighbours; an explicit position is used as is.
+func targetPosition(items []models.PlaylistItem, input models.PlaylistItemInput) (float64, bool) {
+	if input.Position != nil {
+		return *input.Position, true
+	}
+
+	sorted := sortedItems(items)
+	index := len(sorted)
+	if input.Index != nil {
+		index = *input.Index
+	}
+	if index < 0 || index > len(sorted) {
+		return 0, false
+	}
+
+	switch {
+	case len(sorted) == 0:
+		return 1, true
+	case index == 0:
+		return sorted[0].Position - 1, true
+	case index == len(sorted):
+		return sorted[len(sorted)-1].Position + 1, true
+	default:
+		return (sorted[index-1].Position + sorted[index].Position) / 2, true
+	}
+}
+
+// sortedItems returns the items ordered by position, ties broken by insertion time
+func sortedItems(items []models.PlaylistItem) []models.PlaylistItem {
+	sorted := append([]models.PlaylistItem(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Position != sorted[j].Position {
+			return sorted[i].Position < sorted[j].Position
+		}
+		return sorted[i].AddedAt.Before(sorted[j].AddedAt)
+	})
+	return sorted
+}
+
+// renumberIfCrowded rewrites positions as 1, 2, 3... once repeated halving has
+// left neighbouring positions too close to split again
+func renumberIfCrowded(ctx context.Context, playlist models.Playlist) {
+	sorted := sortedItems(playlist.Items)
+	crowded := false
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Position-sorted[i-1].Position < minPositionGap {
+			crowded = true
+			break
+		}
+	}
+	if !crowded {
+		return
+	}
+
+	for i := range sorted {
+		sorted[i].Position = float64(i + 1)
+	}
+	// Only renumber if nobody changed the items in the meantime
+	filter := bson.M{"_id": playlist.ID, "updatedAt": playlist.UpdatedAt}
+	if _, err := config.DB.Collection("playlists").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"items": sorted}}); err != nil {
+		log.Printf("Failed to renumber playlist %s: %v", playlist.ID.Hex(), err)
+	}
+}
+
+// embedPlaylistVideos sorts the items and attaches the summaries of the videos the user may see
+func embedPlaylistVideos(ctx context.Context, playlist *models.Playlist, user *models.User) error {
+	ids := make([]primitive.ObjectID, 0, len(playlist.Items))
+	for _, item := range playlist.Items {
+		ids = append(ids, item.VideoID)
+	}
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	videos := map[primitive.ObjectID]models.Video{}
+	for cursor.Next(ctx) {
+		var video models.Video
+		if err := cursor.Decode(&video); err == nil {
+			videos[video.ID] = video
+		}
+	}
+
+	// Deleted videos and videos the user may not see are left out
+	items := []models.PlaylistItem{}
+	for _, item := range sortedItems(playlist.Items) {
+		video, ok := videos[item.VideoID]
+		if !ok || !canView(video, user) {
+			continue
+		}
+		summary := summarizeVideo(video)
+		item.Video = &summary
+		items = append(items, item)
+	}
+	playlist.Items = items
+	return nil
+}
+
+// respondWithPlaylist reloads a playlist after a change and writes it with its videos
+func respondWithPlaylist(ctx context.Context, w http.ResponseWriter, id primitive.ObjectID, user *models.User, status int) {
+	playlist, err := findPlaylist(ctx, id.Hex())
+	if err != nil {
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+	renumberIfCrowded(ctx, playlist)
+	if err := embedPlaylistVideos(ctx, &playlist, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// findPlaylist loads the playlist with the given hex ID
+func findPlaylist(ctx context.Context, id string) (models.Playlist, error) {
+	var playlist models.Playlist
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return playlist, err
+	}
+	err = config.DB.Collection("playlists").FindOne(ctx, bson.M{"_id": objectID}).Decode(&playlist)
+	return playlist, err
+}
+
+// findEditablePlaylist loads the playlist of the request for editing. Collaborators
+// may edit items; details and deletion are reserved for the owner.
+func findEditablePlaylist(ctx context.Context, w http.ResponseWriter, r *http.Request, ownerOnly bool) (models.Playlist, models.User, bool) {
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return models.Playlist{}, user, false
+	}
+	playlist, err := findPlaylist(ctx, mux.Vars(r)["id"])
+	if err != nil || !canViewPlaylist(playlist, &user) {
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return playlist, user, false
+	}
+
+	isOwner := user.Role == models.RoleAdmin || playlist.OwnerID == user.ID
+	if ownerOnly && !isOwner {
+		http.Error(w, "Only the owner can change this playlist", http.StatusForbidden)
+		return playlist, user, false
+	}
+	if !isOwner && !isCollaborator(playlist, user.ID) {
+		http.Error(w, "Only the owner and collaborators can edit this playlist", http.StatusForbidden)
+		return playlist, user, false
+	}
+	return playlist, user, true
+}
+
+// canViewPlaylist reports whether the user, nil for anonymous requests, may see the playlist
+func canViewPlaylist(playlist models.Playlist, user *models.User) bool {
+	if playlist.Visibility != models.VisibilityPrivate {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	return user.Role == models.RoleAdmin || playlist.OwnerID == user.ID || isCollaborator(playlist, user.ID)
+}
+
+// isCollaborator reports whether the user may edit the playlist items
+func isCollaborator(playlist models.Playlist, userID string) bool {
+	for _, id := range playlist.Collaborators {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"hub/config"
+	"hub/models"
+	"hub/signing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetChannelPodcast serves the videos of a channel as a podcast feed
+// @Summary Get a channel podcast feed
+// @Description Returns the newest videos of a channel the caller may see in listings as RSS 2.0 with iTunes podcast extensions.
+// @Description Supports conditional requests with If-None-Match and If-Modified-Since.
+// @Tags Podcasts
+// @Param handle path string true "Channel handle"
+// @Produce application/rss+xml
+// @Success 200 {string} string "RSS feed"
+// @Success 304 {string} string "Not modified"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/feed.xml [get]
+func GetChannelPodcast(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	user := optionalUser(ctx, r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(config.PodcastMaxItems))
+	videos, err := findChannelVideos(ctx, channel, user, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	show := models.PodcastChannel{
+		Title:       channel.Name,
+		Link:        base + "/api/v1/channels/" + channel.Handle,
+		Description: channel.Description,
+		ITunesType:  "episodic",
+	}
+	show.ITunesAuthor = channel.Team
+	if show.ITunesAuthor == "" {
+		if owner, err := findUser(ctx, channel.OwnerID); err == nil {
+			show.ITunesAuthor = owner.Name
+		}
+	}
+	if channel.AvatarFileID != nil {
+		show.ITunesImage = &models.ITunesImage{Href: base + "/api/v1/channels/" + channel.Handle + "/avatar"}
+	}
+	writePodcast(ctx, w, r, show, videos, channel.CreatedAt, user, false)
+}
+
+// GetPlaylistPodcast serves the videos of a playlist as a podcast feed
+// @Summary Get a playlist podcast feed
+// @Description Returns the videos of a playlist the caller may see, in playlist order, as RSS 2.0 with iTunes podcast extensions.
+// @Description Supports conditional requests with If-None-Match and If-Modified-Since.
+// @Tags Podcasts
+// @Param id path string true "Playlist ID"
+// @Produce application/rss+xml
+// @Success 200 {string} string "RSS feed"
+// @Success 304 {string} string "Not modified"
+// @Failure 404 {string} string "Playlist not found"
+// @Router /playlists/{id}/feed.xml [get]
+func GetPlaylistPodcast(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := optionalUser(ctx, r)
+	playlist, err := findPlaylist(ctx, mux.Vars(r)["id"])
+	if err != nil || !canViewPlaylist(playlist, user) {
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+
+	items := sortedItems(playlist.Items)
+	if len(items) > config.PodcastMaxItems {
+		items = items[:config.PodcastMaxItems]
+	}
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.VideoID)
+	}
+	cursor, err := config.DB.Collection("videos").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+	found := map[primitive.ObjectID]models.Video{}
+	for cursor.Next(ctx) {
+		var video models.Video
+		if err := cursor.Decode(&video); err == nil {
+			found[video.ID] = video
+		}
+	}
+
+	// Deleted videos and videos the caller may not see are left out
+	videos := make([]models.Video, 0, len(items))
+	for _, item := range items {
+		if video, ok := found[item.VideoID]; ok && canView(video, user) {
+			videos = append(videos, video)
+		}
+	}
+
+	show := models.PodcastChannel{
+		Title:       playlist.Title,
+		Link:        requestBaseURL(r) + "/api/v1/playlists/" + playlist.ID.Hex(),
+		Description: playlist.Description,
+		ITunesType:  "serial",
+	}
+	if owner, err := findUser(ctx, playlist.OwnerID); err == nil {
+		show.ITunesAuthor = owner.Name
+	}
+	writePodcast(ctx, w, r, show, videos, playlist.UpdatedAt, user, true)
+}
+
+// podcastFile is the GridFS metadata an enclosure needs
+type podcastFile struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Filename string             `bson:"filename"`
+	Length   int64              `bson:"length"`
+	Metadata struct {
+		ContentType string `bson:"contentType"`
+	} `bson:"metadata"`
+}
+
+// enclosureType returns the media type of an episode file: the type stored at
+// upload, or the one its file name suggests when the upload did not name one
+func enclosureType(file podcastFile) string {
+	if contentType := file.Metadata.ContentType; contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(path.Ext(file.Filename)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// writePodcast renders the videos as the episodes of a show and serves the feed.
+// Numbered feeds give episodes their position as episode number. The feed body only
+// changes with its content, so its hash serves as ETag for conditional requests.
+func writePodcast(ctx context.Context, w http.ResponseWriter, r *http.Request, show models.PodcastChannel, videos []models.Video, modified time.Time, user *models.User, numbered bool) {
+	fileIDs := make([]primitive.ObjectID, 0, len(videos))
+	for _, video := range videos {
+		fileIDs = append(fileIDs, video.FileID)
+	}
+	cursor, err := config.DB.Collection("video.files").Find(ctx, bson.M{"_id": bson.M{"$in": fileIDs}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+	files := map[primitive.ObjectID]podcastFile{}
+	for cursor.Next(ctx) {
+		var file podcastFile
+		if err := cursor.Decode(&file); err == nil {
+			files[file.ID] = file
+		}
+	}
+
+	// Signed URLs expire at the end of a fixed window so the feed stays byte-identical within it
+	window := config.PodcastURLTTL / 2
+	if window <= 0 {
+		window = time.Second
+	}
+	expires := time.Now().Truncate(window).Add(config.PodcastURLTTL)
+
+	base := requestBaseURL(r)
+	show.Items = make([]models.PodcastItem, 0, len(videos))
+	for i, video := range videos {
+		file, ok := files[video.FileID]
+		if !ok {
+			continue
+		}
+		published := uploadTime(video)
+		if published.After(modified) {
+			modified = published
+		}
+
+		// Anonymous listeners can stream what anyone may view; other episodes need a signed URL
+		url := base + "/api/v1/video/" + video.ID.Hex()
+		if !canView(video, nil) {
+			url = signedVideoURL(r, signing.Grant{VideoID: video.ID.Hex(), FileID: video.FileID.Hex(), Expires: expires})
+		}
+		item := models.PodcastItem{
+			Title:       video.Title,
+			Link:        base + "/api/v1/videos/" + video.ID.Hex(),
+			Description: video.Description,
+			GUID:        models.PodcastGUID{Value: video.ID.Hex()},
+			PubDate:     published.UTC().Format(time.RFC1123Z),
+			Enclosure:   models.Enclosure{URL: url, Length: file.Length, Type: enclosureType(file)},
+		}
+		if video.Duration > 0 {
+			item.ITunesDuration = strconv.Itoa(int(video.Duration + 0.5))
+		}
+		if numbered {
+			item.ITunesEpisode = i + 1
+		}
+		if video.Audio != nil && video.Audio.ArtworkFileID != nil {
+			item.ITunesImage = &models.ITunesImage{Href: base + "/api/v1/videos/" + video.ID.Hex() + "/artwork"}
+		}
+		show.Items = append(show.Items, item)
+	}
+
+	if show.Description == "" {
+		show.Description = show.Title
+	}
+	show.ITunesSummary = show.Description
+	show.ITunesExplicit = "false"
+	show.Generator = "hub"
+	if config.PodcastCategory != "" {
+		show.ITunesCategory = &models.ITunesCategory{Text: config.PodcastCategory}
+	}
+	show.LastBuildDate = modified.UTC().Format(time.RFC1123Z)
+
+	feed := models.RSS{Version: "2.0", ITunes: models.ITunesNamespace, Channel: show}
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+	body := append([]byte(xml.Header), data...)
+	sum := sha256.Sum256(body)
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	if user != nil {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// uploadTime returns when a video was uploaded, falling back to its ID for old records
+func uploadTime(video models.Video) time.Time {
+	if t, err := time.Parse(time.RFC3339, video.UploadDate); err == nil {
+		return t
+	}
+	return video.ID.Timestamp()
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"hub/config"
+)
+
+// trustedProxy reports whether the address belongs to a configured reverse proxy
+func trustedProxy(ip net.IP) bool {
+	for _, network := range config.TrustedProxies {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the address of the connection a request came in on
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the address the request came from. Behind trusted proxies it
+// is the last X-Forwarded-For hop that is not one of them; entries further left
+// were supplied by the client and are not believed.
+func clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !trustedProxy(net.ParseIP(ip)) {
+		return ip
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop.String()
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the hub.
+// Forwarded scheme and host are only taken from trusted proxies.
+func requestBaseURL(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if trustedProxy(net.ParseIP(remoteIP(r))) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+			scheme = proto
+		}
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"hub/config"
+)
+
+func withTrustedProxies(t *testing.T, cidrs ...string) {
+	saved := config.TrustedProxies
+	t.Cleanup(func() { config.TrustedProxies = saved })
+	config.TrustedProxies = nil
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.TrustedProxies = append(config.TrustedProxies, network)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8")
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted sender", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entry", "10.0.0.1:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.0.0.1:5000", []string{"198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"separate headers", "10.0.0.1:5000", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1"},
+		{"only proxies", "10.0.0.1:5000", []string{"10.0.0.3"}, "10.0.0.3"},
+		{"garbage", "10.0.0.1:5000", []string{"not-an-ip"}, "10.0.0.1"},
+		{"no header", "10.0.0.1:5000", nil, "10.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remote
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r); got != test.want {
+				t.Errorf("clientIP = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRequestBaseURL(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8")
+	tests := []struct {
+		name   string
+		remote string
+		tls    bool
+		proto  string
+		host   string
+		want   string
+	}{
+		{"plain", "203.0.113.7:5000", false, "", "", "http://hub.example"},
+		{"tls", "203.0.113.7:5000", true, "", "", "https://hub.example"},
+		{"untrusted forwarded", "203.0.113.7:5000", false, "https", "evil.example", "http://hub.example"},
+		{"trusted forwarded", "10.0.0.1:5000", false, "https", "media.example", "https://media.example"},
+		{"trusted bogus scheme", "10.0.0.1:5000", false, "gopher", "", "http://hub.example"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://hub.example/", nil)
+			r.RemoteAddr = test.remote
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if test.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			if test.host != "" {
+				r.Header.Set("X-Forwarded-Host", test.host)
+			}
+			if got := requestBaseURL(r); got != test.want {
+				t.Errorf("requestBaseURL = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
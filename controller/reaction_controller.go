@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetReactions returns the view and reaction counts of a video
+// @Summary Get video engagement
+// @Description Returns the view count and the number of reactions per kind, plus the caller's own reaction
+// @Tags Reactions
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {object} models.ReactionSummary
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/reactions [get]
+func GetReactions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var reaction models.Reaction
+	if user != nil {
+		err := config.DB.Collection("reactions").FindOne(ctx, bson.M{"videoId": video.ID, "userId": user.ID}).Decode(&reaction)
+		if err != nil && err != mongo.ErrNoDocuments {
+			http.Error(w, "Failed to load reaction", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizeReactions(video, reaction.Kind))
+}
+
+// SetReaction sets the caller's reaction to a video
+// @Summary React to a video
+// @Description Sets the caller's reaction, replacing any earlier one. Repeating the same reaction changes nothing.
+// @Tags Reactions
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param reaction body models.ReactionInput true "Reaction"
+// @Success 200 {object} models.ReactionSummary
+// @Failure 400 {string} string "Invalid reaction"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/reaction [put]
+func SetReaction(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var input models.ReactionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || !models.ValidReaction(input.Kind) {
+		http.Error(w, "Invalid reaction", http.StatusBadRequest)
+		return
+	}
+
+	// The upsert returns the previous reaction, so the counters only move
+	// when the reaction actually changes
+	filter := bson.M{"videoId": video.ID, "userId": user.ID}
+	update := bson.M{
+		"$set":         bson.M{"kind": input.Kind},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	var previous models.Reaction
+	err := config.DB.Collection("reactions").FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent first reaction won the insert; apply ours on top of it
+		err = config.DB.Collection("reactions").FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		http.Error(w, "Failed to save reaction", http.StatusInternalServerError)
+		return
+	}
+
+	counts := bson.M{}
+	if previous.Kind != input.Kind {
+		counts["reactions."+input.Kind] = 1
+		if previous.Kind != "" {
+			counts["reactions."+previous.Kind] = -1
+		}
+	}
+	if err := adjustReactionCounts(ctx, video, counts); err != nil {
+		http.Error(w, "Failed to update reaction counts", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithReactions(ctx, w, video, input.Kind)
+}
+
+// DeleteReaction removes the caller's reaction to a video
+// @Summary Remove a reaction
+// @Description Removes the caller's reaction to a video. Removing a reaction that does not exist changes nothing.
+// @Tags Reactions
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {object} models.ReactionSummary
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/reaction [delete]
+func DeleteReaction(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var previous models.Reaction
+	err := config.DB.Collection("reactions").FindOneAndDelete(ctx, bson.M{"videoId": video.ID, "userId": user.ID}).Decode(&previous)
+	if err != nil && err != mongo.ErrNoDocuments {
+		http.Error(w, "Failed to remove reaction", http.StatusInternalServerError)
+		return
+	}
+	if previous.Kind != "" {
+		if err := adjustReactionCounts(ctx, video, bson.M{"reactions." + previous.Kind: -1}); err != nil {
+			http.Error(w, "Failed to update reaction counts", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondWithReactions(ctx, w, video, "")
+}
+
+// adjustReactionCounts applies counter increments to a video
+func adjustReactionCounts(ctx context.Context, video models.Video, counts bson.M) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	_, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, bson.M{"$inc": counts})
+	return err
+}
+
+// respondWithReactions reloads the counters of a video and writes its summary
+func respondWithReactions(ctx context.Context, w http.ResponseWriter, video models.Video, mine string) {
+	if updated, err := findVideo(ctx, video.ID.Hex()); err == nil {
+		video = updated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizeReactions(video, mine))
+}
+
+// summarizeReactions returns the engagement counters of a video
+func summarizeReactions(video models.Video, mine string) models.ReactionSummary {
+	summary := models.ReactionSummary{Views: video.Views, Reactions: map[string]int64{}, Mine: mine}
+	for kind, count := range video.Reactions {
+		if count > 0 {
+			summary.Reactions[kind] = count
+		}
+	}
+	return summary
+}
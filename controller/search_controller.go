@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/media"
+	"hub/models"
+	"hub/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// snippetWidth is the approximate length of a highlighted snippet in characters
+	snippetWidth = 160
+
+	// maxMemoryHits caps the ranked hits of the in-memory index that are checked against the filters
+	maxMemoryHits = 1000
+)
+
+// searchIndex serves searches when the memory backend is configured
+var searchIndex = search.NewIndex()
+
+// searchResult is a video decoded together with its text score
+type searchResult struct {
+	models.Video `bson:",inline"`
+	Score        float64 `bson:"score"`
+}
+
+// SearchVideos finds videos by title, description, tags and caption text
+// @Summary Search videos
+// @Description Full-text search over titles, descriptions, tags and captions, ranked by relevance with title matches counting the most.
+// @Description Only videos the caller may see in listings are returned. Snippets are HTML with matched words in <mark>.
+// @Tags Search
+// @Param q query string true "Search query"
+// @Param lang query string false "Stemming language, e.g. english or de (default from configuration); the in-memory backend only supports english"
+// @Param owner query string false "Only videos of this user ID"
+// @Param channel query string false "Only videos on this channel ID"
+// @Param tag query string false "Only videos with this tag"
+// @Param type query string false "video or audio"
+// @Param category query string false "Only videos in this category or its subcategories"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of results to skip"
+// @Produce json
+// @Success 200 {array} models.SearchResult
+// @Failure 400 {string} string "Query is required"
+// @Router /search [get]
+func SearchVideos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+	language := strings.ToLower(r.URL.Query().Get("lang"))
+	if config.SearchBackend == config.SearchBackendMemory && language != "" && !search.FallbackLanguage(language) {
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+	if language == "" {
+		language = config.SearchLanguage
+	}
+	if !search.SupportedLanguage(language) {
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+
+	user := optionalUser(ctx, r)
+	filters := bson.A{catalogFilter(ctx, r), listableFilter(user)}
+	limit, offset := pagination(r)
+
+	var found []searchResult
+	var err error
+	if config.SearchBackend == config.SearchBackendMemory {
+		found, err = searchMemory(ctx, query, filters, limit, offset)
+	} else {
+		found, err = searchText(ctx, query, language, filters, limit, offset)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]models.SearchResult, 0, len(found))
+	for _, result := range found {
+		results = append(results, models.SearchResult{
+			Video:      summarizeVideo(result.Video),
+			Score:      result.Score,
+			Highlights: highlightVideo(result.Video, query),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// searchText queries the MongoDB text index
+func searchText(ctx context.Context, query, language string, filters bson.A, limit, offset int64) ([]searchResult, error) {
+	filter := bson.M{
+		"$text": bson.M{"$search": query, "$language": language},
+		"$and":  filters,
+	}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetLimit(limit).SetSkip(offset)
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []searchResult
+	err = cursor.All(ctx, &results)
+	return results, err
+}
+
+// searchMemory ranks videos with the in-memory index and keeps those passing the filters
+func searchMemory(ctx context.Context, query string, filters bson.A, limit, offset int64) ([]searchResult, error) {
+	hits := searchIndex.Search(query)
+	if len(hits) > maxMemoryHits {
+		hits = hits[:maxMemoryHits]
+	}
+	ids := make([]primitive.ObjectID, 0, len(hits))
+	for _, hit := range hits {
+		if id, err := primitive.ObjectIDFromHex(hit.ID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}, "$and": filters}
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var videos []models.Video
+	if err := cursor.All(ctx, &videos); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Video, len(videos))
+	for _, video := range videos {
+		byID[video.ID.Hex()] = video
+	}
+
+	var results []searchResult
+	for _, hit := range hits {
+		if video, ok := byID[hit.ID]; ok {
+			results = append(results, searchResult{Video: video, Score: hit.Score})
+		}
+	}
+	if offset >= int64(len(results)) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if int64(len(results)) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// highlightVideo returns snippets of the fields of a video that match the query
+func highlightVideo(video models.Video, query string) []models.Highlight {
+	highlights := []models.Highlight{}
+	for _, field := range []struct{ name, text string }{
+		{"title", video.Title},
+		{"description", video.Description},
+		{"tags", strings.Join(video.Tags, " ")},
+		{"captions", video.CaptionText},
+	} {
+		if snippet, ok := search.Highlight(field.text, query, snippetWidth); ok {
+			highlights = append(highlights, models.Highlight{Field: field.name, Snippet: snippet})
+		}
+	}
+	return highlights
+}
+
+// LoadSearchIndex fills the in-memory search index from the database when the
+// memory backend is configured
+func LoadSearchIndex() {
+	if config.SearchBackend != config.SearchBackendMemory {
+		return
+	}
+	if !search.FallbackLanguage(config.SearchLanguage) {
+		log.Printf("The in-memory search backend only stems English; HUB_SEARCH_LANGUAGE=%s is ignored", config.SearchLanguage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, bson.M{})
+	if err != nil {
+		log.Fatalf("Failed to load search index: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var video models.Video
+		if err := cursor.Decode(&video); err != nil {
+			continue
+		}
+		indexVideo(video)
+		count++
+	}
+	log.Printf("Indexed %d videos for search", count)
+}
+
+// indexVideo adds or refreshes a video in the in-memory search index
+func indexVideo(video models.Video) {
+	if config.SearchBackend != config.SearchBackendMemory {
+		return
+	}
+	weight := func(field string) float64 { return float64(config.SearchWeights[field]) }
+	searchIndex.Add(video.ID.Hex(),
+		search.Field{Text: video.Title, Weight: weight("title")},
+		search.Field{Text: video.Description, Weight: weight("description")},
+		search.Field{Text: strings.Join(video.Tags, " "), Weight: weight("tags")},
+		search.Field{Text: video.CaptionText, Weight: weight("captionText")},
+	)
+}
+
+// reindexVideos refreshes the videos matching the filter in the in-memory search index
+func reindexVideos(ctx context.Context, filter bson.M) {
+	if config.SearchBackend != config.SearchBackendMemory {
+		return
+	}
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter)
+	if err != nil {
+		log.Printf("Failed to reindex videos: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var video models.Video
+		if err := cursor.Decode(&video); err == nil {
+			indexVideo(video)
+		}
+	}
+}
+
+// unindexVideo removes a deleted video from the in-memory search index
+func unindexVideo(videoID primitive.ObjectID) {
+	if config.SearchBackend != config.SearchBackendMemory {
+		return
+	}
+	searchIndex.Remove(videoID.Hex())
+}
+
+// refreshCaptionText stores the text of all caption tracks of a video on the
+// video record so searches match spoken words
+func refreshCaptionText(ctx context.Context, videoID primitive.ObjectID) error {
+	cursor, err := config.DB.Collection("captions").Find(ctx, bson.M{"videoId": videoID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var captions []models.Caption
+	if err := cursor.All(ctx, &captions); err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, caption := range captions {
+		cues, err := media.ParseCaptions(caption.Content)
+		if err != nil {
+			continue
+		}
+		for _, cue := range cues {
+			lines = append(lines, cue.Text)
+		}
+	}
+
+	update := bson.M{"$set": bson.M{"captionText": strings.Join(lines, "\n")}}
+	if len(lines) == 0 {
+		update = bson.M{"$unset": bson.M{"captionText": ""}}
+	}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": videoID}, update); err != nil {
+		return err
+	}
+
+	video, err := findVideo(ctx, videoID.Hex())
+	if err != nil {
+		return err
+	}
+	indexVideo(video)
+	return nil
+}
@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hub/config"
+	"hub/models"
+	"hub/signing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateSignedURL mints a time-limited playback URL for a video
+// @Summary Create a signed playback URL
+// @Description Returns an HMAC-signed URL that streams the video without auth headers until it expires.
+// @Description The URL can be bound to a client network and a byte range.
+// @Tags Videos
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param request body models.SignedURLRequest false "URL restrictions"
+// @Success 201 {object} models.SignedURL
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/signed-url [post]
+func CreateSignedURL(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := requireUser(ctx, w, r); !ok {
+		return
+	}
+	video, _, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var body models.SignedURLRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ttl := time.Hour
+	if body.TTLSeconds > 0 {
+		ttl = time.Duration(body.TTLSeconds) * time.Second
+	}
+	if ttl > config.SignedURLMaxTTL {
+		http.Error(w, fmt.Sprintf("TTL exceeds the maximum of %s", config.SignedURLMaxTTL), http.StatusBadRequest)
+		return
+	}
+
+	grant := signing.Grant{
+		VideoID: video.ID.Hex(),
+		FileID:  video.FileID.Hex(),
+		Expires: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if body.IPRange != "" {
+		_, network, err := net.ParseCIDR(body.IPRange)
+		if err != nil {
+			http.Error(w, "Invalid IP range", http.StatusBadRequest)
+			return
+		}
+		grant.IPRange = network
+	}
+	if body.ByteRange != "" {
+		byteRange, err := signing.ParseByteRange(body.ByteRange)
+		if err != nil {
+			http.Error(w, "Invalid byte range", http.StatusBadRequest)
+			return
+		}
+		grant.Range = byteRange
+	}
+
+	signed := models.SignedURL{
+		URL:       signedVideoURL(r, grant),
+		ExpiresAt: grant.Expires,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(signed)
+}
+
+// signedVideoURL returns the playback URL authorised by a grant, signed with the current key
+func signedVideoURL(r *http.Request, grant signing.Grant) string {
+	query := signing.Sign(grant, config.SigningKeyID, config.SigningKeys[config.SigningKeyID])
+	return requestBaseURL(r) + "/api/v1/video/" + grant.VideoID + "?" + query.Encode()
+}
+
+// serveSignedVideo streams a video authorised by a signed URL. The signature
+// names the file to stream, so no video record is loaded.
+func serveSignedVideo(w http.ResponseWriter, r *http.Request, videoID string) {
+	grant, err := signing.Verify(r.URL.Query(), videoID, net.ParseIP(clientIP(r)), config.SigningKeys, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid signed URL: %v", err), http.StatusForbidden)
+		return
+	}
+
+	fileID, err := primitive.ObjectIDFromHex(grant.FileID)
+	if err != nil {
+		http.Error(w, "Invalid signed URL", http.StatusForbidden)
+		return
+	}
+
+	if videoObjectID, err := primitive.ObjectIDFromHex(videoID); err == nil && startsPlayback(r) {
+		recordView(r, videoObjectID, nil)
+	}
+
+	if grant.Range != nil && !restrictRange(r, *grant.Range) {
+		http.Error(w, "Requested range is outside the signed range", http.StatusForbidden)
+		return
+	}
+
+	streamFile(w, r, fileID)
+}
+
+// restrictRange rewrites the Range header so it stays inside the allowed range.
+// A suffix range asks for the last bytes of the allowed range. It returns false
+// for requests that ask for bytes outside of it.
+func restrictRange(r *http.Request, allowed signing.ByteRange) bool {
+	header := r.Header.Get("Range")
+	if header == "" {
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", allowed.Start, allowed.End))
+		return true
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return false
+	}
+	startValue, endValue, _ := strings.Cut(spec, "-")
+	if startValue == "" {
+		length, err := strconv.ParseInt(endValue, 10, 64)
+		if err != nil || length <= 0 {
+			return false
+		}
+		start := allowed.Start
+		if length <= allowed.End-allowed.Start {
+			start = allowed.End - length + 1
+		}
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, allowed.End))
+		return true
+	}
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < allowed.Start || start > allowed.End {
+		return false
+	}
+	end := allowed.End
+	if endValue != "" {
+		if end, err = strconv.ParseInt(endValue, 10, 64); err != nil || end < start {
+			return false
+		}
+		if end > allowed.End {
+			end = allowed.End
+		}
+	}
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	return true
+}
+
+// streamFile serves a file from the video bucket with support for range requests
+func streamFile(w http.ResponseWriter, r *http.Request, fileID primitive.ObjectID) {
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	downloadStream, err := bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Video not found or failed to open stream: %v", err), http.StatusNotFound)
+		return
+	}
+	file := downloadStream.GetFile()
+	content := &gridfsReadSeeker{bucket: bucket, id: fileID, size: file.Length, chunkSize: int64(file.ChunkSize), stream: downloadStream}
+	defer content.Close()
+
+	// Audio keeps the type recorded at upload; everything else plays as MP4
+	contentType := "video/mp4"
+	var metadata struct {
+		ContentType string `bson:"contentType"`
+	}
+	if file.Metadata != nil {
+		bson.Unmarshal(file.Metadata, &metadata)
+	}
+	if strings.HasPrefix(metadata.ContentType, "audio/") {
+		contentType = metadata.ContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, file.Name, file.UploadDate, content)
+}
+
+// gridfsReadSeeker adapts a GridFS file to io.ReadSeeker. Reads continue the
+// download stream it was opened with until the first seek; after that the
+// chunks are queried from the one holding the new offset, so a seek does not
+// download the part of the file before it.
+type gridfsReadSeeker struct {
+	bucket    *gridfs.Bucket
+	id        primitive.ObjectID
+	size      int64
+	chunkSize int64
+	offset    int64
+	stream    *gridfs.DownloadStream
+	chunks    *mongo.Cursor
+	index     int32  // Next chunk expected from chunks
+	chunk     []byte // Unread rest of the current chunk
+}
+
+func (s *gridfsReadSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.stream != nil {
+		n, err := s.stream.Read(p)
+		s.offset += int64(n)
+		return n, err
+	}
+	if len(s.chunk) == 0 {
+		if err := s.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.chunk)
+	s.chunk = s.chunk[n:]
+	s.offset += int64(n)
+	return n, nil
+}
+
+// nextChunk loads the chunk holding the current offset, starting the chunk
+// query at that chunk when none is open
+func (s *gridfsReadSeeker) nextChunk() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if s.chunks == nil {
+		s.index = int32(s.offset / s.chunkSize)
+		filter := bson.M{"files_id": s.id, "n": bson.M{"$gte": s.index}}
+		opts := options.Find().SetSort(bson.D{{Key: "n", Value: 1}})
+		cursor, err := s.bucket.GetChunksCollection().Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		s.chunks = cursor
+	}
+	if !s.chunks.Next(ctx) {
+		if err := s.chunks.Err(); err != nil {
+			return err
+		}
+		return gridfs.ErrWrongIndex
+	}
+	var chunk struct {
+		N    int32  `bson:"n"`
+		Data []byte `bson:"data"`
+	}
+	if err := s.chunks.Decode(&chunk); err != nil {
+		return err
+	}
+	skip := s.offset - int64(s.index)*s.chunkSize
+	if chunk.N != s.index || skip >= int64(len(chunk.Data)) {
+		return gridfs.ErrWrongIndex
+	}
+	s.index++
+	s.chunk = chunk.Data[skip:]
+	return nil
+}
+
+func (s *gridfsReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = s.offset + offset
+	case io.SeekEnd:
+		target = s.size + offset
+	default:
+		return s.offset, fmt.Errorf("invalid whence %d", whence)
+	}
+	if target < 0 {
+		return s.offset, fmt.Errorf("negative position %d", target)
+	}
+	if target != s.offset {
+		s.Close()
+		s.offset = target
+	}
+	return target, nil
+}
+
+// Close releases the current download stream or chunk query
+func (s *gridfsReadSeeker) Close() error {
+	var err error
+	if s.stream != nil {
+		err = s.stream.Close()
+		s.stream = nil
+	}
+	if s.chunks != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if closeErr := s.chunks.Close(ctx); err == nil {
+			err = closeErr
+		}
+		s.chunks = nil
+	}
+	s.chunk = nil
+	return err
+}
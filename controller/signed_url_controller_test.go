@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"hub/config"
+	"hub/signing"
+
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRestrictRange(t *testing.T) {
+	allowed := signing.ByteRange{Start: 100, End: 199}
+	tests := []struct {
+		header string
+		want   string // Rewritten header, empty when refused
+	}{
+		{"", "bytes=100-199"},
+		{"bytes=100-", "bytes=100-199"},
+		{"bytes=150-160", "bytes=150-160"},
+		{"bytes=150-500", "bytes=150-199"},
+		{"bytes=0-150", ""},
+		{"bytes=200-", ""},
+		{"bytes=160-150", ""},
+		{"bytes=-10", "bytes=190-199"},
+		{"bytes=-100", "bytes=100-199"},
+		{"bytes=-5000", "bytes=100-199"},
+		{"bytes=-0", ""},
+		{"bytes=-", ""},
+		{"bytes=100-110,120-130", ""},
+		{"items=0-1", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set("Range", test.header)
+		}
+		ok := restrictRange(r, allowed)
+		if ok != (test.want != "") {
+			t.Errorf("restrictRange(%q) = %v, want %v", test.header, ok, test.want != "")
+			continue
+		}
+		if ok && r.Header.Get("Range") != test.want {
+			t.Errorf("restrictRange(%q) set %q, want %q", test.header, r.Header.Get("Range"), test.want)
+		}
+	}
+}
+
+func TestGridFSReadSeeker(t *testing.T) {
+	useTestDB(t)
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video").SetChunkSizeBytes(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	fileID, err := bucket.UploadFromStream("seek.bin", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := stream.GetFile()
+	content := &gridfsReadSeeker{bucket: bucket, id: fileID, size: file.Length, chunkSize: int64(file.ChunkSize), stream: stream}
+	defer content.Close()
+
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(content, head); err != nil || !bytes.Equal(head, data[:10]) {
+		t.Fatalf("read from the start = %v, %v", head, err)
+	}
+	for _, offset := range []int64{50, 15, 16, 99, 0, 37} {
+		if _, err := content.Seek(offset, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		rest, err := io.ReadAll(content)
+		if err != nil {
+			t.Fatalf("read from %d: %v", offset, err)
+		}
+		if !bytes.Equal(rest, data[offset:]) {
+			t.Fatalf("read from %d = %v, want %v", offset, rest, data[offset:])
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subscribe follows a channel
+// @Summary Subscribe to a channel
+// @Description Subscribes the caller to a channel. Subscribing again only updates the notification preference.
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Param subscription body models.SubscriptionInput false "Notification preference"
+// @Success 200 {object} models.Subscription
+// @Failure 400 {string} string "Invalid notification preference"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/subscription [put]
+func Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	input := models.SubscriptionInput{Notify: models.NotifyAll}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		if input.Notify == "" {
+			input.Notify = models.NotifyAll
+		}
+	}
+	if !models.ValidNotify(input.Notify) {
+		http.Error(w, "Invalid notification preference", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"userId": user.ID, "channelId": channel.ID}
+	update := bson.M{
+		"$set":         bson.M{"notify": input.Notify},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	result, err := config.DB.Collection("subscriptions").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	}
+
+	// Only a new subscription changes the count and the feed
+	if result != nil && result.UpsertedCount > 0 {
+		adjustSubscriberCount(ctx, channel.ID, 1)
+		invalidateTimeline(ctx, user.ID)
+		notify(ctx, models.Notification{
+			UserID:    channel.OwnerID,
+			Kind:      models.NotificationSubscriber,
+			Message:   fmt.Sprintf("%s subscribed to %s", user.Name, channel.Name),
+			ActorID:   user.ID,
+			ChannelID: &channel.ID,
+		})
+	}
+
+	var subscription models.Subscription
+	if err := config.DB.Collection("subscriptions").FindOne(ctx, filter).Decode(&subscription); err != nil {
+		http.Error(w, "Failed to load subscription", http.StatusInternalServerError)
+		return
+	}
+	subscription.Channel = &channel
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// Unsubscribe stops following a channel
+// @Summary Unsubscribe from a channel
+// @Description Removes the caller's subscription to a channel. Unsubscribing twice changes nothing.
+// @Tags Subscriptions
+// @Param handle path string true "Channel handle"
+// @Success 204 {string} string "Unsubscribed"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Channel not found"
+// @Router /channels/{handle}/subscription [delete]
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	channel, err := findChannel(ctx, mux.Vars(r)["handle"])
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := config.DB.Collection("subscriptions").DeleteOne(ctx, bson.M{"userId": user.ID, "channelId": channel.ID})
+	if err != nil {
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount > 0 {
+		adjustSubscriberCount(ctx, channel.ID, -1)
+		invalidateTimeline(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMySubscriptions lists the channels the caller follows
+// @Summary List my subscriptions
+// @Description Lists the caller's subscriptions with their channels, most recent first
+// @Tags Subscriptions
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of subscriptions to skip"
+// @Produce json
+// @Success 200 {array} models.Subscription
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/subscriptions [get]
+func GetMySubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset)
+	cursor, err := config.DB.Collection("subscriptions").Find(ctx, bson.M{"userId": user.ID}, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	subscriptions := []models.Subscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range subscriptions {
+		var channel models.Channel
+		if err := config.DB.Collection("channels").FindOne(ctx, bson.M{"_id": subscriptions[i].ChannelID}).Decode(&channel); err == nil {
+			subscriptions[i].Channel = &channel
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// GetFeed lists new uploads from the channels the caller follows
+// @Summary Get my subscription feed
+// @Description Lists videos published on subscribed channels, newest first. Pass nextCursor from a page as cursor to get the following page.
+// @Tags Subscriptions
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Produce json
+// @Success 200 {object} models.FeedPage
+// @Failure 400 {string} string "Invalid cursor"
+// @Failure 401 {string} string "Authentication required"
+// @Router /feed [get]
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// The cursor is the ID of the last video of the previous page; IDs grow with upload time
+	var before *primitive.ObjectID
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		before = &id
+	}
+	limit, _ := pagination(r)
+
+	channelIDs, err := subscribedChannels(ctx, user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var videos []models.Video
+	if len(channelIDs) >= config.FeedHeavySubscriptions {
+		videos, err = readTimeline(ctx, &user, channelIDs, before, limit+1)
+	} else {
+		videos, err = fanOutFeed(ctx, &user, channelIDs, before, limit+1)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// One extra video tells whether another page follows
+	page := models.FeedPage{Videos: videos}
+	if int64(len(videos)) > limit {
+		page.Videos = videos[:limit]
+		page.NextCursor = page.Videos[limit-1].ID.Hex()
+	}
+	if page.Videos == nil {
+		page.Videos = []models.Video{}
+	}
+	attachResumePositions(ctx, &user, page.Videos)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// subscribedChannels returns the IDs of the channels a user follows
+func subscribedChannels(ctx context.Context, userID string) ([]primitive.ObjectID, error) {
+	values, err := config.DB.Collection("subscriptions").Distinct(ctx, "channelId", bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// fanOutFeed queries the newest visible videos of the channels directly
+func fanOutFeed(ctx context.Context, user *models.User, channelIDs []primitive.ObjectID, before *primitive.ObjectID, limit int64) ([]models.Video, error) {
+	if len(channelIDs) == 0 {
+		return nil, nil
+	}
+	scope := bson.M{"channelId": bson.M{"$in": channelIDs}}
+	if before != nil {
+		scope["_id"] = bson.M{"$lt": *before}
+	}
+	filter := bson.M{"$and": bson.A{scope, listableFilter(user)}}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var videos []models.Video
+	err = cursor.All(ctx, &videos)
+	return videos, err
+}
+
+// readTimeline pages through the cached timeline of a user, rebuilding it when
+// it expired. Videos are checked for visibility again on every read.
+func readTimeline(ctx context.Context, user *models.User, channelIDs []primitive.ObjectID, before *primitive.ObjectID, limit int64) ([]models.Video, error) {
+	var timeline models.Timeline
+	err := config.DB.Collection("timelines").FindOne(ctx, bson.M{"_id": user.ID}).Decode(&timeline)
+	if err != nil || time.Now().After(timeline.ExpiresAt) {
+		if timeline, err = buildTimeline(ctx, user, channelIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := timeline.VideoIDs
+	if before != nil {
+		start := len(ids)
+		for i, id := range ids {
+			if bytes.Compare(id[:], before[:]) < 0 {
+				start = i
+				break
+			}
+		}
+		ids = ids[start:]
+	}
+
+	var videos []models.Video
+	for len(ids) > 0 && int64(len(videos)) < limit {
+		chunk := ids
+		if int64(len(chunk)) > limit {
+			chunk = chunk[:limit]
+		}
+		ids = ids[len(chunk):]
+
+		filter := bson.M{"$and": bson.A{bson.M{"_id": bson.M{"$in": chunk}}, listableFilter(user)}}
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+		cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		var found []models.Video
+		err = cursor.All(ctx, &found)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, found...)
+	}
+	if int64(len(videos)) > limit {
+		videos = videos[:limit]
+	}
+	return videos, nil
+}
+
+// buildTimeline materialises the newest feed videos of a user
+func buildTimeline(ctx context.Context, user *models.User, channelIDs []primitive.ObjectID) (models.Timeline, error) {
+	timeline := models.Timeline{UserID: user.ID, ExpiresAt: time.Now().Add(config.FeedTimelineTTL)}
+
+	filter := bson.M{"$and": bson.A{bson.M{"channelId": bson.M{"$in": channelIDs}}, listableFilter(user)}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(config.FeedTimelineSize)).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		return timeline, err
+	}
+	defer cursor.Close(ctx)
+
+	timeline.VideoIDs = []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		var video struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&video); err == nil {
+			timeline.VideoIDs = append(timeline.VideoIDs, video.ID)
+		}
+	}
+
+	_, err = config.DB.Collection("timelines").ReplaceOne(ctx, bson.M{"_id": user.ID}, timeline, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to cache timeline of user %s: %v", user.ID, err)
+	}
+	return timeline, nil
+}
+
+// invalidateTimeline drops the cached timeline of a user after their subscriptions changed
+func invalidateTimeline(ctx context.Context, userID string) {
+	if _, err := config.DB.Collection("timelines").DeleteOne(ctx, bson.M{"_id": userID}); err != nil {
+		log.Printf("Failed to invalidate timeline of user %s: %v", userID, err)
+	}
+}
+
+// addToTimelines merges a new video into the cached timelines of the channel's
+// subscribers, so they see it without waiting for their timeline to expire.
+// Timelines keep their newest-first order and size; visibility is checked on read.
+func addToTimelines(ctx context.Context, video models.Video) {
+	if video.ChannelID == nil {
+		return
+	}
+	users, err := config.DB.Collection("subscriptions").Distinct(ctx, "userId", bson.M{"channelId": *video.ChannelID})
+	if err != nil || len(users) == 0 {
+		return
+	}
+	filter := bson.M{"_id": bson.M{"$in": users}, "videoIds": bson.M{"$ne": video.ID}}
+	update := bson.M{"$push": bson.M{"videoIds": bson.M{
+		"$each":  bson.A{video.ID},
+		"$sort":  -1,
+		"$slice": config.FeedTimelineSize,
+	}}}
+	if _, err := config.DB.Collection("timelines").UpdateMany(ctx, filter, update); err != nil {
+		log.Printf("Failed to add video %s to timelines: %v", video.ID.Hex(), err)
+	}
+}
+
+// removeSubscriptions deletes the subscriptions of a deleted channel and drops
+// the cached timelines of its subscribers
+func removeSubscriptions(ctx context.Context, channelID primitive.ObjectID) {
+	filter := bson.M{"channelId": channelID}
+	if users, err := config.DB.Collection("subscriptions").Distinct(ctx, "userId", filter); err == nil && len(users) > 0 {
+		config.DB.Collection("timelines").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": users}})
+	}
+	if _, err := config.DB.Collection("subscriptions").DeleteMany(ctx, filter); err != nil {
+		log.Printf("Failed to delete subscriptions of channel %s: %v", channelID.Hex(), err)
+	}
+}
+
+// adjustSubscriberCount changes the subscriber counter of a channel
+func adjustSubscriberCount(ctx context.Context, channelID primitive.ObjectID, delta int64) {
+	_, err := config.DB.Collection("channels").UpdateOne(ctx, bson.M{"_id": channelID}, bson.M{"$inc": bson.M{"subscriberCount": delta}})
+	if err != nil {
+		log.Printf("Failed to update subscriber count of channel %s: %v", channelID.Hex(), err)
+	}
+}
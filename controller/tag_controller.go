@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ListTags lists tags with the number of videos carrying them
+// @Summary List and autocomplete tags
+// @Description Lists the tags of the videos the caller may see in listings, most used first. With a prefix it serves autocomplete.
+// @Tags Tags
+// @Param prefix query string false "Only tags starting with this text"
+// @Param limit query int false "Number of tags (default 20, max 100)"
+// @Produce json
+// @Success 200 {array} models.TagCount
+// @Router /tags [get]
+func ListTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	match := listableFilter(optionalUser(ctx, r))
+	tagMatch := bson.M{}
+	if prefix := models.NormalizeTag(r.URL.Query().Get("prefix")); prefix != "" {
+		tagMatch["tags"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	// Matching the prefix before unwinding lets the tags index narrow the videos
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$and": bson.A{match, tagMatch}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$match", Value: tagMatch}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := config.DB.Collection("videos").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	tags := []models.TagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// SetVideoTags replaces the tags of a video
+// @Summary Set video tags
+// @Description Replaces the tags of a video (owner or admin only). Tags are lower-cased and their words joined with hyphens.
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param tags body models.TagsUpdate true "Tags"
+// @Success 200 {object} models.TagsUpdate
+// @Failure 400 {string} string "Invalid tags"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/tags [put]
+func SetVideoTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, _, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var body models.TagsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	tags, err := models.NormalizeTags(body.Tags)
+	if err != nil {
+		http.Error(w, "Invalid tags: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"tags": tags}}
+	if len(tags) == 0 {
+		update = bson.M{"$unset": bson.M{"tags": ""}}
+	}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, update); err != nil {
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+	video.Tags = tags
+	indexVideo(video)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TagsUpdate{Tags: tags})
+}
+
+// RetagVideos adds and removes tags on many videos at once
+// @Summary Bulk retag videos
+// @Description Adds and removes tags on the listed videos, or on every video carrying a tag. Owners change their own videos, admins any video.
+// @Description Renaming a tag is a retag selecting the old tag, removing it and adding the new one. Tags beyond the per-video limit are not added.
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param retag body models.Retag true "Selection and tag changes"
+// @Success 200 {object} models.RetagResult
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Router /videos/retag [post]
+func RetagVideos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var body models.Retag
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	add, err := models.NormalizeTags(body.Add)
+	if err != nil {
+		http.Error(w, "Invalid tags: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	remove := []string{}
+	for _, tag := range body.Remove {
+		if tag = models.NormalizeTag(tag); tag != "" {
+			remove = append(remove, tag)
+		}
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		http.Error(w, "Nothing to add or remove", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{}
+	switch {
+	case len(body.VideoIDs) > 0:
+		ids := make([]primitive.ObjectID, 0, len(body.VideoIDs))
+		for _, id := range body.VideoIDs {
+			objectID, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				http.Error(w, "Invalid video ID", http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, objectID)
+		}
+		filter["_id"] = bson.M{"$in": ids}
+	case models.NormalizeTag(body.Tag) != "":
+		filter["tags"] = models.NormalizeTag(body.Tag)
+	default:
+		http.Error(w, "Select videos by videoIds or tag", http.StatusBadRequest)
+		return
+	}
+	if user.Role != models.RoleAdmin {
+		filter["ownerId"] = user.ID
+	}
+
+	// Resolve the selection first; selecting by a tag that is being removed
+	// would otherwise lose track of the changed videos
+	ids, err := config.DB.Collection("videos").Distinct(ctx, "_id", filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(ids) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.RetagResult{})
+		return
+	}
+
+	// One pipeline update removes, then appends the new tags in order, so each
+	// video is written once and unchanged videos are not counted as modified
+	kept := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$tags", bson.A{}}},
+		"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", remove}}}},
+	}}
+	merged := bson.M{"$let": bson.M{
+		"vars": bson.M{"kept": kept},
+		"in": bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{"$$kept", bson.M{"$filter": bson.M{
+				"input": add,
+				"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", "$$kept"}}}},
+			}}}},
+			models.MaxTags,
+		}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"tags": merged}}}}
+	selected := bson.M{"_id": bson.M{"$in": ids}}
+	result, err := config.DB.Collection("videos").UpdateMany(ctx, selected, update)
+	if err != nil {
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+	reindexVideos(ctx, selected)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RetagResult{Matched: result.MatchedCount, Modified: result.ModifiedCount})
+}
@@ -3,17 +3,24 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"hub/config"
 	"hub/models"
 
+	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// errQuotaExceeded is returned when an upload does not fit in the user's quota
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
 // @Summary Get Users
 // @Description Get all users
 // @Tags Users
@@ -66,8 +73,21 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Set the creation timestamp
 	user.CreatedAt = time.Now()
 
+	// Roles and quotas are not self-service; the very first user administers the hub
+	user.Role = models.RoleUser
+	user.QuotaBytes = nil
+	user.UsedBytes = 0
+	user.Groups = nil
+	if count, err := collection.EstimatedDocumentCount(ctx); err == nil && count == 0 && claimBootstrapAdmin(ctx) {
+		user.Role = models.RoleAdmin
+	}
+
 	// Insert the user into the database
 	result, err := collection.InsertOne(ctx, user)
+	if err != nil && user.Role == models.RoleAdmin {
+		// Give the claim back so the next sign-up can still become the admin
+		config.DB.Collection("settings").DeleteOne(ctx, bson.M{"_id": "bootstrap"})
+	}
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
@@ -80,8 +100,260 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to retrieve inserted ID", http.StatusInternalServerError)
 		return
 	}
+	go emitEvent(models.EventUserCreated, models.UserEventData{ID: user.ID, Name: user.Name, Username: user.Username, Role: user.Role})
+
+	// Sign-ups act for themselves; an account made by a signed-in user names them
+	actorID := user.ID
+	if creator := optionalUser(ctx, r); creator != nil {
+		actorID = creator.ID
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: actorID, Action: models.AuditUserCreated, TargetType: "user", TargetID: user.ID, Changes: auditChanges(nil, user)})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
+
+// claimBootstrapAdmin reports whether this sign-up is the one that becomes the
+// first admin. Racing sign-ups on an empty hub all see no users; only the one
+// whose upsert creates the singleton settings document wins.
+func claimBootstrapAdmin(ctx context.Context) bool {
+	result, err := config.DB.Collection("settings").UpdateOne(ctx,
+		bson.M{"_id": "bootstrap"},
+		bson.M{"$setOnInsert": bson.M{"adminClaimedAt": time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		// A concurrent upsert of the same document fails with a duplicate key
+		return false
+	}
+	return result.UpsertedCount == 1
+}
+
+// @Summary Get My Storage Usage
+// @Description Get the storage used by the authenticated user and their quota
+// @Tags Users
+// @Produce json
+// @Success 200 {object} models.Usage
+// @Failure 401 {string} string "Authentication required"
+// @Router /users/me/usage [get]
+func GetMyUsage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	count, err := config.DB.Collection("videos").CountDocuments(ctx, bson.M{"ownerId": user.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	usage := models.Usage{
+		UsedBytes:      user.UsedBytes,
+		QuotaBytes:     effectiveQuota(user),
+		VideoCount:     count,
+		MaxUploadBytes: config.MaxUploadBytes,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// @Summary Set User Quota
+// @Description Override the storage quota of a user (admin only). A null quota falls back to the role quota, 0 means unlimited.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param quota body models.QuotaUpdate true "Quota override"
+// @Success 200 {object} models.Usage
+// @Failure 400 {string} string "Invalid input"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /users/{id}/quota [put]
+func SetUserQuota(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var body models.QuotaUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || (body.QuotaBytes != nil && *body.QuotaBytes < 0) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	user, err := findUser(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	userID, _ := primitive.ObjectIDFromHex(user.ID)
+
+	update := bson.M{"$set": bson.M{"quotaBytes": body.QuotaBytes}}
+	if body.QuotaBytes == nil {
+		update = bson.M{"$unset": bson.M{"quotaBytes": ""}}
+	}
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+		http.Error(w, "Failed to update quota", http.StatusInternalServerError)
+		return
+	}
+	before := user
+	user.QuotaBytes = body.QuotaBytes
+	recordAudit(ctx, r, models.AuditEntry{ActorID: admin.ID, Action: models.AuditUserQuotaChanged, TargetType: "user", TargetID: user.ID, Changes: auditChanges(before, user)})
+
+	usage := models.Usage{UsedBytes: user.UsedBytes, QuotaBytes: effectiveQuota(user), MaxUploadBytes: config.MaxUploadBytes}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// @Summary Recalculate User Usage
+// @Description Recompute a user's storage usage from their videos (admin only)
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.Usage
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /users/{id}/usage/recalculate [post]
+func RecalculateUsage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+
+	user, err := findUser(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Sum the GridFS lengths of the files behind the user's videos
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"ownerId": user.ID}}},
+		{{Key: "$lookup", Value: bson.M{"from": "video.files", "localField": "fileId", "foreignField": "_id", "as": "file"}}},
+		{{Key: "$unwind", Value: "$file"}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "bytes": bson.M{"$sum": "$file.length"}, "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := config.DB.Collection("videos").Aggregate(ctx, pipeline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var totals struct {
+		Bytes int64 `bson:"bytes"`
+		Count int64 `bson:"count"`
+	}
+	if cursor.Next(ctx) {
+		cursor.Decode(&totals)
+	}
+
+	userID, _ := primitive.ObjectIDFromHex(user.ID)
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"usedBytes": totals.Bytes}}); err != nil {
+		http.Error(w, "Failed to update usage", http.StatusInternalServerError)
+		return
+	}
+	user.UsedBytes = totals.Bytes
+
+	usage := models.Usage{UsedBytes: user.UsedBytes, QuotaBytes: effectiveQuota(user), VideoCount: totals.Count, MaxUploadBytes: config.MaxUploadBytes}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// @Summary Set User Groups
+// @Description Replace the group memberships used by restricted video visibility (admin only)
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param groups body models.GroupsUpdate true "Groups"
+// @Success 200 {object} models.User
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /users/{id}/groups [put]
+func SetUserGroups(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var body models.GroupsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	user, err := findUser(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	userID, _ := primitive.ObjectIDFromHex(user.ID)
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"groups": body.Groups}}); err != nil {
+		http.Error(w, "Failed to update groups", http.StatusInternalServerError)
+		return
+	}
+	before := user
+	user.Groups = body.Groups
+	recordAudit(ctx, r, models.AuditEntry{ActorID: admin.ID, Action: models.AuditUserGroupsChanged, TargetType: "user", TargetID: user.ID, Changes: auditChanges(before, user)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// effectiveQuota returns the user's quota override or their role quota, 0 means unlimited
+func effectiveQuota(user models.User) int64 {
+	if user.QuotaBytes != nil {
+		return *user.QuotaBytes
+	}
+	return config.QuotaForRole(user.Role)
+}
+
+// reserveStorage adds size bytes to the user's usage unless that would exceed their quota.
+// The check and the increment happen in a single update so concurrent uploads cannot overrun it.
+func reserveStorage(ctx context.Context, user models.User, size int64) error {
+	userID, err := primitive.ObjectIDFromHex(user.ID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": userID}
+	if quota := effectiveQuota(user); quota > 0 {
+		filter["$expr"] = bson.M{"$lte": bson.A{bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$usedBytes", 0}}, size}}, quota}}
+	}
+
+	result, err := config.DB.Collection("users").UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"usedBytes": size}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// releaseStorage returns size bytes to the user's quota
+func releaseStorage(userID string, size int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$inc": bson.M{"usedBytes": -size}}); err != nil {
+		log.Printf("Failed to release %d bytes for user %s: %v", size, userID, err)
+	}
+}
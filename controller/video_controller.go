@@ -2,11 +2,24 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"hub/config"
+	"hub/media"
+	"hub/models"
+	"hub/search"
+
+	"github.com/dhowden/tag"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -33,56 +46,573 @@ func init() {
 
 // UploadVideo handles video uploads to MongoDB
 // @Summary Upload a video
-// @Description Uploads a video file to MongoDB using GridFS
+// @Description Uploads a video file to MongoDB using GridFS and creates its video record owned by the caller.
+// @Description The file counts against the caller's storage quota. Audio files (MP3, FLAC, M4A, OGG) are
+// @Description accepted too; their title, artist, album, track number and artwork are read from their tags.
 // @Tags Videos
 // @Accept multipart/form-data
-// @Param video formData file true "Video file to upload"
+// @Param video formData file true "Video or audio file to upload"
+// @Param title formData string false "Video title"
+// @Param description formData string false "Video description"
+// @Param visibility formData string false "public (default), unlisted or private"
+// @Param language formData string false "Spoken language used for search stemming, e.g. english or de"
+// @Param tags formData string false "Comma-separated tags"
+// @Param category formData string false "Category ID"
+// @Param channel formData string false "ID of a channel the caller manages"
 // @Produce json
 // @Success 200 {string} string "Video uploaded successfully"
 // @Failure 400 {string} string "Unable to read video file"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 413 {string} string "Video file too large or storage quota exceeded"
 // @Failure 500 {string} string "Unable to upload video"
 // @Router /upload [post]
 func UploadVideo(w http.ResponseWriter, r *http.Request) {
+	authCtx, authCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer authCancel()
+
+	user, ok := requireUser(authCtx, w, r)
+	if !ok {
+		return
+	}
+
+	// Cap the request body; leave headroom for the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxUploadBytes+1<<20)
 	file, header, err := r.FormFile("video")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Video file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Unable to read video file", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	// Open the GridFS bucket for the "mydatabase" database
-	bucket, err := gridfs.NewBucket(client.Database("mydatabase"), options.GridFSBucket().SetName("video"))
+	visibility := r.FormValue("visibility")
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+	if !models.ValidVisibility(visibility) || visibility == models.VisibilityRestricted {
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return
+	}
+	language := strings.ToLower(r.FormValue("language"))
+	if language != "" && !search.SupportedLanguage(language) {
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+	tags, err := models.NormalizeTags(strings.Split(r.FormValue("tags"), ","))
 	if err != nil {
-		http.Error(w, "Unable to create GridFS bucket", http.StatusInternalServerError)
+		http.Error(w, "Invalid tags: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	var categoryID *primitive.ObjectID
+	if id := r.FormValue("category"); id != "" {
+		category, err := findCategory(authCtx, id)
+		if err != nil {
+			http.Error(w, "Category not found", http.StatusBadRequest)
+			return
+		}
+		categoryID = &category.ID
+	}
+	var channelID *primitive.ObjectID
+	if id := r.FormValue("channel"); id != "" {
+		channel, err := findChannelByID(authCtx, id)
+		if err != nil {
+			http.Error(w, "Channel not found", http.StatusBadRequest)
+			return
+		}
+		if !canManageChannel(channel, &user) {
+			http.Error(w, "Not allowed to manage this channel", http.StatusForbidden)
+			return
+		}
+		channelID = &channel.ID
+	}
 
-	// Upload the video file to GridFS
-	uploadStream, err := bucket.OpenUploadStream(header.Filename)
-	if err != nil {
+	if header.Size > config.MaxUploadBytes {
+		http.Error(w, "Video file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Reserve the space up front so concurrent uploads cannot overrun the quota
+	if err := reserveStorage(authCtx, user, header.Size); err != nil {
+		if err == errQuotaExceeded {
+			http.Error(w, "Storage quota exceeded", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Unable to upload video", http.StatusInternalServerError)
 		return
 	}
-	defer uploadStream.Close()
+	reserved := true
+	defer func() {
+		if reserved {
+			releaseStorage(user.ID, header.Size)
+		}
+	}()
 
-	_, err = io.Copy(uploadStream, file)
+	upload, err := readUploadTags(file, header.Filename, header.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "Unable to read video file", http.StatusBadRequest)
+		return
+	}
+	fileID, sum, err := storeUpload(file, header.Filename, upload.ContentType)
+	if err != nil {
+		http.Error(w, "Failed to save video", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Point at an existing copy of the same content instead of keeping a second one
+	fileID, err = dedupeFile(ctx, fileID, sum)
 	if err != nil {
 		http.Error(w, "Failed to save video", http.StatusInternalServerError)
 		return
 	}
 
-	// Respond with the file ID for reference
+	// Create the video record pointing at the stored file
+	video := models.Video{
+		Title:       r.FormValue("title"),
+		Description: r.FormValue("description"),
+		FileName:    header.Filename,
+		FileID:      fileID,
+		Size:        header.Size,
+		OwnerID:     user.ID,
+		Visibility:  visibility,
+		ChannelID:   channelID,
+		Tags:        tags,
+		CategoryID:  categoryID,
+		Language:    language,
+		UploadDate:  time.Now().Format(time.RFC3339),
+	}
+	upload.apply(ctx, &video)
+
+	result, err := config.DB.Collection("videos").InsertOne(ctx, video)
+	if err != nil {
+		releaseFile(ctx, fileID)
+		if video.Audio != nil {
+			deleteImage(ctx, video.Audio.ArtworkFileID)
+		}
+		http.Error(w, "Failed to create video record", http.StatusInternalServerError)
+		return
+	}
+	video.ID = result.InsertedID.(primitive.ObjectID)
+	reserved = false
+	indexVideo(video)
+
+	// Read the duration and any embedded chapters in the background
+	go probeVideo(video)
+	go notifySubscribers(video)
+	go emitEvent(models.EventVideoUploaded, videoEventData(video))
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditVideoUploaded, TargetType: "video", TargetID: video.ID.Hex(), Changes: auditChanges(nil, video)})
+
+	// Respond with the video and file IDs for reference
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Video uploaded successfully. File ID: %v", uploadStream.FileID)
+	fmt.Fprintf(w, "Video uploaded successfully. Video ID: %s, File ID: %s", video.ID.Hex(), fileID.Hex())
+}
+
+// uploadTags is what an uploaded file says about itself
+type uploadTags struct {
+	ContentType string
+	Audio       *models.AudioInfo
+	Title       string
+	Artwork     *media.Artwork
+}
+
+// readUploadTags reads the tags of audio files, which carry their title, artist,
+// album and artwork. Other files keep the given content type. The file is rewound.
+func readUploadTags(file io.ReadSeeker, fileName, contentType string) (uploadTags, error) {
+	upload := uploadTags{ContentType: contentType}
+	audioType, ok := models.AudioContentType(fileName)
+	if !ok {
+		return upload, nil
+	}
+	upload.ContentType = audioType
+	info, title, artwork, err := media.ReadAudioTags(file)
+	if err != nil && err != tag.ErrNoTagsFound {
+		log.Printf("Failed to read tags of %s: %v", fileName, err)
+	}
+	if info.AlbumArtist == "" {
+		info.AlbumArtist = info.Artist
+	}
+	upload.Audio, upload.Title, upload.Artwork = &info, title, artwork
+	_, err = file.Seek(0, io.SeekStart)
+	return upload, err
+}
+
+// apply fills in the media type, tags and artwork of a new video record and
+// defaults its title to the tagged title or the file name
+func (upload uploadTags) apply(ctx context.Context, video *models.Video) {
+	if upload.Audio != nil {
+		video.MediaType = models.MediaAudio
+		video.Audio = upload.Audio
+		if video.Title == "" {
+			video.Title = upload.Title
+		}
+		if upload.Artwork != nil {
+			video.Audio.ArtworkFileID = storeArtwork(ctx, video.FileID, upload.Artwork)
+		}
+	}
+	if video.Title == "" {
+		video.Title = video.FileName
+	}
+	video.Chapters = media.ParseDescriptionChapters(video.Description)
+}
+
+// storeUpload streams a file into the video bucket and returns its ID and SHA-256 hash
+func storeUpload(file io.Reader, fileName, contentType string) (primitive.ObjectID, string, error) {
+	// Open the GridFS bucket for the "mydatabase" database
+	bucket, err := gridfs.NewBucket(client.Database("mydatabase"), options.GridFSBucket().SetName("video"))
+	if err != nil {
+		return primitive.NilObjectID, "", err
+	}
+
+	metadata := bson.M{"contentType": contentType}
+	uploadStream, err := bucket.OpenUploadStream(fileName, options.GridFSUpload().SetMetadata(metadata))
+	if err != nil {
+		return primitive.NilObjectID, "", err
+	}
+	defer uploadStream.Close()
+
+	// Hash the content while it streams into GridFS
+	hash := sha256.New()
+	if _, err := io.Copy(uploadStream, io.TeeReader(file, hash)); err != nil {
+		uploadStream.Abort()
+		return primitive.NilObjectID, "", err
+	}
+
+	// Close the stream so the file document is written before it is referenced
+	if err := uploadStream.Close(); err != nil {
+		return primitive.NilObjectID, "", err
+	}
+	fileID, ok := uploadStream.FileID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, "", fmt.Errorf("invalid file ID %v", uploadStream.FileID)
+	}
+	return fileID, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dedupeFile records the content hash of a freshly uploaded file. When another
+// file with the same hash is still referenced, the upload is discarded and the
+// existing file gains a reference instead. It returns the file the video should use.
+func dedupeFile(ctx context.Context, uploaded primitive.ObjectID, sum string) (primitive.ObjectID, error) {
+	files := config.DB.Collection("video.files")
+
+	for attempt := 0; attempt < 2; attempt++ {
+		// Reuse a live copy; files whose count reached zero are being deleted
+		var existing struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		filter := bson.M{"metadata.sha256": sum, "metadata.refCount": bson.M{"$gt": 0}}
+		err := files.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"metadata.refCount": 1}}).Decode(&existing)
+		if err == nil {
+			deleteFile(ctx, uploaded)
+			return existing.ID, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return primitive.NilObjectID, err
+		}
+
+		// First copy of this content: claim the hash through the unique index
+		update := bson.M{"$set": bson.M{"metadata.sha256": sum, "metadata.refCount": 1}}
+		_, err = files.UpdateOne(ctx, bson.M{"_id": uploaded}, update)
+		if err == nil {
+			return uploaded, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return primitive.NilObjectID, err
+		}
+		// A concurrent upload of the same content won the race; retry to reference it
+	}
+
+	// The competing copy is being deleted; keep this upload without a hash
+	_, err := files.UpdateOne(ctx, bson.M{"_id": uploaded}, bson.M{"$set": bson.M{"metadata.refCount": 1}})
+	return uploaded, err
+}
+
+// releaseFile drops one reference to an uploaded file and deletes it with the last reference.
+// Files uploaded before reference counting have no count and are deleted on first release.
+func releaseFile(ctx context.Context, fileID primitive.ObjectID) error {
+	files := config.DB.Collection("video.files")
+	_, err := files.UpdateOne(ctx, bson.M{"_id": fileID}, bson.M{"$inc": bson.M{"metadata.refCount": -1}})
+	if err != nil {
+		return err
+	}
+
+	// Only delete while the count is still zero so a concurrent dedupe keeps the file
+	result, err := files.DeleteOne(ctx, bson.M{"_id": fileID, "metadata.refCount": bson.M{"$lte": 0}})
+	if err != nil || result.DeletedCount == 0 {
+		return err
+	}
+	_, err = config.DB.Collection("video.chunks").DeleteMany(ctx, bson.M{"files_id": fileID})
+	return err
+}
+
+// deleteFile removes a file and its chunks from the video bucket
+func deleteFile(ctx context.Context, fileID primitive.ObjectID) {
+	bucket, err := gridfs.NewBucket(config.DB, options.GridFSBucket().SetName("video"))
+	if err == nil {
+		err = bucket.DeleteContext(ctx, fileID)
+	}
+	if err != nil {
+		log.Printf("Failed to delete file %s: %v", fileID.Hex(), err)
+	}
+}
+
+// DeleteVideo deletes a video record and releases its file
+// @Summary Delete a video
+// @Description Deletes a video, its captions, comments, reactions, watch history, notifications, renditions and artwork and removes it from playlists. Owners can delete their own videos, admins any video.
+// @Description The stored file is removed once no other video references it.
+// @Tags Videos
+// @Param id path string true "Video ID"
+// @Success 204 {string} string "Video deleted"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Failure 500 {string} string "Failed to delete video"
+// @Router /videos/{id} [delete]
+func DeleteVideo(w http.ResponseWriter, r *http.Request) {
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid video ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// Owners delete their own videos, admins any video
+	filter := bson.M{"_id": objectID}
+	if user.Role != models.RoleAdmin {
+		filter["ownerId"] = user.ID
+	}
+
+	var video models.Video
+	err = config.DB.Collection("videos").FindOneAndDelete(ctx, filter).Decode(&video)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete video", http.StatusInternalServerError)
+		return
+	}
+
+	if err := releaseFile(ctx, video.FileID); err != nil {
+		log.Printf("Failed to release file %s: %v", video.FileID.Hex(), err)
+	}
+	if video.OwnerID != "" {
+		releaseStorage(video.OwnerID, video.Size)
+	}
+	if _, err := config.DB.Collection("captions").DeleteMany(ctx, bson.M{"videoId": video.ID}); err != nil {
+		log.Printf("Failed to delete captions of video %s: %v", video.ID.Hex(), err)
+	}
+	unindexVideo(video.ID)
+	if _, err := config.DB.Collection("comments").DeleteMany(ctx, bson.M{"videoId": video.ID}); err != nil {
+		log.Printf("Failed to delete comments of video %s: %v", video.ID.Hex(), err)
+	}
+	if _, err := config.DB.Collection("reactions").DeleteMany(ctx, bson.M{"videoId": video.ID}); err != nil {
+		log.Printf("Failed to delete reactions of video %s: %v", video.ID.Hex(), err)
+	}
+	if _, err := config.DB.Collection("history").DeleteMany(ctx, bson.M{"videoId": video.ID}); err != nil {
+		log.Printf("Failed to delete watch history of video %s: %v", video.ID.Hex(), err)
+	}
+	if _, err := config.DB.Collection("notifications").DeleteMany(ctx, bson.M{"videoId": video.ID}); err != nil {
+		log.Printf("Failed to delete notifications of video %s: %v", video.ID.Hex(), err)
+	}
+	// Playlists drop the video rather than keep a dangling item
+	_, err = config.DB.Collection("playlists").UpdateMany(ctx, bson.M{"items.videoId": video.ID},
+		bson.M{"$pull": bson.M{"items": bson.M{"videoId": video.ID}}})
+	if err != nil {
+		log.Printf("Failed to remove video %s from playlists: %v", video.ID.Hex(), err)
+	}
+	for _, rendition := range video.Renditions {
+		if err := deleteRenditionFiles(ctx, video.ID, rendition.Name); err != nil {
+			log.Printf("Failed to delete rendition %s of video %s: %v", rendition.Name, video.ID.Hex(), err)
+		}
+	}
+	if video.Audio != nil {
+		deleteImage(ctx, video.Audio.ArtworkFileID)
+	}
+	go emitEvent(models.EventVideoDeleted, videoEventData(video))
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditVideoDeleted, TargetType: "video", TargetID: video.ID.Hex(), Changes: auditChanges(video, nil)})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canViewFile reports whether the user may stream a file addressed by its file ID.
+// Files without video records predate visibility and stay public.
+func canViewFile(ctx context.Context, fileID primitive.ObjectID, user *models.User) bool {
+	cursor, err := config.DB.Collection("videos").Find(ctx, bson.M{"fileId": fileID})
+	if err != nil {
+		return false
+	}
+	defer cursor.Close(ctx)
+
+	referenced := false
+	for cursor.Next(ctx) {
+		var video models.Video
+		if err := cursor.Decode(&video); err != nil {
+			continue
+		}
+		if canView(video, user) {
+			return true
+		}
+		referenced = true
+	}
+	return !referenced
+}
+
+// ListVideos lists the video catalog
+// @Summary List videos
+// @Description Lists the videos the caller may see, newest first. Unlisted videos are only listed for their owner.
+// @Tags Videos
+// @Param owner query string false "Only videos of this user ID"
+// @Param channel query string false "Only videos on this channel ID"
+// @Param tag query string false "Only videos with this tag"
+// @Param type query string false "video or audio"
+// @Param category query string false "Only videos in this category or its subcategories"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of videos to skip"
+// @Produce json
+// @Success 200 {array} models.Video
+// @Router /videos [get]
+func ListVideos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := optionalUser(ctx, r)
+	filter := bson.M{"$and": bson.A{catalogFilter(ctx, r), listableFilter(user)}}
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset)
+
+	cursor, err := config.DB.Collection("videos").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	videos := []models.Video{}
+	if err := cursor.All(ctx, &videos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attachResumePositions(ctx, user, videos)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(videos)
+}
+
+// GetVideoDetails returns the record of a video
+// @Summary Get a video
+// @Description Returns the metadata of a video. Unlisted videos are reachable through this direct link.
+// @Description For signed-in callers resumeAt holds their saved playback position.
+// @Tags Videos
+// @Param id path string true "Video ID"
+// @Produce json
+// @Success 200 {object} models.Video
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id} [get]
+func GetVideoDetails(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findViewableVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	videos := []models.Video{video}
+	attachResumePositions(ctx, user, videos)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(videos[0])
+}
+
+// catalogFilter builds the filter for the catalog query parameters.
+// A category includes the videos of its subcategories.
+func catalogFilter(ctx context.Context, r *http.Request) bson.M {
+	filter := bson.M{}
+	query := r.URL.Query()
+	if owner := query.Get("owner"); owner != "" {
+		filter["ownerId"] = owner
+	}
+	if channel := query.Get("channel"); channel != "" {
+		channelID, _ := primitive.ObjectIDFromHex(channel)
+		filter["channelId"] = channelID
+	}
+	if tag := models.NormalizeTag(query.Get("tag")); tag != "" {
+		filter["tags"] = tag
+	}
+	if mediaType := query.Get("type"); mediaType == models.MediaAudio {
+		filter["mediaType"] = models.MediaAudio
+	} else if mediaType == models.MediaVideo {
+		filter["mediaType"] = bson.M{"$ne": models.MediaAudio}
+	}
+	if category := query.Get("category"); category != "" {
+		scope, err := categoryScope(ctx, category)
+		if err != nil {
+			scope = []primitive.ObjectID{}
+		}
+		filter["categoryId"] = bson.M{"$in": scope}
+	}
+	return filter
+}
+
+// pagination reads the limit and offset query parameters
+func pagination(r *http.Request) (int64, int64) {
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// summarizeVideo returns the short form of a video embedded in other resources
+func summarizeVideo(video models.Video) models.VideoSummary {
+	return models.VideoSummary{
+		ID:         video.ID,
+		Title:      video.Title,
+		OwnerID:    video.OwnerID,
+		Duration:   video.Duration,
+		UploadDate: video.UploadDate,
+	}
+}
+
+// findVideo loads the video record with the given hex ID
+func findVideo(ctx context.Context, id string) (models.Video, error) {
+	var video models.Video
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return video, err
+	}
+	err = config.DB.Collection("videos").FindOne(ctx, bson.M{"_id": objectID}).Decode(&video)
+	return video, err
 }
 
 // GetVideo streams the video by its ID
 // @Summary Stream a video
-// @Description Streams a video file from MongoDB by its ID
+// @Description Streams a video file from MongoDB by its ID. Private and restricted videos require an authorised session or a signed URL.
 // @Tags Videos
-// @Param id path string true "Video ID"
+// @Param id path string true "Video ID or file ID"
+// @Param sig query string false "Signature of a signed playback URL"
 // @Produce video/mp4
 // @Success 200 {file} file "Video streamed successfully"
+// @Success 206 {file} file "Requested byte range"
+// @Failure 403 {string} string "Invalid signed URL"
 // @Failure 404 {string} string "Video not found"
 // @Failure 500 {string} string "Failed to stream video"
 // @Router /video/{id} [get]
@@ -90,41 +620,44 @@ func GetVideo(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	videoID := vars["id"]
 
-	// Convert the ID string to MongoDB ObjectID
-	objectID, err := primitive.ObjectIDFromHex(videoID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid video ID: %v", err), http.StatusBadRequest)
+	// Signed URLs carry their own authorisation
+	if r.URL.Query().Get("sig") != "" {
+		serveSignedVideo(w, r, videoID)
 		return
 	}
 
-	// Open the GridFS bucket for the "mydatabase" database
-	bucket, err := gridfs.NewBucket(client.Database("mydatabase"), options.GridFSBucket().SetName("video"))
+	// Convert the ID string to MongoDB ObjectID
+	objectID, err := primitive.ObjectIDFromHex(videoID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Unable to create GridFS bucket: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Invalid video ID: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Stream the video from GridFS
-	downloadStream, err := bucket.OpenDownloadStream(objectID)
-	if err != nil {
-		// Enhance error logging for clarity
-		http.Error(w, fmt.Sprintf("Video not found or failed to open stream: %v", err), http.StatusNotFound)
+	// Resolve the video record to its file; older uploads are addressed by file ID directly
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	user := optionalUser(ctx, r)
+	if video, err := findVideo(ctx, videoID); err == nil {
+		if !canView(video, user) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		objectID = video.FileID
+		if startsPlayback(r) {
+			recordView(r, video.ID, user)
+		}
+	} else if !canViewFile(ctx, objectID, user) {
+		http.Error(w, "Video not found", http.StatusNotFound)
 		return
 	}
-	defer downloadStream.Close()
 
-	// Stream the video to the client
-	w.Header().Set("Content-Type", "video/mp4")
-	_, err = io.Copy(w, downloadStream)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to stream video: %v", err), http.StatusInternalServerError)
-		return
-	}
+	// Stream the video to the client, honouring range requests
+	streamFile(w, r, objectID)
 }
 
 // GetFirstVideo streams the first video in the MongoDB GridFS bucket
 // @Summary Stream the first video
-// @Description Streams the first video file from MongoDB GridFS
+// @Description Streams the first video file from MongoDB GridFS that the caller may see in listings
 // @Tags Videos
 // @Produce video/mp4
 // @Success 200 {file} file "Video streamed successfully"
@@ -139,24 +672,43 @@ func GetFirstVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query the GridFS metadata collection for the first video
-	filter := bson.M{} // Empty filter to get any document
-	metadataCollection := client.Database("mydatabase").Collection("video.files")
-	var firstFile bson.M
-	err = metadataCollection.FindOne(context.TODO(), filter).Decode(&firstFile)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "No video found", http.StatusNotFound)
-		} else {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Prefer the first video the caller may see in listings
+	var objectID primitive.ObjectID
+	var video models.Video
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}})
+	err = config.DB.Collection("videos").FindOne(ctx, listableFilter(optionalUser(ctx, r)), opts).Decode(&video)
+	switch {
+	case err == nil:
+		objectID = video.FileID
+	case err == mongo.ErrNoDocuments:
+		// Fall back to the first file uploaded before video records existed
+		pipeline := mongo.Pipeline{
+			{{Key: "$lookup", Value: bson.M{"from": "videos", "localField": "_id", "foreignField": "fileId", "as": "refs"}}},
+			{{Key: "$match", Value: bson.M{"refs": bson.M{"$size": 0}}}},
+			{{Key: "$limit", Value: 1}},
+			{{Key: "$project", Value: bson.M{"_id": 1}}},
+		}
+		metadataCollection := client.Database("mydatabase").Collection("video.files")
+		cursor, err := metadataCollection.Aggregate(ctx, pipeline)
+		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to find video: %v", err), http.StatusInternalServerError)
+			return
 		}
-		return
-	}
+		defer cursor.Close(ctx)
 
-	// Extract the ObjectID of the first video
-	objectID, ok := firstFile["_id"].(primitive.ObjectID)
-	if !ok {
-		http.Error(w, "Invalid video ObjectID format", http.StatusInternalServerError)
+		var firstFile struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if !cursor.Next(ctx) || cursor.Decode(&firstFile) != nil {
+			http.Error(w, "No video found", http.StatusNotFound)
+			return
+		}
+		objectID = firstFile.ID
+	default:
+		http.Error(w, fmt.Sprintf("Failed to find video: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// viewCounter buffers de-duplicated views in memory and writes them to the
+// videos collection in batches, so playback never waits on a counter update
+type viewCounter struct {
+	mu       sync.Mutex
+	pending  map[primitive.ObjectID]int64 // Views not yet written per video
+	claiming sync.WaitGroup               // De-duplication checks still running
+}
+
+var views = &viewCounter{
+	pending: map[primitive.ObjectID]int64{},
+}
+
+// StartViewCounter periodically writes buffered view counts to the database
+func StartViewCounter() {
+	go func() {
+		ticker := time.NewTicker(config.ViewFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			views.flush()
+		}
+	}()
+}
+
+// FlushViews waits for views being checked and writes every buffered count.
+// It is called on shutdown so counts since the last flush are not lost.
+func FlushViews() {
+	views.claiming.Wait()
+	views.flush()
+}
+
+// recordView counts a play of the video unless the same viewer was counted
+// within the de-duplication window. The check runs in the background.
+func recordView(r *http.Request, videoID primitive.ObjectID, user *models.User) {
+	key := videoID.Hex() + ":" + viewerKey(r, user)
+	views.claiming.Add(1)
+	go func() {
+		defer views.claiming.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		counted, err := claimView(ctx, key)
+		if err != nil {
+			log.Printf("Failed to de-duplicate view of %s: %v", videoID.Hex(), err)
+			return
+		}
+		if counted {
+			views.mu.Lock()
+			views.pending[videoID]++
+			views.mu.Unlock()
+		}
+	}()
+}
+
+// claimView marks the viewer behind the key as counted for the de-duplication
+// window and reports whether they were not already. Marks are kept in the database
+// so restarts and other instances honour them. MongoDB removes expired marks only
+// about once a minute, so one past its window is taken over in place.
+func claimView(ctx context.Context, key string) (bool, error) {
+	now := time.Now()
+	filter := bson.M{"_id": key, "expiresAt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"expiresAt": now.Add(config.ViewDedupWindow)}}
+	_, err := config.DB.Collection("view_marks").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The mark exists and has not expired
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// flush writes the buffered counts
+func (c *viewCounter) flush() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[primitive.ObjectID]int64{}
+	c.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(pending))
+	for videoID, count := range pending {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": videoID}).
+			SetUpdate(bson.M{"$inc": bson.M{"views": count}}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := config.DB.Collection("videos").BulkWrite(ctx, writes); err != nil {
+		log.Printf("Failed to write view counts: %v", err)
+
+		// Keep the counts for the next flush rather than lose them
+		c.mu.Lock()
+		for videoID, count := range pending {
+			c.pending[videoID] += count
+		}
+		c.mu.Unlock()
+	}
+}
+
+// viewerKey identifies the viewer of a request: the user when signed in,
+// otherwise a hash of the client address and user agent
+func viewerKey(r *http.Request, user *models.User) string {
+	if user != nil {
+		return "user:" + user.ID
+	}
+	sum := sha256.Sum256([]byte(clientIP(r) + "\x00" + r.UserAgent()))
+	return "anon:" + hex.EncodeToString(sum[:16])
+}
+
+// startsPlayback reports whether a streaming request begins a play rather than
+// continuing one. Players fetch later byte ranges while seeking and buffering.
+func startsPlayback(r *http.Request) bool {
+	header := r.Header.Get("Range")
+	return header == "" || strings.HasPrefix(header, "bytes=0-")
+}
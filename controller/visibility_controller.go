@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"hub/config"
+	"hub/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetVideoVisibility changes who can see a video
+// @Summary Set video visibility
+// @Description Sets a video to public, unlisted, private or restricted to named users and groups (owner or admin only)
+// @Tags Videos
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param visibility body models.VisibilityUpdate true "Visibility"
+// @Success 200 {object} models.Video
+// @Failure 400 {string} string "Invalid visibility"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 404 {string} string "Video not found"
+// @Router /videos/{id}/visibility [put]
+func SetVideoVisibility(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	video, user, ok := findOwnedVideo(ctx, w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var body models.VisibilityUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !models.ValidVisibility(body.Visibility) {
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return
+	}
+
+	// Allow lists only mean something for restricted videos
+	if body.Visibility != models.VisibilityRestricted {
+		body.AllowedUsers, body.AllowedGroups = nil, nil
+	}
+
+	update := bson.M{"$set": bson.M{
+		"visibility":    body.Visibility,
+		"allowedUsers":  body.AllowedUsers,
+		"allowedGroups": body.AllowedGroups,
+	}}
+	if _, err := config.DB.Collection("videos").UpdateOne(ctx, bson.M{"_id": video.ID}, update); err != nil {
+		http.Error(w, "Failed to update video", http.StatusInternalServerError)
+		return
+	}
+	before := video
+	video.Visibility, video.AllowedUsers, video.AllowedGroups = body.Visibility, body.AllowedUsers, body.AllowedGroups
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditVideoVisibilityChanged, TargetType: "video", TargetID: video.ID.Hex(), Changes: auditChanges(before, video)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(video)
+}
+
+// canView reports whether the user, nil for anonymous requests, may watch the video
+func canView(video models.Video, user *models.User) bool {
+	switch video.Visibility {
+	case "", models.VisibilityPublic, models.VisibilityUnlisted:
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if user.Role == models.RoleAdmin || (video.OwnerID != "" && video.OwnerID == user.ID) {
+		return true
+	}
+	if video.Visibility != models.VisibilityRestricted {
+		return false
+	}
+	for _, id := range video.AllowedUsers {
+		if id == user.ID {
+			return true
+		}
+	}
+	for _, group := range video.AllowedGroups {
+		for _, member := range user.Groups {
+			if group == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listableFilter returns the filter for videos the user may see in listings and search.
+// Unlisted videos are only listed for their owner.
+func listableFilter(user *models.User) bson.M {
+	public := bson.M{"$or": bson.A{
+		bson.M{"visibility": models.VisibilityPublic},
+		bson.M{"visibility": bson.M{"$exists": false}},
+	}}
+	if user == nil {
+		return public
+	}
+	if user.Role == models.RoleAdmin {
+		return bson.M{}
+	}
+
+	restricted := bson.M{"visibility": models.VisibilityRestricted, "allowedUsers": user.ID}
+	clauses := bson.A{public, bson.M{"ownerId": user.ID}, restricted}
+	if len(user.Groups) > 0 {
+		clauses = append(clauses, bson.M{"visibility": models.VisibilityRestricted, "allowedGroups": bson.M{"$in": user.Groups}})
+	}
+	return bson.M{"$or": clauses}
+}
+
+// viewableFilter returns the filter for videos the user may watch, the query
+// form of canView. Unlike listableFilter it matches unlisted videos.
+func viewableFilter(user *models.User) bson.M {
+	open := bson.M{"$or": bson.A{
+		bson.M{"visibility": bson.M{"$in": bson.A{"", models.VisibilityPublic, models.VisibilityUnlisted}}},
+		bson.M{"visibility": bson.M{"$exists": false}},
+	}}
+	if user == nil {
+		return open
+	}
+	if user.Role == models.RoleAdmin {
+		return bson.M{}
+	}
+
+	restricted := bson.M{"visibility": models.VisibilityRestricted, "allowedUsers": user.ID}
+	clauses := bson.A{open, bson.M{"ownerId": user.ID}, restricted}
+	if len(user.Groups) > 0 {
+		clauses = append(clauses, bson.M{"visibility": models.VisibilityRestricted, "allowedGroups": bson.M{"$in": user.Groups}})
+	}
+	return bson.M{"$or": clauses}
+}
+
+// optionalUser returns the authenticated user or nil for anonymous requests
+func optionalUser(ctx context.Context, r *http.Request) *models.User {
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// findViewableVideo loads a video the requester may watch. Videos they may not
+// see are reported as not found so their existence is not revealed.
+func findViewableVideo(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) (models.Video, *models.User, bool) {
+	user := optionalUser(ctx, r)
+	video, err := findVideo(ctx, id)
+	if err != nil || !canView(video, user) {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return video, user, false
+	}
+	return video, user, true
+}
+
+// findOwnedVideo loads a video the requester owns or administers
+func findOwnedVideo(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) (models.Video, models.User, bool) {
+	user, ok := requireUser(ctx, w, r)
+	if !ok {
+		return models.Video{}, user, false
+	}
+	video, err := findVideo(ctx, id)
+	if err != nil || !canView(video, &user) {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return video, user, false
+	}
+	if user.Role != models.RoleAdmin && video.OwnerID != user.ID {
+		http.Error(w, "Only the owner can change this video", http.StatusForbidden)
+		return video, user, false
+	}
+	return video, user, true
+}
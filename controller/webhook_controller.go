@@ -0,0 +1,549 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"hub/config"
+	"hub/models"
+	"hub/signing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxLoggedResponse caps the part of a receiver's response body kept in the delivery log
+const maxLoggedResponse = 1024
+
+// webhookClient sends deliveries; redirects are not followed so a receiver
+// cannot bounce signed payloads elsewhere
+var webhookClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// webhookWake starts a dispatch round before the next poll
+var webhookWake = make(chan struct{}, 1)
+
+// CreateWebhook registers a webhook
+// @Summary Create a webhook
+// @Description Registers a URL that receives the selected events (admin only). The response carries the signing secret, which is not shown again.
+// @Description Each request has X-Hub-Event, X-Hub-Delivery, X-Hub-Timestamp and X-Hub-Signature-256 headers; the signature is an HMAC-SHA256 of "<timestamp>.<body>".
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookInput true "URL and events"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Router /webhooks [post]
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var input models.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.URL == nil || input.Events == nil {
+		http.Error(w, "URL and events are required", http.StatusBadRequest)
+		return
+	}
+	secret, err := newSecret()
+	if err != nil {
+		http.Error(w, "Failed to create secret", http.StatusInternalServerError)
+		return
+	}
+	webhook := models.Webhook{
+		ID:        primitive.NewObjectID(),
+		Secret:    secret,
+		Active:    true,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := applyWebhookInput(&webhook, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := config.DB.Collection("webhooks").InsertOne(ctx, webhook); err != nil {
+		http.Error(w, "Failed to save webhook", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: user.ID, Action: models.AuditWebhookCreated, TargetType: "webhook", TargetID: webhook.ID.Hex(), Changes: auditChanges(nil, webhook)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// ListWebhooks lists the registered webhooks
+// @Summary List webhooks
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Router /webhooks [get]
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := requireAdmin(ctx, w, r); !ok {
+		return
+	}
+
+	cursor, err := config.DB.Collection("webhooks").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []models.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// UpdateWebhook changes a webhook
+// @Summary Update a webhook
+// @Description Changes the URL, events, description or active state of a webhook (admin only). With rotateSecret a new secret is issued and returned.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param webhook body models.WebhookInput true "Fields to change"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Webhook not found"
+// @Router /webhooks/{id} [put]
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	webhook, admin, ok := findAdminWebhook(ctx, w, r)
+	if !ok {
+		return
+	}
+	before := webhook
+
+	var input models.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := applyWebhookInput(&webhook, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.RotateSecret {
+		secret, err := newSecret()
+		if err != nil {
+			http.Error(w, "Failed to create secret", http.StatusInternalServerError)
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	if _, err := config.DB.Collection("webhooks").ReplaceOne(ctx, bson.M{"_id": webhook.ID}, webhook); err != nil {
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: admin.ID, Action: models.AuditWebhookUpdated, TargetType: "webhook", TargetID: webhook.ID.Hex(), Changes: auditChanges(before, webhook)})
+	if !input.RotateSecret {
+		webhook.Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+// @Summary Delete a webhook
+// @Description Removes a webhook (admin only). Pending deliveries are dropped with its delivery log.
+// @Tags Webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204 {string} string "Webhook deleted"
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Webhook not found"
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	webhook, admin, ok := findAdminWebhook(ctx, w, r)
+	if !ok {
+		return
+	}
+	if _, err := config.DB.Collection("webhooks").DeleteOne(ctx, bson.M{"_id": webhook.ID}); err != nil {
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if _, err := config.DB.Collection("webhook_deliveries").DeleteMany(ctx, bson.M{"webhookId": webhook.ID}); err != nil {
+		log.Printf("Failed to delete deliveries of webhook %s: %v", webhook.ID.Hex(), err)
+	}
+	recordAudit(ctx, r, models.AuditEntry{ActorID: admin.ID, Action: models.AuditWebhookDeleted, TargetType: "webhook", TargetID: webhook.ID.Hex(), Changes: auditChanges(webhook, nil)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description Lists the deliveries of a webhook, newest first, with the outcome of their last attempt (admin only)
+// @Tags Webhooks
+// @Param id path string true "Webhook ID"
+// @Param status query string false "pending, succeeded or failed"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of deliveries to skip"
+// @Produce json
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Webhook not found"
+// @Router /webhooks/{id}/deliveries [get]
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	webhook, _, ok := findAdminWebhook(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"webhookId": webhook.ID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+	limit, offset := pagination(r)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset)
+	cursor, err := config.DB.Collection("webhook_deliveries").Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// TestWebhook sends a ping event to a webhook right away
+// @Summary Send a test event
+// @Description Posts a signed ping event to the webhook and returns the logged delivery with the receiver's response (admin only).
+// @Description The test is attempted once and not retried; it is sent even to inactive webhooks.
+// @Tags Webhooks
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {string} string "Authentication required"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Webhook not found"
+// @Router /webhooks/{id}/test [post]
+func TestWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second+config.WebhookTimeout)
+	defer cancel()
+
+	webhook, _, ok := findAdminWebhook(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := newDelivery(webhook.ID, models.EventPing, map[string]string{"webhookId": webhook.ID.Hex()})
+	if err != nil {
+		http.Error(w, "Failed to build test event", http.StatusInternalServerError)
+		return
+	}
+	attemptDelivery(ctx, webhook, &delivery)
+	if delivery.Status != models.DeliverySucceeded {
+		delivery.Status = models.DeliveryFailed
+	}
+	if _, err := config.DB.Collection("webhook_deliveries").InsertOne(ctx, delivery); err != nil {
+		log.Printf("Failed to log test delivery of webhook %s: %v", webhook.ID.Hex(), err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// findAdminWebhook loads the webhook named in the path for an admin
+func findAdminWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.Webhook, models.User, bool) {
+	var webhook models.Webhook
+	admin, ok := requireAdmin(ctx, w, r)
+	if !ok {
+		return webhook, admin, false
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err == nil {
+		err = config.DB.Collection("webhooks").FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	}
+	if err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return webhook, admin, false
+	}
+	return webhook, admin, true
+}
+
+// applyWebhookInput validates and copies the given fields onto a webhook
+func applyWebhookInput(webhook *models.Webhook, input models.WebhookInput) error {
+	if input.URL != nil {
+		target, err := url.Parse(*input.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("URL must be an absolute http or https URL")
+		}
+		webhook.URL = target.String()
+	}
+	if input.Events != nil {
+		if len(*input.Events) == 0 {
+			return fmt.Errorf("at least one event is required")
+		}
+		for _, event := range *input.Events {
+			if !models.ValidWebhookEvent(event) {
+				return fmt.Errorf("unknown event %q", event)
+			}
+		}
+		webhook.Events = *input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	return nil
+}
+
+// newSecret returns a random hex-encoded secret
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// newDelivery builds a pending delivery of an event
+func newDelivery(webhookID primitive.ObjectID, event string, data interface{}) (models.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookID:     webhookID,
+		Event:         event,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(config.WebhookLogRetention),
+	}
+	payload, err := json.Marshal(models.WebhookPayload{ID: delivery.ID.Hex(), Event: event, CreatedAt: now, Data: data})
+	delivery.Payload = string(payload)
+	return delivery, err
+}
+
+// emitEvent queues an event for every active webhook subscribed to it
+func emitEvent(event string, data interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.DB.Collection("webhooks").Find(ctx, bson.M{"active": true, "events": bson.M{"$in": bson.A{event, models.EventAll}}})
+	if err != nil {
+		log.Printf("Failed to load webhooks for %s: %v", event, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []interface{}
+	for cursor.Next(ctx) {
+		var webhook models.Webhook
+		if err := cursor.Decode(&webhook); err != nil || !webhook.Wants(event) {
+			continue
+		}
+		delivery, err := newDelivery(webhook.ID, event, data)
+		if err != nil {
+			log.Printf("Failed to encode %s event: %v", event, err)
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if _, err := config.DB.Collection("webhook_deliveries").InsertMany(ctx, deliveries); err != nil {
+		log.Printf("Failed to queue %s deliveries: %v", event, err)
+		return
+	}
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// videoEventData describes a video for webhook payloads
+func videoEventData(video models.Video) models.VideoEventData {
+	return models.VideoEventData{
+		ID:         video.ID,
+		Title:      video.Title,
+		OwnerID:    video.OwnerID,
+		ChannelID:  video.ChannelID,
+		Visibility: video.Visibility,
+		Size:       video.Size,
+		UploadDate: video.UploadDate,
+	}
+}
+
+// StartWebhookDispatcher sends due deliveries in the background. Deliveries
+// live in the database, so retries survive restarts and several instances can
+// share the work.
+func StartWebhookDispatcher() {
+	go func() {
+		ticker := time.NewTicker(config.WebhookPollInterval)
+		defer ticker.Stop()
+		for {
+			dispatchDeliveries()
+			select {
+			case <-ticker.C:
+			case <-webhookWake:
+			}
+		}
+	}()
+}
+
+// dispatchDeliveries sends every delivery that is due
+func dispatchDeliveries() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second+config.WebhookTimeout)
+		delivery, ok := claimDelivery(ctx)
+		if !ok {
+			cancel()
+			return
+		}
+
+		var webhook models.Webhook
+		err := config.DB.Collection("webhooks").FindOne(ctx, bson.M{"_id": delivery.WebhookID}).Decode(&webhook)
+		switch {
+		case err != nil:
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = "webhook was deleted"
+		case !webhook.Active:
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = "webhook is inactive"
+		default:
+			attemptDelivery(ctx, webhook, &delivery)
+		}
+		saveDelivery(ctx, delivery)
+		cancel()
+	}
+}
+
+// claimDelivery leases the oldest due delivery so no other dispatcher sends it meanwhile
+func claimDelivery(ctx context.Context) (models.WebhookDelivery, bool) {
+	var delivery models.WebhookDelivery
+	now := time.Now()
+	lease := now.Add(2 * config.WebhookTimeout)
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := config.DB.Collection("webhook_deliveries").FindOneAndUpdate(ctx,
+		bson.M{"status": models.DeliveryPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAttemptAt": lease}}, opts).Decode(&delivery)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to claim webhook delivery: %v", err)
+		}
+		return delivery, false
+	}
+	return delivery, true
+}
+
+// attemptDelivery posts a delivery once and records the outcome on it. Failed
+// attempts are scheduled again with exponential backoff until the attempts run out.
+func attemptDelivery(ctx context.Context, webhook models.Webhook, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	err := postDelivery(ctx, webhook, delivery)
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		return
+	}
+	delivery.Error = err.Error()
+	if delivery.Attempts >= config.WebhookMaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		return
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = now.Add(config.WebhookBackoff << (delivery.Attempts - 1))
+}
+
+// postDelivery sends the signed payload and fails unless the receiver answers with 2xx
+func postDelivery(ctx context.Context, webhook models.Webhook, delivery *models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, config.WebhookTimeout)
+	defer cancel()
+
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Hub-Webhooks/1.0")
+	req.Header.Set("X-Hub-Event", delivery.Event)
+	req.Header.Set("X-Hub-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-Hub-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Hub-Signature-256", signing.SignPayload([]byte(webhook.Secret), timestamp, payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+// saveDelivery stores the outcome of an attempt
+func saveDelivery(ctx context.Context, delivery models.WebhookDelivery) {
+	if _, err := config.DB.Collection("webhook_deliveries").ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery); err != nil {
+		log.Printf("Failed to save webhook delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
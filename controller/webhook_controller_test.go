@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hub/config"
+	"hub/models"
+	"hub/signing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// signedReceiver answers every request with status after checking its signature
+// against secret; the number of correctly signed requests is counted in verified
+func signedReceiver(t *testing.T, secret string, status int, verified *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Hub-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("X-Hub-Timestamp: %v", err)
+		}
+		if signing.VerifyPayload([]byte(secret), timestamp, body, r.Header.Get("X-Hub-Signature-256")) {
+			atomic.AddInt32(verified, 1)
+		} else {
+			t.Errorf("X-Hub-Signature-256 %q does not match the body", r.Header.Get("X-Hub-Signature-256"))
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testDelivery(t *testing.T, webhook models.Webhook) models.WebhookDelivery {
+	t.Helper()
+	delivery, err := newDelivery(webhook.ID, models.EventPing, map[string]string{"webhookId": webhook.ID.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestAttemptDeliverySigned(t *testing.T) {
+	var verified int32
+	server := signedReceiver(t, "secret", http.StatusNoContent, &verified)
+	webhook := models.Webhook{ID: primitive.NewObjectID(), URL: server.URL, Secret: "secret"}
+	delivery := testDelivery(t, webhook)
+
+	attemptDelivery(context.Background(), webhook, &delivery)
+	if delivery.Status != models.DeliverySucceeded {
+		t.Fatalf("status = %q (%s), want %q", delivery.Status, delivery.Error, models.DeliverySucceeded)
+	}
+	if n := atomic.LoadInt32(&verified); n != 1 {
+		t.Fatalf("receiver verified %d signed requests, want 1", n)
+	}
+	if delivery.ResponseStatus != http.StatusNoContent {
+		t.Fatalf("response status = %d, want %d", delivery.ResponseStatus, http.StatusNoContent)
+	}
+}
+
+func TestAttemptDeliveryRetriesWithBackoff(t *testing.T) {
+	var verified int32
+	server := signedReceiver(t, "secret", http.StatusServiceUnavailable, &verified)
+	webhook := models.Webhook{ID: primitive.NewObjectID(), URL: server.URL, Secret: "secret"}
+	delivery := testDelivery(t, webhook)
+
+	for attempt := 1; attempt < config.WebhookMaxAttempts; attempt++ {
+		attemptDelivery(context.Background(), webhook, &delivery)
+		if delivery.Status != models.DeliveryPending {
+			t.Fatalf("attempt %d: status = %q, want %q", attempt, delivery.Status, models.DeliveryPending)
+		}
+		if delivery.ResponseStatus != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d: response status = %d, want %d", attempt, delivery.ResponseStatus, http.StatusServiceUnavailable)
+		}
+		want := config.WebhookBackoff << (attempt - 1)
+		if delay := delivery.NextAttemptAt.Sub(*delivery.LastAttemptAt); delay != want {
+			t.Fatalf("attempt %d: retried after %s, want %s", attempt, delay, want)
+		}
+	}
+	attemptDelivery(context.Background(), webhook, &delivery)
+	if delivery.Status != models.DeliveryFailed {
+		t.Fatalf("last attempt: status = %q, want %q", delivery.Status, models.DeliveryFailed)
+	}
+	if n := atomic.LoadInt32(&verified); int(n) != config.WebhookMaxAttempts {
+		t.Fatalf("receiver verified %d signed requests, want %d", n, config.WebhookMaxAttempts)
+	}
+}
+
+func TestAttemptDeliveryIgnoresRedirects(t *testing.T) {
+	var verified int32
+	target := signedReceiver(t, "secret", http.StatusOK, &verified)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	webhook := models.Webhook{ID: primitive.NewObjectID(), URL: redirect.URL, Secret: "secret"}
+	delivery := testDelivery(t, webhook)
+
+	attemptDelivery(context.Background(), webhook, &delivery)
+	if delivery.Status != models.DeliveryPending {
+		t.Fatalf("status = %q, want %q", delivery.Status, models.DeliveryPending)
+	}
+	if delivery.ResponseStatus != http.StatusTemporaryRedirect {
+		t.Fatalf("response status = %d, want %d", delivery.ResponseStatus, http.StatusTemporaryRedirect)
+	}
+	if n := atomic.LoadInt32(&verified); n != 0 {
+		t.Fatalf("redirect was followed to the target %d times", n)
+	}
+}
+
+func TestTestWebhook(t *testing.T) {
+	useTestDB(t)
+	admin, token := testUser(t, models.RoleAdmin)
+	var verified int32
+	server := signedReceiver(t, "secret", http.StatusOK, &verified)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	webhook := models.Webhook{ID: primitive.NewObjectID(), URL: server.URL, Events: []string{models.EventAll}, Secret: "secret", Active: true, CreatedBy: admin.ID, CreatedAt: time.Now()}
+	if _, err := config.DB.Collection("webhooks").InsertOne(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/"+webhook.ID.Hex()+"/test", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = mux.SetURLVars(r, map[string]string{"id": webhook.ID.Hex()})
+	w := httptest.NewRecorder()
+	TestWebhook(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var delivery models.WebhookDelivery
+	if err := json.NewDecoder(w.Body).Decode(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != models.DeliverySucceeded || delivery.Event != models.EventPing {
+		t.Fatalf("delivery = %s %s, want a succeeded %s", delivery.Status, delivery.Event, models.EventPing)
+	}
+	if n := atomic.LoadInt32(&verified); n != 1 {
+		t.Fatalf("receiver verified %d signed requests, want 1", n)
+	}
+	logged, err := config.DB.Collection("webhook_deliveries").CountDocuments(ctx, bson.M{"_id": delivery.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged != 1 {
+		t.Fatalf("test delivery logged %d times, want 1", logged)
+	}
+}
@@ -0,0 +1,224 @@
+package live
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AMF0 type markers
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfDate        = 0x0b
+	amfLongString  = 0x0c
+)
+
+// maxAMFDepth bounds how deeply objects and arrays may nest
+const maxAMFDepth = 32
+
+var errShortAMF = errors.New("amf: truncated value")
+
+// amfReader decodes AMF0 values from a message payload
+type amfReader struct {
+	data  []byte
+	pos   int
+	depth int // Values being decoded, including the current one
+}
+
+// next returns the following n bytes
+func (r *amfReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errShortAMF
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// more reports whether values are left
+func (r *amfReader) more() bool {
+	return r.pos < len(r.data)
+}
+
+// value decodes one value. Objects and ECMA arrays become maps, strict arrays
+// slices, numbers and dates float64 and null or undefined nil.
+func (r *amfReader) value() (interface{}, error) {
+	// Payloads come from unauthenticated clients, so nesting must not exhaust the stack
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxAMFDepth {
+		return nil, errors.New("amf: values nested too deeply")
+	}
+
+	marker, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	switch marker[0] {
+	case amfNumber:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case amfBoolean:
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case amfString:
+		return r.shortString()
+	case amfLongString:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := r.next(int(binary.BigEndian.Uint32(b)))
+		return string(s), err
+	case amfObject:
+		return r.properties()
+	case amfECMAArray:
+		// The count is only a hint; the properties end with an end marker like objects
+		if _, err := r.next(4); err != nil {
+			return nil, err
+		}
+		return r.properties()
+	case amfStrictArray:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		count := int(binary.BigEndian.Uint32(b))
+		values := make([]interface{}, 0)
+		for i := 0; i < count; i++ {
+			v, err := r.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case amfDate:
+		b, err := r.next(10)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[:8])), nil
+	case amfNull, amfUndefined:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("amf: unsupported type 0x%02x", marker[0])
+	}
+}
+
+// shortString reads a string with a 16-bit length
+func (r *amfReader) shortString() (string, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return "", err
+	}
+	s, err := r.next(int(binary.BigEndian.Uint16(b)))
+	return string(s), err
+}
+
+// properties reads object properties up to the end marker
+func (r *amfReader) properties() (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+	for {
+		key, err := r.shortString()
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := r.next(1)
+			if err != nil {
+				return nil, err
+			}
+			if end[0] == amfObjectEnd {
+				return object, nil
+			}
+			r.pos--
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = v
+	}
+}
+
+// decodeAMF decodes every value of a payload
+func decodeAMF(data []byte) ([]interface{}, error) {
+	r := &amfReader{data: data}
+	var values []interface{}
+	for r.more() {
+		v, err := r.value()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// encodeAMF encodes values as AMF0. It supports the types used in replies:
+// numbers, booleans, strings, nil and string-keyed maps.
+func encodeAMF(values ...interface{}) []byte {
+	var b []byte
+	for _, v := range values {
+		b = appendAMF(b, v)
+	}
+	return b
+}
+
+func appendAMF(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case float64:
+		b = append(b, amfNumber)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case int:
+		return appendAMF(b, float64(v))
+	case bool:
+		if v {
+			return append(b, amfBoolean, 1)
+		}
+		return append(b, amfBoolean, 0)
+	case string:
+		b = append(b, amfString)
+		return appendShortString(b, v)
+	case map[string]interface{}:
+		b = append(b, amfObject)
+		// Sorted keys keep the encoding stable
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b = appendShortString(b, key)
+			b = appendAMF(b, v[key])
+		}
+		return append(b, 0, 0, amfObjectEnd)
+	default:
+		return append(b, amfNull)
+	}
+}
+
+func appendShortString(b []byte, s string) []byte {
+	if len(s) > math.MaxUint16 {
+		s = s[:math.MaxUint16]
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
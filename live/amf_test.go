@@ -0,0 +1,73 @@
+package live
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAMFRoundTrip(t *testing.T) {
+	payload := encodeAMF("connect", 1.0, map[string]interface{}{"app": "live", "tcUrl": "rtmp://localhost/live"}, nil, true)
+	values, err := decodeAMF(payload)
+	if err != nil {
+		t.Fatalf("decodeAMF: %v", err)
+	}
+	want := []interface{}{"connect", 1.0, map[string]interface{}{"app": "live", "tcUrl": "rtmp://localhost/live"}, nil, true}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("decodeAMF = %#v, want %#v", values, want)
+	}
+}
+
+func TestAMFStrictArray(t *testing.T) {
+	payload := []byte{amfStrictArray, 0, 0, 0, 2, amfBoolean, 1, amfNull}
+	values, err := decodeAMF(payload)
+	if err != nil {
+		t.Fatalf("decodeAMF: %v", err)
+	}
+	want := []interface{}{[]interface{}{true, nil}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("decodeAMF = %#v, want %#v", values, want)
+	}
+}
+
+func TestAMFMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"truncated number", []byte{amfNumber, 0, 0}},
+		{"truncated string", []byte{amfString, 0, 5, 'a'}},
+		{"unterminated object", []byte{amfObject, 0, 1, 'a', amfNull}},
+		{"strict array longer than payload", []byte{amfStrictArray, 0xff, 0xff, 0xff, 0xff, amfNull}},
+		{"unsupported type", []byte{0x11}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeAMF(tt.payload); err == nil {
+				t.Error("decodeAMF succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestAMFNestingLimit(t *testing.T) {
+	nested := func(depth int) []byte {
+		var b bytes.Buffer
+		for i := 0; i < depth; i++ {
+			b.Write([]byte{amfStrictArray, 0, 0, 0, 1})
+		}
+		b.WriteByte(amfNull)
+		return b.Bytes()
+	}
+
+	if _, err := decodeAMF(nested(maxAMFDepth - 1)); err != nil {
+		t.Errorf("decodeAMF of %d levels: %v", maxAMFDepth, err)
+	}
+	if _, err := decodeAMF(nested(maxAMFDepth)); err == nil {
+		t.Errorf("decodeAMF of %d levels succeeded, want an error", maxAMFDepth+1)
+	}
+	// Far deeper than any stack would survive without the limit
+	if _, err := decodeAMF(nested(1 << 20)); err == nil {
+		t.Error("decodeAMF of deeply nested arrays succeeded, want an error")
+	}
+}
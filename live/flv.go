@@ -0,0 +1,39 @@
+package live
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// FLV tag types, equal to the RTMP message types they carry
+const (
+	tagAudio  = 8
+	tagVideo  = 9
+	tagScript = 18
+)
+
+// flvHeader starts an FLV file with audio and video
+var flvHeader = []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+
+// flvWriter remuxes RTMP media messages into an FLV stream
+type flvWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// writeTag writes one tag, preceded by the file header on the first call
+func (f *flvWriter) writeTag(tagType byte, timestamp uint32, data []byte) error {
+	buf := make([]byte, 0, len(flvHeader)+11+len(data)+4)
+	if !f.started {
+		buf = append(buf, flvHeader...)
+		f.started = true
+	}
+	size := uint32(len(data))
+	buf = append(buf, tagType, byte(size>>16), byte(size>>8), byte(size))
+	buf = append(buf, byte(timestamp>>16), byte(timestamp>>8), byte(timestamp), byte(timestamp>>24))
+	buf = append(buf, 0, 0, 0)
+	buf = append(buf, data...)
+	buf = binary.BigEndian.AppendUint32(buf, 11+size)
+	_, err := f.w.Write(buf)
+	return err
+}
@@ -0,0 +1,470 @@
+// Package live accepts live streams from encoders such as OBS or ffmpeg over
+// RTMP and hands them on as FLV.
+package live
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// PublishFunc is called when a client starts publishing a stream key on an
+// application. It returns the writer receiving the stream as FLV, or an error
+// to refuse the stream. The writer is closed when the client stops.
+type PublishFunc func(app, key string) (io.WriteCloser, error)
+
+// RTMP message types
+const (
+	msgSetChunkSize     = 1
+	msgAbort            = 2
+	msgAcknowledgement  = 3
+	msgUserControl      = 4
+	msgWindowAckSize    = 5
+	msgSetPeerBandwidth = 6
+	msgAudio            = 8
+	msgVideo            = 9
+	msgDataAMF3         = 15
+	msgCommandAMF3      = 17
+	msgDataAMF0         = 18
+	msgCommandAMF0      = 20
+)
+
+const (
+	handshakeSize   = 1536
+	defaultChunk    = 128
+	outChunkSize    = 4096
+	windowAckSize   = 2500000
+	maxMessageSize  = 16 << 20
+	maxBuffered     = 32 << 20 // Bytes of partly assembled messages held per connection
+	maxChunkSize    = 65536    // Largest chunk size a client may announce
+	maxChunkStreams = 16       // Chunk streams one connection may open
+	idleTimeout     = 30 * time.Second
+	controlStream   = 2 // Chunk stream of protocol control messages
+	commandStream   = 3 // Chunk stream of command replies
+	publishStreamID = 1 // Message stream handed out by createStream
+)
+
+// errStopped ends a connection whose client stopped publishing
+var errStopped = errors.New("rtmp: publishing stopped")
+
+// ListenAndServe accepts RTMP connections on addr until the listener fails
+func ListenAndServe(addr string, publish PublishFunc) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go func() {
+			c := &rtmpConn{
+				conn:        conn,
+				r:           bufio.NewReader(conn),
+				w:           bufio.NewWriter(conn),
+				inChunkSize: defaultChunk,
+				outChunk:    defaultChunk,
+				streams:     map[uint32]*chunkStream{},
+				publish:     publish,
+			}
+			if err := c.serve(); err != nil && err != errStopped && !errors.Is(err, io.EOF) {
+				log.Printf("RTMP connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// chunkStream is the state of one RTMP chunk stream
+type chunkStream struct {
+	timestamp uint32 // Absolute timestamp of the current message
+	delta     uint32 // Timestamp delta of the last header
+	length    uint32
+	typeID    byte
+	streamID  uint32
+	extended  bool // The last header carried an extended timestamp
+	payload   []byte
+}
+
+// message is a reassembled RTMP message
+type message struct {
+	typeID    byte
+	streamID  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+// rtmpConn serves one encoder connection
+type rtmpConn struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	w           *bufio.Writer
+	inChunkSize uint32
+	outChunk    uint32
+	streams     map[uint32]*chunkStream
+	buffered    int    // Bytes of partly assembled messages across the chunk streams
+	received    uint32 // Bytes read, for acknowledgements
+	ackWindow   uint32
+	lastAck     uint32
+	app         string
+	publish     PublishFunc
+	output      io.WriteCloser
+	flv         *flvWriter
+}
+
+// serve runs the handshake and handles messages until the client leaves
+func (c *rtmpConn) serve() error {
+	defer c.conn.Close()
+	defer func() {
+		if c.output != nil {
+			c.output.Close()
+		}
+	}()
+
+	if err := c.handshake(); err != nil {
+		return fmt.Errorf("handshake: %v", err)
+	}
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if err := c.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handshake performs the plain RTMP handshake
+func (c *rtmpConn) handshake() error {
+	c.conn.SetDeadline(time.Now().Add(idleTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(c.r, c0c1); err != nil {
+		return err
+	}
+	if c0c1[0] != 3 {
+		return fmt.Errorf("unsupported version %d", c0c1[0])
+	}
+
+	s1 := make([]byte, handshakeSize)
+	binary.BigEndian.PutUint32(s1, uint32(time.Now().Unix()))
+	rand.Read(s1[8:])
+	c.w.WriteByte(3)
+	c.w.Write(s1)
+	c.w.Write(c0c1[1:]) // S2 echoes C1
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	c2 := make([]byte, handshakeSize)
+	_, err := io.ReadFull(c.r, c2)
+	return err
+}
+
+// read fills b and counts the bytes for acknowledgements
+func (c *rtmpConn) read(b []byte) error {
+	n, err := io.ReadFull(c.r, b)
+	c.received += uint32(n)
+	return err
+}
+
+// readUint24 reads a 24-bit big-endian integer
+func (c *rtmpConn) readUint24() (uint32, error) {
+	var b [3]byte
+	if err := c.read(b[:]); err != nil {
+		return 0, err
+	}
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+}
+
+// readMessage reads chunks until a message is complete
+func (c *rtmpConn) readMessage() (message, error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+
+		var b [4]byte
+		if err := c.read(b[:1]); err != nil {
+			return message{}, err
+		}
+		format := b[0] >> 6
+		csid := uint32(b[0] & 0x3f)
+		switch csid {
+		case 0:
+			if err := c.read(b[:1]); err != nil {
+				return message{}, err
+			}
+			csid = 64 + uint32(b[0])
+		case 1:
+			if err := c.read(b[:2]); err != nil {
+				return message{}, err
+			}
+			csid = 64 + uint32(b[0]) + uint32(b[1])*256
+		}
+
+		cs := c.streams[csid]
+		if cs == nil {
+			if format != 0 {
+				return message{}, fmt.Errorf("chunk stream %d starts without a full header", csid)
+			}
+			if len(c.streams) >= maxChunkStreams {
+				return message{}, fmt.Errorf("more than %d chunk streams", maxChunkStreams)
+			}
+			cs = &chunkStream{}
+			c.streams[csid] = cs
+		}
+		starting := len(cs.payload) == 0
+
+		var stamp uint32
+		var err error
+		if format <= 2 {
+			if stamp, err = c.readUint24(); err != nil {
+				return message{}, err
+			}
+		}
+		if format <= 1 {
+			length, err := c.readUint24()
+			if err != nil {
+				return message{}, err
+			}
+			if err := c.read(b[:1]); err != nil {
+				return message{}, err
+			}
+			// A message being assembled keeps its length and type until it is complete
+			if !starting && (length != cs.length || b[0] != cs.typeID) {
+				return message{}, fmt.Errorf("chunk stream %d changes its message header mid-message", csid)
+			}
+			if length > maxMessageSize {
+				return message{}, fmt.Errorf("message of %d bytes is too large", length)
+			}
+			cs.length, cs.typeID = length, b[0]
+		}
+		if format == 0 {
+			if err := c.read(b[:4]); err != nil {
+				return message{}, err
+			}
+			cs.streamID = binary.LittleEndian.Uint32(b[:4])
+		}
+		if format <= 2 {
+			cs.extended = stamp == 0xffffff
+		}
+		if cs.extended {
+			if err := c.read(b[:4]); err != nil {
+				return message{}, err
+			}
+			if format <= 2 {
+				stamp = binary.BigEndian.Uint32(b[:4])
+			}
+		}
+
+		// Timestamps advance once per message, not per chunk
+		switch {
+		case format == 0:
+			cs.timestamp, cs.delta = stamp, 0
+		case format <= 2:
+			cs.delta = stamp
+			cs.timestamp += stamp
+		case starting:
+			cs.timestamp += cs.delta
+		}
+
+		size := cs.length - uint32(len(cs.payload))
+		if size > c.inChunkSize {
+			size = c.inChunkSize
+		}
+		if uint32(len(cs.payload))+size > cs.length || c.buffered+int(size) > maxBuffered {
+			return message{}, errors.New("too much message data buffered")
+		}
+		chunk := make([]byte, size)
+		if err := c.read(chunk); err != nil {
+			return message{}, err
+		}
+		cs.payload = append(cs.payload, chunk...)
+		c.buffered += int(size)
+
+		if err := c.acknowledge(); err != nil {
+			return message{}, err
+		}
+		if uint32(len(cs.payload)) == cs.length {
+			msg := message{typeID: cs.typeID, streamID: cs.streamID, timestamp: cs.timestamp, payload: cs.payload}
+			c.buffered -= len(cs.payload)
+			cs.payload = nil
+			return msg, nil
+		}
+	}
+}
+
+// acknowledge reports the bytes read once the peer's window is reached
+func (c *rtmpConn) acknowledge() error {
+	if c.ackWindow == 0 || c.received-c.lastAck < c.ackWindow {
+		return nil
+	}
+	c.lastAck = c.received
+	return c.writeMessage(controlStream, msgAcknowledgement, 0, binary.BigEndian.AppendUint32(nil, c.received))
+}
+
+// handle acts on one message
+func (c *rtmpConn) handle(msg message) error {
+	switch msg.typeID {
+	case msgSetChunkSize:
+		if len(msg.payload) < 4 {
+			return errors.New("short set chunk size")
+		}
+		size := binary.BigEndian.Uint32(msg.payload) & 0x7fffffff
+		if size == 0 || size > maxChunkSize {
+			return fmt.Errorf("unsupported chunk size %d", size)
+		}
+		c.inChunkSize = size
+	case msgAbort:
+		if len(msg.payload) >= 4 {
+			if cs := c.streams[binary.BigEndian.Uint32(msg.payload)]; cs != nil {
+				c.buffered -= len(cs.payload)
+				cs.payload = nil
+			}
+		}
+	case msgWindowAckSize:
+		if len(msg.payload) >= 4 {
+			c.ackWindow = binary.BigEndian.Uint32(msg.payload)
+		}
+	case msgUserControl:
+		// Answer ping requests so the client keeps the connection
+		if len(msg.payload) >= 6 && binary.BigEndian.Uint16(msg.payload) == 6 {
+			pong := append([]byte{0, 7}, msg.payload[2:6]...)
+			return c.writeMessage(controlStream, msgUserControl, 0, pong)
+		}
+	case msgCommandAMF3:
+		if len(msg.payload) > 0 {
+			return c.command(msg.streamID, msg.payload[1:])
+		}
+	case msgCommandAMF0:
+		return c.command(msg.streamID, msg.payload)
+	case msgDataAMF3:
+		if len(msg.payload) > 0 {
+			return c.metadata(msg.timestamp, msg.payload[1:])
+		}
+	case msgDataAMF0:
+		return c.metadata(msg.timestamp, msg.payload)
+	case msgAudio, msgVideo:
+		if c.flv != nil {
+			return c.flv.writeTag(msg.typeID, msg.timestamp, msg.payload)
+		}
+	}
+	return nil
+}
+
+// command answers an AMF0 command
+func (c *rtmpConn) command(streamID uint32, payload []byte) error {
+	values, err := decodeAMF(payload)
+	if err != nil && len(values) < 2 {
+		return fmt.Errorf("malformed command: %v", err)
+	}
+	if len(values) < 2 {
+		return nil
+	}
+	name, _ := values[0].(string)
+	txn, _ := values[1].(float64)
+
+	switch name {
+	case "connect":
+		if len(values) > 2 {
+			if object, ok := values[2].(map[string]interface{}); ok {
+				app, _ := object["app"].(string)
+				c.app = strings.Trim(app, "/")
+			}
+		}
+		c.writeMessage(controlStream, msgWindowAckSize, 0, binary.BigEndian.AppendUint32(nil, windowAckSize))
+		c.writeMessage(controlStream, msgSetPeerBandwidth, 0, append(binary.BigEndian.AppendUint32(nil, windowAckSize), 2))
+		c.writeMessage(controlStream, msgSetChunkSize, 0, binary.BigEndian.AppendUint32(nil, outChunkSize))
+		c.outChunk = outChunkSize
+		return c.writeMessage(commandStream, msgCommandAMF0, 0, encodeAMF("_result", txn,
+			map[string]interface{}{"fmsVer": "FMS/3,0,1,123", "capabilities": 31},
+			map[string]interface{}{"level": "status", "code": "NetConnection.Connect.Success", "description": "Connection succeeded.", "objectEncoding": 0},
+		))
+	case "createStream":
+		return c.writeMessage(commandStream, msgCommandAMF0, 0, encodeAMF("_result", txn, nil, publishStreamID))
+	case "releaseStream", "FCPublish":
+		return c.writeMessage(commandStream, msgCommandAMF0, 0, encodeAMF("_result", txn, nil))
+	case "publish":
+		if c.output != nil {
+			return c.status(streamID, "error", "NetStream.Publish.BadName", "Already publishing")
+		}
+		key := ""
+		if len(values) > 3 {
+			key, _ = values[3].(string)
+		}
+		// Encoders may append query parameters to the stream key
+		key, _, _ = strings.Cut(key, "?")
+		output, err := c.publish(c.app, key)
+		if err != nil {
+			c.status(streamID, "error", "NetStream.Publish.BadName", err.Error())
+			return fmt.Errorf("publish refused: %v", err)
+		}
+		c.output = output
+		c.flv = &flvWriter{w: output}
+		return c.status(streamID, "status", "NetStream.Publish.Start", "Publishing started")
+	case "FCUnpublish", "deleteStream", "closeStream":
+		if c.output != nil {
+			return errStopped
+		}
+	}
+	return nil
+}
+
+// metadata passes stream metadata on as an FLV script tag
+func (c *rtmpConn) metadata(timestamp uint32, payload []byte) error {
+	if c.flv == nil {
+		return nil
+	}
+	r := &amfReader{data: payload}
+	first, err := r.value()
+	if err != nil {
+		return nil
+	}
+	// Encoders wrap onMetaData in @setDataFrame, which is not part of the FLV tag
+	if first == "@setDataFrame" {
+		payload = payload[r.pos:]
+	}
+	return c.flv.writeTag(tagScript, timestamp, payload)
+}
+
+// status sends an onStatus event on a message stream
+func (c *rtmpConn) status(streamID uint32, level, code, description string) error {
+	info := map[string]interface{}{"level": level, "code": code, "description": description}
+	return c.writeMessage(5, msgCommandAMF0, streamID, encodeAMF("onStatus", 0, nil, info))
+}
+
+// writeMessage sends a message split into chunks of the outgoing chunk size
+func (c *rtmpConn) writeMessage(csid byte, typeID byte, streamID uint32, payload []byte) error {
+	header := []byte{csid & 0x3f, 0, 0, 0}
+	length := uint32(len(payload))
+	header = append(header, byte(length>>16), byte(length>>8), byte(length), typeID)
+	header = binary.LittleEndian.AppendUint32(header, streamID)
+	c.w.Write(header)
+	for sent := uint32(0); ; {
+		end := sent + c.outChunk
+		if end > length {
+			end = length
+		}
+		c.w.Write(payload[sent:end])
+		sent = end
+		if sent >= length {
+			break
+		}
+		c.w.WriteByte(0xc0 | csid&0x3f)
+	}
+	return c.w.Flush()
+}
@@ -0,0 +1,149 @@
+package live
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// testConn returns a connection reading the given bytes from the client
+func testConn(t *testing.T, data []byte) *rtmpConn {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close() })
+	go func() {
+		client.Write(data)
+		client.Close()
+	}()
+	return &rtmpConn{
+		conn:        server,
+		r:           bufio.NewReader(server),
+		w:           bufio.NewWriter(io.Discard),
+		inChunkSize: defaultChunk,
+		outChunk:    defaultChunk,
+		streams:     map[uint32]*chunkStream{},
+	}
+}
+
+// fullHeader returns a type 0 chunk header
+func fullHeader(csid byte, timestamp, length uint32, typeID byte, streamID uint32) []byte {
+	b := []byte{csid & 0x3f, byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp)}
+	b = append(b, byte(length>>16), byte(length>>8), byte(length), typeID)
+	return binary.LittleEndian.AppendUint32(b, streamID)
+}
+
+// shortHeader returns a type 1 chunk header
+func shortHeader(csid byte, delta, length uint32, typeID byte) []byte {
+	b := []byte{0x40 | csid&0x3f, byte(delta >> 16), byte(delta >> 8), byte(delta)}
+	return append(b, byte(length>>16), byte(length>>8), byte(length), typeID)
+}
+
+func TestReadMessageReassemblesChunks(t *testing.T) {
+	payload := make([]byte, 300)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	var data []byte
+	data = append(data, fullHeader(4, 1000, 300, msgVideo, 1)...)
+	data = append(data, payload[:128]...)
+	data = append(data, 0xc4)
+	data = append(data, payload[128:256]...)
+	data = append(data, 0xc4)
+	data = append(data, payload[256:]...)
+
+	c := testConn(t, data)
+	msg, err := c.readMessage()
+	if err != nil {
+		t.Fatalf("readMessage: %v", err)
+	}
+	if msg.typeID != msgVideo || msg.streamID != 1 || msg.timestamp != 1000 {
+		t.Errorf("message header = type %d stream %d time %d", msg.typeID, msg.streamID, msg.timestamp)
+	}
+	if string(msg.payload) != string(payload) {
+		t.Error("payload was not reassembled in order")
+	}
+	if c.buffered != 0 {
+		t.Errorf("buffered = %d after a complete message, want 0", c.buffered)
+	}
+}
+
+func TestReadMessageTimestampDeltas(t *testing.T) {
+	var data []byte
+	data = append(data, fullHeader(4, 1000, 1, msgAudio, 1)...)
+	data = append(data, 0)
+	data = append(data, shortHeader(4, 20, 1, msgAudio)...)
+	data = append(data, 0)
+	data = append(data, 0xc4, 0) // Type 3 repeats the delta for a new message
+
+	c := testConn(t, data)
+	for _, want := range []uint32{1000, 1020, 1040} {
+		msg, err := c.readMessage()
+		if err != nil {
+			t.Fatalf("readMessage: %v", err)
+		}
+		if msg.timestamp != want {
+			t.Errorf("timestamp = %d, want %d", msg.timestamp, want)
+		}
+	}
+}
+
+func TestReadMessageRejectsHeaderChangeMidMessage(t *testing.T) {
+	var data []byte
+	data = append(data, fullHeader(4, 0, 200, msgVideo, 1)...)
+	data = append(data, make([]byte, 128)...)
+	// Shrinking the length below the buffered bytes used to wrap the remaining size
+	data = append(data, shortHeader(4, 0, 10, msgVideo)...)
+	data = append(data, make([]byte, 10)...)
+
+	c := testConn(t, data)
+	if _, err := c.readMessage(); err == nil {
+		t.Fatal("readMessage succeeded, want an error")
+	}
+}
+
+func TestReadMessageRejectsOversizedMessage(t *testing.T) {
+	c := testConn(t, fullHeader(4, 0, maxMessageSize+1, msgVideo, 1))
+	if _, err := c.readMessage(); err == nil {
+		t.Fatal("readMessage succeeded, want an error")
+	}
+}
+
+func TestReadMessageLimitsChunkStreams(t *testing.T) {
+	var data []byte
+	for csid := byte(3); csid < 3+maxChunkStreams+1; csid++ {
+		// Each stream starts a message and leaves it incomplete
+		data = append(data, fullHeader(csid, 0, 1000, msgVideo, 1)...)
+		data = append(data, make([]byte, defaultChunk)...)
+	}
+
+	c := testConn(t, data)
+	_, err := c.readMessage()
+	if err == nil || len(c.streams) > maxChunkStreams {
+		t.Fatalf("readMessage = %v with %d chunk streams, want an error at %d", err, len(c.streams), maxChunkStreams)
+	}
+}
+
+func TestSetChunkSizeLimit(t *testing.T) {
+	c := testConn(t, nil)
+	tests := []struct {
+		size uint32
+		ok   bool
+	}{
+		{4096, true},
+		{maxChunkSize, true},
+		{maxChunkSize + 1, false},
+		{0x7fffffff, false},
+		{0, false},
+	}
+	for _, tt := range tests {
+		err := c.handle(message{typeID: msgSetChunkSize, payload: binary.BigEndian.AppendUint32(nil, tt.size)})
+		if (err == nil) != tt.ok {
+			t.Errorf("set chunk size %d: err = %v, want ok %v", tt.size, err, tt.ok)
+		}
+	}
+	if c.inChunkSize != maxChunkSize {
+		t.Errorf("inChunkSize = %d, want %d", c.inChunkSize, maxChunkSize)
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"hub/cli"
 	"hub/config"
+	"hub/controller"
 	"hub/routes"
 
 	_ "hub/docs"
@@ -21,6 +28,19 @@ import (
 func main() {
 	// Connect to MongoDB
 	config.ConnectDB()
+	config.EnsureIndexes()
+
+	// Subcommands such as "hub import" run against the database and exit
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
+	// Write buffered view counts in the background
+	controller.StartViewCounter()
+	controller.LoadSearchIndex()
+	controller.StartWebhookDispatcher()
+	controller.StartLiveIngest()
+	controller.StartDataExports()
 
 	// Create a new router
 	r := mux.NewRouter()
@@ -31,7 +51,26 @@ func main() {
 	// Add Swagger documentation
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
+	// Stop taking requests on SIGINT or SIGTERM, letting running ones finish
+	server := &http.Server{Addr: ":8080", Handler: r}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
 	// Start the HTTP server
 	log.Println("Server started at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+
+	// Write the view counts buffered since the last flush
+	controller.FlushViews()
 }
@@ -0,0 +1,46 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"hub/models"
+
+	"github.com/dhowden/tag"
+)
+
+// Artwork is a picture embedded in an audio file's tags
+type Artwork struct {
+	MIMEType string
+	Data     []byte
+}
+
+// ReadAudioTags reads the ID3, Vorbis or MP4 tags of an audio file. The reader is
+// left at an arbitrary position; callers seek back before reusing it.
+func ReadAudioTags(r io.ReadSeeker) (info models.AudioInfo, title string, artwork *Artwork, err error) {
+	// Tags come from untrusted uploads; a malformed frame must not take the server down
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("malformed tags: %v", p)
+		}
+	}()
+
+	meta, err := tag.ReadFrom(r)
+	if err != nil {
+		return info, "", nil, err
+	}
+	info.Artist = strings.TrimSpace(meta.Artist())
+	info.AlbumArtist = strings.TrimSpace(meta.AlbumArtist())
+	info.Album = strings.TrimSpace(meta.Album())
+	info.Track, info.TrackTotal = meta.Track()
+	info.Disc, _ = meta.Disc()
+	info.Year = meta.Year()
+	info.Genre = strings.TrimSpace(meta.Genre())
+	info.TagFormat = string(meta.Format())
+
+	if picture := meta.Picture(); picture != nil && len(picture.Data) > 0 {
+		artwork = &Artwork{MIMEType: picture.MIMEType, Data: picture.Data}
+	}
+	return info, strings.TrimSpace(meta.Title()), artwork, nil
+}
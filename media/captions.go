@@ -0,0 +1,212 @@
+package media
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a single timed caption
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// ParseCaptions parses SRT or WebVTT caption data and validates the cue timing
+func ParseCaptions(data string) ([]Cue, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+
+	cues, err := parseBlocks(data, strings.HasPrefix(data, "WEBVTT"))
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateCues(cues); err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+// parseBlocks reads blank-line separated cue blocks shared by SRT and WebVTT
+func parseBlocks(data string, vtt bool) ([]Cue, error) {
+	var cues []Cue
+	blocks := strings.Split(data, "\n\n")
+	for i, block := range blocks {
+		block = strings.Trim(block, "\n")
+		if block == "" {
+			continue
+		}
+		lines := strings.Split(block, "\n")
+
+		// Skip the WebVTT header and NOTE, STYLE and REGION blocks
+		if vtt && (i == 0 || strings.HasPrefix(lines[0], "NOTE") || lines[0] == "STYLE" || lines[0] == "REGION") {
+			continue
+		}
+
+		// The timing line follows an optional identifier (SRT sequence number or VTT cue ID)
+		timing := 0
+		if !strings.Contains(lines[0], "-->") {
+			timing = 1
+		}
+		if timing >= len(lines) || !strings.Contains(lines[timing], "-->") {
+			return nil, fmt.Errorf("cue %q has no timing line", lines[0])
+		}
+
+		start, end, err := parseTiming(lines[timing])
+		if err != nil {
+			return nil, err
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: strings.Join(lines[timing+1:], "\n")})
+	}
+	return cues, nil
+}
+
+// parseTiming parses "start --> end [settings]"
+func parseTiming(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, err := parseTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("invalid timing line %q", line)
+	}
+	end, err := parseTimestamp(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseTimestamp accepts hh:mm:ss,mmm (SRT), hh:mm:ss.mmm and mm:ss.mmm (WebVTT)
+func parseTimestamp(value string) (time.Duration, error) {
+	normalized := strings.Replace(value, ",", ".", 1)
+	parts := strings.Split(normalized, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	var hours, minutes int
+	var err error
+	if len(parts) == 3 {
+		if hours, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		parts = parts[1:]
+	}
+	if minutes, err = strconv.Atoi(parts[0]); err != nil || minutes > 59 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	// Seconds are digits with an optional fraction; ParseFloat alone would take "NaN" or "1e1"
+	if strings.Trim(parts[1], "0123456789.") != "" || strings.Count(parts[1], ".") > 1 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || seconds >= 60 || hours < 0 || minutes < 0 || seconds < 0 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	total := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	return total + time.Duration(seconds*float64(time.Second)+0.5), nil
+}
+
+// ValidateCues checks that every cue ends after it starts and that cues are ordered by start time
+func ValidateCues(cues []Cue) error {
+	if len(cues) == 0 {
+		return fmt.Errorf("no cues found")
+	}
+	for i, cue := range cues {
+		if cue.End <= cue.Start {
+			return fmt.Errorf("cue %d ends at %s before it starts at %s", i+1, FormatTimestamp(cue.End), FormatTimestamp(cue.Start))
+		}
+		if i > 0 && cue.Start < cues[i-1].Start {
+			return fmt.Errorf("cue %d starts at %s before the previous cue", i+1, FormatTimestamp(cue.Start))
+		}
+	}
+	return nil
+}
+
+// RenderWebVTT writes cues as a WebVTT document
+func RenderWebVTT(cues []Cue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", FormatTimestamp(cue.Start), FormatTimestamp(cue.End), cueText(cue.Text))
+	}
+	return b.String()
+}
+
+// vttTags are the markup tags WebVTT cue text may contain
+var vttTags = map[string]bool{"b": true, "c": true, "i": true, "lang": true, "ruby": true, "rt": true, "u": true, "v": true}
+
+// cueText makes cue text valid WebVTT. Tags WebVTT does not know, such as the
+// <font> tags common in SRT files, are dropped and known ones are lower-cased;
+// a "<" that opens no tag and the "-->" sequence are escaped.
+func cueText(text string) string {
+	text = strings.ReplaceAll(text, "-->", "--&gt;")
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(text, '<')
+		if open < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:open])
+		text = text[open:]
+		end := strings.IndexByte(text, '>')
+		if end < 0 {
+			b.WriteString("&lt;")
+			text = text[1:]
+			continue
+		}
+
+		tag := text[1:end]
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.TrimPrefix(tag, "/")
+		if i := strings.IndexAny(name, ". \t"); i >= 0 {
+			name = name[:i]
+		}
+		switch {
+		case name == "":
+			// Not a tag, as in "a < b > c"
+			b.WriteString("&lt;")
+			text = text[1:]
+			continue
+		case vttTags[strings.ToLower(name)]:
+			prefix := "<"
+			if closing {
+				prefix = "</"
+			}
+			b.WriteString(prefix + strings.ToLower(name) + strings.TrimPrefix(tag, "/")[len(name):] + ">")
+		case !closing && name[0] >= '0' && name[0] <= '9':
+			// Karaoke-style timestamp tags are WebVTT too
+			b.WriteString(text[:end+1])
+		}
+		text = text[end+1:]
+	}
+}
+
+// FormatTimestamp formats a duration as a WebVTT timestamp
+func FormatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// SubtitlePlaylist wraps a WebVTT file in a single-segment HLS media playlist
+func SubtitlePlaylist(uri string, duration time.Duration) string {
+	seconds := duration.Seconds()
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(seconds+0.999))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	fmt.Fprintf(&b, "#EXTINF:%.3f,\n", seconds)
+	b.WriteString(uri + "\n")
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package media
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCaptions(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name string
+		data string
+		cues []Cue
+		err  string
+	}{
+		{
+			name: "srt",
+			data: "1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nTwo\r\nlines\r\n",
+			cues: []Cue{{1000 * ms, 2500 * ms, "Hello"}, {3000 * ms, 4000 * ms, "Two\nlines"}},
+		},
+		{
+			name: "srt with byte order mark",
+			data: "\ufeff1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+			cues: []Cue{{1000 * ms, 2000 * ms, "Hello"}},
+		},
+		{
+			name: "vtt",
+			data: "WEBVTT - Title\n\nNOTE a comment\n\nSTYLE\n::cue { color: red }\n\nintro\n00:01.000 --> 00:02.000 align:start\nHello\n\n01:00:00.000 --> 01:00:01.250\nLater\n",
+			cues: []Cue{{1000 * ms, 2000 * ms, "Hello"}, {time.Hour, time.Hour + 1250*ms, "Later"}},
+		},
+		{
+			name: "overlapping cues",
+			data: "WEBVTT\n\n00:00:01.000 --> 00:00:05.000\nFirst\n\n00:00:02.000 --> 00:00:03.000\nSecond\n",
+			cues: []Cue{{1000 * ms, 5000 * ms, "First"}, {2000 * ms, 3000 * ms, "Second"}},
+		},
+		{
+			name: "same start",
+			data: "1\n00:00:01,000 --> 00:00:02,000\nTop\n\n2\n00:00:01,000 --> 00:00:02,000\nBottom\n",
+			cues: []Cue{{1000 * ms, 2000 * ms, "Top"}, {1000 * ms, 2000 * ms, "Bottom"}},
+		},
+		{
+			name: "out of order",
+			data: "1\n00:00:05,000 --> 00:00:06,000\nLater\n\n2\n00:00:01,000 --> 00:00:02,000\nEarlier\n",
+			err:  "cue 2 starts at 00:00:01.000 before the previous cue",
+		},
+		{
+			name: "ends before it starts",
+			data: "1\n00:00:02,000 --> 00:00:01,000\nBackwards\n",
+			err:  "cue 1 ends at 00:00:01.000 before it starts at 00:00:02.000",
+		},
+		{
+			name: "empty cue",
+			data: "1\n00:00:01,000 --> 00:00:01,000\nNothing\n",
+			err:  "cue 1 ends",
+		},
+		{name: "no cues", data: "WEBVTT\n\nNOTE only a note\n", err: "no cues found"},
+		{name: "no timing line", data: "1\nHello\n", err: `cue "1" has no timing line`},
+		{name: "no end", data: "1\n00:00:01,000 -->\nHello\n", err: "invalid timing line"},
+		{name: "minutes out of range", data: "1\n00:60:00,000 --> 00:61:00,000\nHello\n", err: `invalid timestamp "00:60:00,000"`},
+		{name: "seconds out of range", data: "1\n00:00:60,000 --> 00:01:00,000\nHello\n", err: `invalid timestamp "00:00:60,000"`},
+		{name: "letters", data: "1\n00:00:0a,000 --> 00:00:02,000\nHello\n", err: "invalid timestamp"},
+		{name: "not a number", data: "1\n00:00:NaN --> 00:00:02,000\nHello\n", err: "invalid timestamp"},
+		{name: "exponent", data: "1\n00:00:1e1 --> 00:00:20,000\nHello\n", err: "invalid timestamp"},
+		{name: "negative", data: "1\n-01:00:00,000 --> 00:00:02,000\nHello\n", err: "invalid timestamp"},
+		{name: "too many fields", data: "1\n00:00:00:01,000 --> 00:00:02,000\nHello\n", err: "invalid timestamp"},
+		{name: "seconds only", data: "1\n01,000 --> 02,000\nHello\n", err: "invalid timestamp"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cues, err := ParseCaptions(test.data)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cues) != len(test.cues) {
+				t.Fatalf("got %d cues, want %d: %+v", len(cues), len(test.cues), cues)
+			}
+			for i := range cues {
+				if cues[i] != test.cues[i] {
+					t.Errorf("cue %d = %+v, want %+v", i+1, cues[i], test.cues[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderWebVTT(t *testing.T) {
+	cues := []Cue{
+		{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "Hello"},
+		{Start: time.Hour + 61*time.Second, End: time.Hour + 62*time.Second + 5*time.Millisecond, Text: "Two\nlines"},
+	}
+	want := "WEBVTT\n\n00:00:01.500 --> 00:00:02.000\nHello\n\n01:01:01.000 --> 01:01:02.005\nTwo\nlines\n"
+	if got := RenderWebVTT(cues); got != want {
+		t.Errorf("RenderWebVTT = %q, want %q", got, want)
+	}
+
+	// What is rendered parses back to the same cues
+	parsed, err := ParseCaptions(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range cues {
+		if parsed[i] != cues[i] {
+			t.Errorf("cue %d parsed back as %+v, want %+v", i+1, parsed[i], cues[i])
+		}
+	}
+}
+
+func TestCueText(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"plain", "plain"},
+		{"<i>italic</i> and <b>bold</b>", "<i>italic</i> and <b>bold</b>"},
+		{"<I>upper</I>", "<i>upper</i>"},
+		{`<font color="#ffff00">yellow</font>`, "yellow"},
+		{`<font face="Arial"><i>both</i></font>`, "<i>both</i>"},
+		{"<v Roger>Hi</v>", "<v Roger>Hi</v>"},
+		{"<c.loud>Hey</c>", "<c.loud>Hey</c>"},
+		{"<00:00:01.000>karaoke", "<00:00:01.000>karaoke"},
+		{"<br>", ""},
+		{"a < b", "a &lt; b"},
+		{"a < b > c", "a &lt; b > c"},
+		{"x --> y", "x --&gt; y"},
+	}
+	for _, test := range tests {
+		if got := cueText(test.text); got != test.want {
+			t.Errorf("cueText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
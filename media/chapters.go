@@ -0,0 +1,95 @@
+package media
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"hub/models"
+)
+
+// chapterLine matches description lines such as "00:00 Intro", "1:02:03 - Q&A" or "[12:30] Demo"
+var chapterLine = regexp.MustCompile(`^\s*[\(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\)\]]?\s*(?:[-–—:|]\s*)?(\S.*)$`)
+
+// ParseDescriptionChapters extracts chapters from timestamp lines of a description.
+// Like most video platforms, the list only counts when it starts at 0:00 and has at least two entries.
+func ParseDescriptionChapters(description string) []models.Chapter {
+	var chapters []models.Chapter
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		start, err := parseClock(match[1])
+		if err != nil {
+			continue
+		}
+		chapters = append(chapters, models.Chapter{Start: start, Title: strings.TrimSpace(match[2])})
+	}
+
+	if len(chapters) < 2 || chapters[0].Start != 0 {
+		return nil
+	}
+	if err := ValidateChapters(chapters, 0); err != nil {
+		return nil
+	}
+	return chapters
+}
+
+// parseClock parses "m:ss", "mm:ss" or "h:mm:ss" into seconds
+func parseClock(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	total := 0
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 && n > 59 {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		total = total*60 + n
+	}
+	return float64(total), nil
+}
+
+// ValidateChapters checks that chapters have titles, are strictly ordered and fall within the duration.
+// A zero duration skips the upper bound check for videos that have not been probed yet.
+func ValidateChapters(chapters []models.Chapter, duration float64) error {
+	for i, chapter := range chapters {
+		if strings.TrimSpace(chapter.Title) == "" {
+			return fmt.Errorf("chapter %d has no title", i+1)
+		}
+		if chapter.Start < 0 {
+			return fmt.Errorf("chapter %d starts before the video", i+1)
+		}
+		if i > 0 && chapter.Start <= chapters[i-1].Start {
+			return fmt.Errorf("chapter %d does not start after chapter %d", i+1, i)
+		}
+		if duration > 0 && chapter.Start >= duration {
+			return fmt.Errorf("chapter %d starts after the end of the video", i+1)
+		}
+	}
+	return nil
+}
+
+// RenderChaptersVTT writes chapters as a WebVTT chapters track; each chapter ends where the next begins
+func RenderChaptersVTT(chapters []models.Chapter, duration float64) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, chapter := range chapters {
+		end := duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		fmt.Fprintf(&b, "\nchapter-%d\n%s --> %s\n%s\n", i+1, FormatTimestamp(seconds(chapter.Start)), FormatTimestamp(seconds(end)), chapter.Title)
+	}
+	return b.String()
+}
+
+// seconds converts fractional seconds to a duration
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
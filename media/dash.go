@@ -0,0 +1,368 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MediaPlaylist is the parsed form of an fMP4 HLS media playlist
+type MediaPlaylist struct {
+	InitURI   string    // URI from EXT-X-MAP
+	Segments  []string  // Segment URIs in order
+	Durations []float64 // Segment durations in seconds
+}
+
+// ParseMediaPlaylist reads the init segment and segment list of an HLS media playlist
+func ParseMediaPlaylist(data string) (MediaPlaylist, error) {
+	var playlist MediaPlaylist
+	var pending float64
+	havePending := false
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			playlist.InitURI = attribute(line, "URI")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.Index(value, ","); i >= 0 {
+				value = value[:i]
+			}
+			duration, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return playlist, fmt.Errorf("invalid EXTINF %q", line)
+			}
+			pending, havePending = duration, true
+		case strings.HasPrefix(line, "#"):
+		default:
+			if !havePending {
+				return playlist, fmt.Errorf("segment %q has no EXTINF", line)
+			}
+			playlist.Segments = append(playlist.Segments, line)
+			playlist.Durations = append(playlist.Durations, pending)
+			havePending = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return playlist, err
+	}
+	if playlist.InitURI == "" {
+		return playlist, fmt.Errorf("playlist is not fragmented MP4")
+	}
+	if len(playlist.Segments) == 0 {
+		return playlist, fmt.Errorf("playlist has no segments")
+	}
+	return playlist, nil
+}
+
+// attribute extracts a quoted attribute value from an HLS tag
+func attribute(line, name string) string {
+	i := strings.Index(line, name+"=\"")
+	if i < 0 {
+		return ""
+	}
+	value := line[i+len(name)+2:]
+	if j := strings.Index(value, "\""); j >= 0 {
+		return value[:j]
+	}
+	return value
+}
+
+// Representation is one rendition to be listed in an MPD
+type Representation struct {
+	ID        string
+	Width     int
+	Height    int
+	Bandwidth int
+	Codecs    string // RFC 6381 codecs, left out of the MPD when empty
+	Playlist  MediaPlaylist
+}
+
+type mpd struct {
+	XMLName                   xml.Name    `xml:"MPD"`
+	Xmlns                     string      `xml:"xmlns,attr"`
+	Profiles                  string      `xml:"profiles,attr"`
+	Type                      string      `xml:"type,attr"`
+	MediaPresentationDuration string      `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string      `xml:"minBufferTime,attr"`
+	Periods                   []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	MimeType         string              `xml:"mimeType,attr"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	StartWithSAP     int                 `xml:"startWithSAP,attr"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID              string             `xml:"id,attr"`
+	Codecs          string             `xml:"codecs,attr,omitempty"`
+	Bandwidth       int                `xml:"bandwidth,attr"`
+	Width           int                `xml:"width,attr,omitempty"`
+	Height          int                `xml:"height,attr,omitempty"`
+	SegmentTemplate mpdSegmentTemplate `xml:"SegmentTemplate"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale       int                `xml:"timescale,attr"`
+	Initialization  string             `xml:"initialization,attr"`
+	Media           string             `xml:"media,attr"`
+	StartNumber     int                `xml:"startNumber,attr"`
+	SegmentTimeline mpdSegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type mpdSegmentTimeline struct {
+	Segments []mpdS `xml:"S"`
+}
+
+type mpdS struct {
+	T int64 `xml:"t,attr,omitempty"`
+	D int64 `xml:"d,attr"`
+	R int   `xml:"r,attr,omitempty"`
+}
+
+// numbered matches the trailing segment number of a segment file name
+var numbered = regexp.MustCompile(`^(.*?)(\d+)(\.[A-Za-z0-9]+)$`)
+
+// DASHManifest renders a static MPD for the given fMP4 representations.
+// Segments are addressed through SegmentTemplate with an explicit SegmentTimeline,
+// so the files written for HLS are served unchanged.
+func DASHManifest(representations []Representation) (string, error) {
+	const timescale = 1000
+
+	set := mpdAdaptationSet{MimeType: "video/mp4", SegmentAlignment: true, StartWithSAP: 1}
+	var total float64
+	for _, rep := range representations {
+		template, duration, err := segmentTemplate(rep, timescale)
+		if err != nil {
+			return "", fmt.Errorf("representation %s: %v", rep.ID, err)
+		}
+		if duration > total {
+			total = duration
+		}
+		set.Representations = append(set.Representations, mpdRepresentation{
+			ID:              rep.ID,
+			Codecs:          rep.Codecs,
+			Bandwidth:       rep.Bandwidth,
+			Width:           rep.Width,
+			Height:          rep.Height,
+			SegmentTemplate: template,
+		})
+	}
+	if len(set.Representations) == 0 {
+		return "", fmt.Errorf("no representations")
+	}
+
+	manifest := mpd{
+		Xmlns:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                      "static",
+		MediaPresentationDuration: isoDuration(total),
+		MinBufferTime:             "PT2S",
+		Periods: []mpdPeriod{{
+			ID:             "0",
+			Start:          "PT0S",
+			AdaptationSets: []mpdAdaptationSet{set},
+		}},
+	}
+
+	out, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(out) + "\n", nil
+}
+
+// segmentTemplate builds the SegmentTemplate of a representation and returns its total duration
+func segmentTemplate(rep Representation, timescale int) (mpdSegmentTemplate, float64, error) {
+	playlist := rep.Playlist
+	match := numbered.FindStringSubmatch(playlist.Segments[0])
+	if match == nil {
+		return mpdSegmentTemplate{}, 0, fmt.Errorf("segment %q is not numbered", playlist.Segments[0])
+	}
+	start, _ := strconv.Atoi(match[2])
+
+	// Segments must be numbered consecutively for $Number$ addressing to hold
+	for i, segment := range playlist.Segments {
+		expected := fmt.Sprintf("%s%0*d%s", match[1], len(match[2]), start+i, match[3])
+		if segment != expected {
+			return mpdSegmentTemplate{}, 0, fmt.Errorf("segment %q breaks numbering", segment)
+		}
+	}
+
+	var timeline []mpdS
+	var total float64
+	for _, seconds := range playlist.Durations {
+		total += seconds
+		d := int64(seconds*float64(timescale) + 0.5)
+		if n := len(timeline); n > 0 && timeline[n-1].D == d {
+			timeline[n-1].R++
+			continue
+		}
+		timeline = append(timeline, mpdS{D: d})
+	}
+
+	template := mpdSegmentTemplate{
+		Timescale:       timescale,
+		Initialization:  rep.ID + "/" + playlist.InitURI,
+		Media:           fmt.Sprintf("%s/%s$Number%%0%dd$%s", rep.ID, match[1], len(match[2]), match[3]),
+		StartNumber:     start,
+		SegmentTimeline: mpdSegmentTimeline{Segments: timeline},
+	}
+	return template, total, nil
+}
+
+// isoDuration formats seconds as an ISO 8601 duration
+func isoDuration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(seconds, 'f', 3, 64) + "S"
+}
+
+// sampleBoxes are the boxes walked to reach the codec configuration of an init
+// segment, with the bytes of fixed fields preceding their child boxes
+var sampleBoxes = map[string]int{
+	"moov": 0,
+	"trak": 0,
+	"mdia": 0,
+	"minf": 0,
+	"stbl": 0,
+	"stsd": 8,  // Version, flags and entry count
+	"avc1": 78, // Visual sample entry
+	"avc3": 78,
+	"mp4a": 28, // Audio sample entry
+}
+
+// InitCodecs returns the RFC 6381 codecs string of an fMP4 init segment, such as
+// "avc1.64001f,mp4a.40.2", read from the AVC and AAC configuration of its tracks.
+// Streams of other codecs are left out; an empty string means none was recognised.
+func InitCodecs(init []byte) string {
+	var codecs []string
+	entry := ""
+	walkBoxes(init, func(kind string, body []byte) {
+		switch kind {
+		case "avc1", "avc3":
+			entry = kind
+		case "avcC":
+			// Configuration version, then profile, profile compatibility and level
+			if len(body) >= 4 {
+				codecs = append(codecs, fmt.Sprintf("%s.%02x%02x%02x", entry, body[1], body[2], body[3]))
+			}
+		case "esds":
+			if codec := esdsCodec(body); codec != "" {
+				codecs = append(codecs, codec)
+			}
+		}
+	})
+	return strings.Join(codecs, ",")
+}
+
+// walkBoxes calls visit for every box of data and the children of sampleBoxes
+func walkBoxes(data []byte, visit func(kind string, body []byte)) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		kind := string(data[4:8])
+		header := 8
+		switch size {
+		case 0:
+			size = len(data)
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size64 := binary.BigEndian.Uint64(data[8:])
+			if size64 > uint64(len(data)) {
+				return
+			}
+			size, header = int(size64), 16
+		}
+		if size < header || size > len(data) {
+			return
+		}
+		body := data[header:size]
+		visit(kind, body)
+		if skip, ok := sampleBoxes[kind]; ok && len(body) >= skip {
+			walkBoxes(body[skip:], visit)
+		}
+		data = data[size:]
+	}
+}
+
+// esdsCodec returns "mp4a.<object type>.<audio object type>" from an elementary
+// stream descriptor box
+func esdsCodec(body []byte) string {
+	if len(body) < 4 {
+		return ""
+	}
+	r := body[4:] // Version and flags
+	tag, es := descriptor(r)
+	if tag != 0x03 || len(es) < 3 {
+		return ""
+	}
+	flags := es[2]
+	es = es[3:] // ES_ID and flags
+	if flags&0x80 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	if flags&0x40 != 0 && len(es) >= 1 && len(es) > int(es[0]) {
+		es = es[1+int(es[0]):]
+	}
+	if flags&0x20 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	tag, config := descriptor(es)
+	if tag != 0x04 || len(config) < 13 {
+		return ""
+	}
+	objectType := config[0]
+	if objectType != 0x40 {
+		return fmt.Sprintf("mp4a.%02x", objectType)
+	}
+	// MPEG-4 audio names the audio object type from the decoder specific info
+	tag, info := descriptor(config[13:])
+	if tag != 0x05 || len(info) < 1 {
+		return "mp4a.40"
+	}
+	audioType := int(info[0] >> 3)
+	if audioType == 31 && len(info) >= 2 {
+		audioType = 32 + int(info[0]&0x07)<<3 + int(info[1]>>5)
+	}
+	return fmt.Sprintf("mp4a.40.%d", audioType)
+}
+
+// descriptor splits the first MPEG-4 descriptor of data into its tag and body
+func descriptor(data []byte) (byte, []byte) {
+	if len(data) < 2 {
+		return 0, nil
+	}
+	tag := data[0]
+	// The size takes up to four bytes of seven bits each
+	size, i := 0, 1
+	for {
+		if i >= len(data) || i > 4 {
+			return 0, nil
+		}
+		b := data[i]
+		i++
+		size = size<<7 | int(b&0x7f)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if size > len(data)-i {
+		return 0, nil
+	}
+	return tag, data[i : i+size]
+}
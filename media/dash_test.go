@@ -0,0 +1,76 @@
+package media
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// box returns an MP4 box of the given type holding the fields and children
+func box(kind string, fields []byte, children ...[]byte) []byte {
+	body := append([]byte{}, fields...)
+	for _, child := range children {
+		body = append(body, child...)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, kind...), body...)
+}
+
+// initSegment returns an init segment with the given sample entries in separate tracks
+func initSegment(entries ...[]byte) []byte {
+	var tracks [][]byte
+	for _, entry := range entries {
+		stsd := box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, entry)
+		tracks = append(tracks, box("trak", nil, box("mdia", nil, box("minf", nil, box("stbl", nil, stsd)))))
+	}
+	return append(box("ftyp", []byte("iso6")), box("moov", nil, tracks...)...)
+}
+
+func avcEntry(profile, compatibility, level byte) []byte {
+	return box("avc1", make([]byte, 78), box("avcC", []byte{1, profile, compatibility, level, 0xff}))
+}
+
+func aacEntry(audioObjectType byte) []byte {
+	info := []byte{0x05, 2, audioObjectType<<3 | 0x01, 0x90}
+	config := append([]byte{0x04, byte(13 + len(info)), 0x40, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, info...)
+	es := append([]byte{0x03, byte(3 + len(config)), 0, 1, 0}, config...)
+	return box("mp4a", make([]byte, 28), box("esds", append([]byte{0, 0, 0, 0}, es...)))
+}
+
+func TestInitCodecs(t *testing.T) {
+	tests := []struct {
+		name string
+		init []byte
+		want string
+	}{
+		{"main 3.1 with AAC-LC", initSegment(avcEntry(0x4d, 0x40, 0x1f), aacEntry(2)), "avc1.4d401f,mp4a.40.2"},
+		{"high 4.0 without audio", initSegment(avcEntry(0x64, 0x00, 0x28)), "avc1.640028"},
+		{"HE-AAC audio only", initSegment(aacEntry(5)), "mp4a.40.5"},
+		{"no tracks", box("moov", nil), ""},
+		{"truncated", initSegment(avcEntry(0x4d, 0x40, 0x1f))[:40], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InitCodecs(tt.init); got != tt.want {
+				t.Errorf("InitCodecs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDASHManifestCodecs(t *testing.T) {
+	playlist := MediaPlaylist{InitURI: "init.mp4", Segments: []string{"segment_00000.m4s"}, Durations: []float64{6}}
+	manifest, err := DASHManifest([]Representation{
+		{ID: "1080p", Width: 1920, Height: 1080, Bandwidth: 5192000, Codecs: "avc1.640028", Playlist: playlist},
+		{ID: "360p", Width: 640, Height: 360, Bandwidth: 896000, Playlist: playlist},
+	})
+	if err != nil {
+		t.Fatalf("DASHManifest: %v", err)
+	}
+	if !strings.Contains(manifest, `id="1080p" codecs="avc1.640028"`) {
+		t.Errorf("manifest does not carry the codecs of the 1080p rendition:\n%s", manifest)
+	}
+	if strings.Count(manifest, "codecs=") != 1 {
+		t.Errorf("manifest advertises codecs for a rendition without them:\n%s", manifest)
+	}
+}
@@ -0,0 +1,110 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"hub/config"
+	"hub/models"
+)
+
+// HLSArgs expands the ffmpeg argument template for one rung of the ladder.
+// {gop} is kept for custom templates; it is the segment length in frames at 30 fps
+// and misses segment boundaries at other frame rates, unlike -force_key_frames.
+func HLSArgs(template string, rung config.Rung, input, output string, segmentSeconds int) []string {
+	gop := segmentSeconds * 30
+	replacer := strings.NewReplacer(
+		"{input}", input,
+		"{output}", output,
+		"{width}", strconv.Itoa(rung.Width),
+		"{height}", strconv.Itoa(rung.Height),
+		"{vbitrate}", strconv.Itoa(rung.VideoBitrate),
+		"{vbuffer}", strconv.Itoa(rung.VideoBitrate*2),
+		"{abitrate}", strconv.Itoa(rung.AudioBitrate),
+		"{segment}", strconv.Itoa(segmentSeconds),
+		"{gop}", strconv.Itoa(gop),
+	)
+
+	// Split before substituting so paths containing spaces stay one argument
+	fields := strings.Fields(template)
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = replacer.Replace(field)
+	}
+	return args
+}
+
+// PackageHLS runs ffmpeg to write the HLS playlist and segments of one rung into output
+func PackageHLS(ctx context.Context, rung config.Rung, input, output string) error {
+	args := HLSArgs(config.HLSCommand, rung, input, output, config.HLSSegmentSeconds)
+	cmd := exec.CommandContext(ctx, config.FFmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg %s: %v: %s", rung.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// SubtitleTrack is a WebVTT track advertised in the master playlist
+type SubtitleTrack struct {
+	Name     string // Human readable label
+	Language string // BCP 47 language tag
+	URI      string // Subtitle media playlist, relative to the master playlist
+	Default  bool
+}
+
+// MasterPlaylist renders the HLS master playlist for the ready renditions and subtitle tracks
+func MasterPlaylist(renditions []models.Rendition, subtitles []SubtitleTrack) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for _, track := range subtitles {
+		isDefault := "NO"
+		if track.Default {
+			isDefault = "YES"
+		}
+		fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=%q,LANGUAGE=%q,DEFAULT=%s,AUTOSELECT=YES,URI=%q\n",
+			track.Name, track.Language, isDefault, track.URI)
+	}
+
+	group := ""
+	if len(subtitles) > 0 {
+		group = ",SUBTITLES=\"subs\""
+	}
+	for _, rendition := range renditions {
+		if rendition.Status != models.RenditionReady {
+			continue
+		}
+		codecs := ""
+		if rendition.Codecs != "" {
+			codecs = fmt.Sprintf(",CODECS=%q", rendition.Codecs)
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d%s%s\n", rendition.Bandwidth, rendition.Width, rendition.Height, codecs, group)
+		fmt.Fprintf(&b, "%s/index.m3u8\n", rendition.Name)
+	}
+	return b.String()
+}
+
+// ContentType returns the MIME type of a stored HLS file
+func ContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".m3u8"):
+		return "application/vnd.apple.mpegurl"
+	case strings.HasSuffix(name, ".m4s"), strings.HasSuffix(name, ".mp4"):
+		return "video/mp4"
+	case strings.HasSuffix(name, ".vtt"):
+		return "text/vtt"
+	case strings.HasSuffix(name, ".ts"):
+		return "video/mp2t"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,74 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"hub/config"
+)
+
+// LiveProcess is a running ffmpeg process turning an FLV stream into live HLS
+// and a recording. Writes go to its input; Close ends the stream.
+type LiveProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+}
+
+// LiveArgs expands the ffmpeg argument template for a live session
+func LiveArgs(template, output string, segmentSeconds, window int) []string {
+	replacer := strings.NewReplacer(
+		"{output}", output,
+		"{segment}", strconv.Itoa(segmentSeconds),
+		"{window}", strconv.Itoa(window),
+	)
+
+	// Split before substituting so paths containing spaces stay one argument
+	fields := strings.Fields(template)
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = replacer.Replace(field)
+	}
+	return args
+}
+
+// StartLive starts ffmpeg writing the live playlist, its segments and the
+// recording of a session into output
+func StartLive(ctx context.Context, output string) (*LiveProcess, error) {
+	args := LiveArgs(config.LiveCommand, output, config.LiveSegmentSeconds, config.LiveWindowSegments)
+	process := &LiveProcess{cmd: exec.CommandContext(ctx, config.FFmpegPath, args...)}
+	process.cmd.Stderr = &process.stderr
+
+	stdin, err := process.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	process.stdin = stdin
+	if err := process.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %v", err)
+	}
+	return process, nil
+}
+
+// Write passes stream data to ffmpeg
+func (p *LiveProcess) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+// Close ends the input so ffmpeg finishes the playlist and the recording
+func (p *LiveProcess) Close() error {
+	return p.stdin.Close()
+}
+
+// Wait waits for ffmpeg to exit after Close
+func (p *LiveProcess) Wait() error {
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(p.stderr.String()))
+	}
+	return nil
+}
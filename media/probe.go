@@ -0,0 +1,63 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"hub/config"
+	"hub/models"
+)
+
+// ProbeResult holds the properties of a media file read by ffprobe
+type ProbeResult struct {
+	Duration float64          // Container duration in seconds
+	Chapters []models.Chapter // Chapters from the container's chapter track
+}
+
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Chapters []struct {
+		StartTime string            `json:"start_time"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"chapters"`
+}
+
+// Probe runs ffprobe on a local file and returns its duration and chapters
+func Probe(ctx context.Context, input string) (ProbeResult, error) {
+	var result ProbeResult
+
+	cmd := exec.CommandContext(ctx, config.FFprobePath,
+		"-v", "error", "-print_format", "json", "-show_format", "-show_chapters", input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return result, fmt.Errorf("ffprobe: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output ffprobeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return result, fmt.Errorf("ffprobe: %v", err)
+	}
+
+	result.Duration, _ = strconv.ParseFloat(output.Format.Duration, 64)
+	for i, chapter := range output.Chapters {
+		start, err := strconv.ParseFloat(chapter.StartTime, 64)
+		if err != nil {
+			continue
+		}
+		title := strings.TrimSpace(chapter.Tags["title"])
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		result.Chapters = append(result.Chapters, models.Chapter{Start: start, Title: title})
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package media
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hub/config"
+)
+
+func TestProbeChapterTrack(t *testing.T) {
+	for _, tool := range []string{config.FFmpegPath, config.FFprobePath} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	dir := t.TempDir()
+	metadata := filepath.Join(dir, "chapters.txt")
+	err := os.WriteFile(metadata, []byte(";FFMETADATA1\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1500\ntitle=Intro\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=1500\nEND=3000\ntitle=Outro\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "chapters.mp4")
+	cmd := exec.Command(config.FFmpegPath, "-v", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=3:size=64x64:rate=10",
+		"-f", "ffmetadata", "-i", metadata, "-map", "0", "-map_chapters", "1",
+		"-c:v", "mpeg4", source)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg: %v: %s", err, out)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := Probe(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Duration < 2.9 || result.Duration > 3.1 {
+		t.Errorf("duration = %v, want about 3", result.Duration)
+	}
+	if len(result.Chapters) != 2 || result.Chapters[0].Title != "Intro" || result.Chapters[1].Start != 1.5 || result.Chapters[1].Title != "Outro" {
+		t.Errorf("chapters = %+v", result.Chapters)
+	}
+}
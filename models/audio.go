@@ -0,0 +1,59 @@
+package models
+
+import (
+	"path"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Media types; records without a type are videos
+const (
+	MediaVideo = "video"
+	MediaAudio = "audio"
+)
+
+// audioTypes maps the accepted audio file extensions to their content types
+var audioTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+}
+
+// AudioContentType returns the content type of an accepted audio file name
+func AudioContentType(fileName string) (string, bool) {
+	contentType, ok := audioTypes[strings.ToLower(path.Ext(fileName))]
+	return contentType, ok
+}
+
+// AudioInfo holds the tags of an audio track
+type AudioInfo struct {
+	Artist        string              `json:"artist,omitempty" bson:"artist,omitempty"`               // Track artist
+	AlbumArtist   string              `json:"albumArtist,omitempty" bson:"albumArtist,omitempty"`     // Album artist, the track artist when untagged
+	Album         string              `json:"album,omitempty" bson:"album,omitempty"`                 // Album title
+	Track         int                 `json:"track,omitempty" bson:"track,omitempty"`                 // Track number on the disc
+	TrackTotal    int                 `json:"trackTotal,omitempty" bson:"trackTotal,omitempty"`       // Number of tracks on the disc
+	Disc          int                 `json:"disc,omitempty" bson:"disc,omitempty"`                   // Disc number
+	Year          int                 `json:"year,omitempty" bson:"year,omitempty"`                   // Release year
+	Genre         string              `json:"genre,omitempty" bson:"genre,omitempty"`                 // Genre
+	TagFormat     string              `json:"tagFormat,omitempty" bson:"tagFormat,omitempty"`         // ID3v2.4, VORBIS, MP4, ...
+	ArtworkFileID *primitive.ObjectID `json:"artworkFileId,omitempty" bson:"artworkFileId,omitempty"` // Embedded artwork in the images bucket
+}
+
+// Artist is one entry of the artist listing
+type Artist struct {
+	Name   string `json:"name" bson:"_id"`      // Album artist
+	Albums int    `json:"albums" bson:"albums"` // Number of albums
+	Tracks int    `json:"tracks" bson:"tracks"` // Number of listable tracks
+}
+
+// Album is one entry of the album listing
+type Album struct {
+	Title     string              `json:"title" bson:"title"`                             // Album title
+	Artist    string              `json:"artist" bson:"artist"`                           // Album artist
+	Year      int                 `json:"year,omitempty" bson:"year,omitempty"`           // Latest release year of its tracks
+	Tracks    int                 `json:"tracks" bson:"tracks"`                           // Number of listable tracks
+	ArtworkID *primitive.ObjectID `json:"artworkId,omitempty" bson:"artworkId,omitempty"` // Track whose artwork represents the album
+}
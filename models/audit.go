@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions
+const (
+	AuditLoginSucceeded               = "login.succeeded"                // A user signed in
+	AuditLoginFailed                  = "login.failed"                   // A sign-in was refused; the target is the attempted username
+	AuditUserCreated                  = "user.created"                   // An account was created
+	AuditUserQuotaChanged             = "user.quota_changed"             // An admin changed a user's storage quota
+	AuditUserGroupsChanged            = "user.groups_changed"            // An admin changed a user's group memberships
+	AuditVideoUploaded                = "video.uploaded"                 // A video or audio track was uploaded or imported
+	AuditVideoDeleted                 = "video.deleted"                  // A video was deleted
+	AuditVideoVisibilityChanged       = "video.visibility_changed"       // Who may watch a video changed
+	AuditCommentDeleted               = "comment.deleted"                // A comment was deleted
+	AuditCommentModerated             = "comment.moderated"              // A comment was hidden, unhidden, pinned or unpinned
+	AuditChannelTeamChanged           = "channel.team_changed"           // The team allowed to manage a channel changed
+	AuditChannelDeleted               = "channel.deleted"                // A channel was deleted
+	AuditPlaylistCollaboratorsChanged = "playlist.collaborators_changed" // The users allowed to edit a playlist changed
+	AuditPlaylistDeleted              = "playlist.deleted"               // A playlist was deleted
+	AuditCategoryDeleted              = "category.deleted"               // A category was deleted
+	AuditCaptionDeleted               = "caption.deleted"                // A caption track was deleted
+	AuditWebhookCreated               = "webhook.created"                // A webhook was registered
+	AuditWebhookUpdated               = "webhook.updated"                // A webhook was changed
+	AuditWebhookDeleted               = "webhook.deleted"                // A webhook was removed
+	AuditArchiveExported              = "archive.exported"               // An admin downloaded an archive of the hub
+	AuditDataExportRequested          = "data_export.requested"          // A user's data export was requested
+	AuditDataExportCompleted          = "data_export.completed"          // The archive of a data export was built
+	AuditDataExportDownloaded         = "data_export.downloaded"         // The archive was fetched through its signed link
+)
+
+// AuditEntry records who did what to which resource. Entries are only ever
+// appended; each one carries the hash of the entry before it, so removing or
+// editing an entry breaks the chain from that point on.
+type AuditEntry struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`                        // MongoDB Object ID
+	Seq        int64              `json:"seq" bson:"seq"`                                 // Position in the chain, starting at 1
+	At         time.Time          `json:"at" bson:"at"`                                   // When the action happened
+	ActorID    string             `json:"actorId,omitempty" bson:"actorId,omitempty"`     // Acting user, empty for the system, anonymous requests or a signed link
+	Action     string             `json:"action" bson:"action"`                           // What happened, e.g. video.deleted
+	TargetType string             `json:"targetType" bson:"targetType"`                   // Kind of resource acted on
+	TargetID   string             `json:"targetId" bson:"targetId"`                       // ID of that resource
+	IP         string             `json:"ip,omitempty" bson:"ip,omitempty"`               // Client address of the request
+	UserAgent  string             `json:"userAgent,omitempty" bson:"userAgent,omitempty"` // Client user agent
+	RequestID  string             `json:"requestId,omitempty" bson:"requestId,omitempty"` // X-Request-ID of the request
+	Changes    []AuditChange      `json:"changes,omitempty" bson:"changes,omitempty"`     // Fields that changed; everything set for creations and deletions
+	PrevHash   string             `json:"prevHash" bson:"prevHash"`                       // Hash of the previous entry, empty for the first
+	Hash       string             `json:"hash" bson:"hash"`                               // SHA-256 over this entry and PrevHash
+}
+
+// AuditChange is the before and after value of one field, each as JSON text.
+// An empty value means the field was not set; secrets are listed without values.
+type AuditChange struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before,omitempty" bson:"before,omitempty"`
+	After  string `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// AuditVerification is the result of checking the hash chain of the audit log
+type AuditVerification struct {
+	Checked  int64  `json:"checked"`            // Entries checked
+	Valid    bool   `json:"valid"`              // Every entry matched its hash and its predecessor
+	BrokenAt int64  `json:"brokenAt,omitempty"` // Sequence number of the first entry that does not match
+	BrokenID string `json:"brokenId,omitempty"` // ID of that entry, which identifies it when its sequence number is missing
+	Reason   string `json:"reason,omitempty"`   // Why the chain is broken there
+}
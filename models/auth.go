@@ -1,7 +1,17 @@
 package models
 
+import "time"
+
 // LoginCredentials represents the username and password required for login
 type LoginCredentials struct {
     Username string `json:"username"`
     Password string `json:"password"`
 }
+
+// Session represents an authenticated login, identified by its token
+type Session struct {
+    Token     string    `json:"token" bson:"_id"`
+    UserID    string    `json:"userId" bson:"userId"`
+    CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+    ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Caption represents a subtitle or caption track of a video, stored as WebVTT
+type Caption struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`    // MongoDB Object ID
+	VideoID   primitive.ObjectID `json:"videoId" bson:"videoId"`     // Video the track belongs to
+	Language  string             `json:"language" bson:"language"`   // BCP 47 language tag, e.g. "en"
+	Label     string             `json:"label" bson:"label"`         // Human readable track name
+	Default   bool               `json:"default" bson:"default"`     // Selected by players when no preference is set
+	CueCount  int                `json:"cueCount" bson:"cueCount"`   // Number of cues in the track
+	Duration  float64            `json:"duration" bson:"duration"`   // End of the last cue in seconds
+	Content   string             `json:"-" bson:"content"`           // WebVTT document
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"` // Upload time
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category is a node of the admin-managed category tree
+type Category struct {
+	ID        primitive.ObjectID   `json:"id" bson:"_id,omitempty"`                        // MongoDB Object ID
+	Name      string               `json:"name" bson:"name"`                               // Display name
+	Slug      string               `json:"slug" bson:"slug"`                               // Normalized name, unique among siblings
+	ParentID  *primitive.ObjectID  `json:"parentId,omitempty" bson:"parentId,omitempty"`   // Parent category, nil for top-level categories
+	Ancestors []primitive.ObjectID `json:"ancestors,omitempty" bson:"ancestors,omitempty"` // Path from the root down to the parent
+	CreatedAt time.Time            `json:"createdAt" bson:"createdAt"`
+}
+
+// CategoryNode is a category with its children and video counts
+type CategoryNode struct {
+	Category
+	VideoCount int64          `json:"videoCount"` // Visible videos directly in the category
+	TotalCount int64          `json:"totalCount"` // Visible videos in the category and its descendants
+	Children   []CategoryNode `json:"children"`
+}
+
+// CategoryInput is the body used to create, rename or move a category
+type CategoryInput struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parentId"` // Empty string moves the category to the top level
+}
+
+// CategoryUpdate is the body used to file a video in a category
+type CategoryUpdate struct {
+	CategoryID string `json:"categoryId"` // Empty string removes the video from its category
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// handlePattern restricts channel handles to URL-safe lower-case names
+var handlePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{2,29}$`)
+
+// ValidHandle reports whether handle can be used as a channel handle
+func ValidHandle(handle string) bool {
+	return handlePattern.MatchString(handle)
+}
+
+// Channel is a page grouping the videos of a user or a team
+type Channel struct {
+	ID              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                              // MongoDB Object ID
+	Handle          string              `json:"handle" bson:"handle"`                                 // Unique lower-case name used in URLs
+	Name            string              `json:"name" bson:"name"`                                     // Display name
+	Description     string              `json:"description,omitempty" bson:"description,omitempty"`   // About text
+	OwnerID         string              `json:"ownerId" bson:"ownerId"`                               // Creating user
+	Team            string              `json:"team,omitempty" bson:"team,omitempty"`                 // Group whose members manage the channel
+	AvatarFileID    *primitive.ObjectID `json:"avatarFileId,omitempty" bson:"avatarFileId,omitempty"` // Avatar image in the images bucket
+	BannerFileID    *primitive.ObjectID `json:"bannerFileId,omitempty" bson:"bannerFileId,omitempty"` // Banner image in the images bucket
+	SubscriberCount int64               `json:"subscriberCount" bson:"subscriberCount"`               // Number of subscribed users
+	Live            *LiveSettings       `json:"-" bson:"live,omitempty"`                              // Live streaming settings, only shown to managers
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// ChannelInput is the body used to create or update a channel
+type ChannelInput struct {
+	Handle      string  `json:"handle"` // Only used on creation
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Team        *string `json:"team"` // Group managing the channel; empty for a personal channel
+}
+
+// ChannelPage is a channel with its latest uploads
+type ChannelPage struct {
+	Channel Channel        `json:"channel"`
+	Latest  []VideoSummary `json:"latest"` // Newest videos visible to the caller
+}
+
+// ChannelUpdate is the body used to publish a video on a channel
+type ChannelUpdate struct {
+	ChannelID string `json:"channelId"` // Empty string removes the video from its channel
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a comment on a video. Replies share the ThreadID of the top-level comment.
+type Comment struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                        // MongoDB Object ID
+	VideoID    primitive.ObjectID  `json:"videoId" bson:"videoId"`                         // Commented video
+	ThreadID   primitive.ObjectID  `json:"threadId" bson:"threadId"`                       // Top-level comment of the thread
+	ParentID   *primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`   // Comment replied to
+	AuthorID   string              `json:"authorId" bson:"authorId"`                       // Commenting user
+	AuthorName string              `json:"authorName" bson:"authorName"`                   // Author's display name at the time
+	Body       string              `json:"body" bson:"body"`                               // Comment text
+	Timestamp  *float64            `json:"timestamp,omitempty" bson:"timestamp,omitempty"` // Anchored position in the video, in seconds
+	Hidden     bool                `json:"hidden,omitempty" bson:"hidden"`                 // Hidden by a moderator
+	Pinned     bool                `json:"pinned,omitempty" bson:"pinned"`                 // Pinned to the top by the video owner
+	Deleted    bool                `json:"deleted,omitempty" bson:"deleted"`               // Removed; kept so replies stay threaded
+	History    []CommentRevision   `json:"history,omitempty" bson:"history,omitempty"`     // Earlier versions of the body
+	CreatedAt  time.Time           `json:"createdAt" bson:"createdAt"`
+	EditedAt   *time.Time          `json:"editedAt,omitempty" bson:"editedAt,omitempty"`
+}
+
+// CommentRevision is a previous version of an edited comment
+type CommentRevision struct {
+	Body     string    `json:"body" bson:"body"`
+	EditedAt time.Time `json:"editedAt" bson:"editedAt"`
+}
+
+// CommentThread is a top-level comment with its first replies
+type CommentThread struct {
+	Comment
+	Replies    []Comment `json:"replies"`
+	ReplyCount int64     `json:"replyCount"`
+}
+
+// CommentInput is the body used to post or edit a comment
+type CommentInput struct {
+	Body      string   `json:"body"`
+	ParentID  string   `json:"parentId"`
+	Timestamp *float64 `json:"timestamp"`
+}
+
+// CommentModeration is the body used by moderators to hide or pin a comment
+type CommentModeration struct {
+	Hidden *bool `json:"hidden"`
+	Pinned *bool `json:"pinned"`
+}
+
+// CommentSettings is the body used to turn comments on a video on or off
+type CommentSettings struct {
+	Disabled bool `json:"disabled"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Data export statuses
+const (
+	DataExportPending = "pending" // Waiting for a worker
+	DataExportRunning = "running" // Archive is being built
+	DataExportReady   = "ready"   // Archive can be downloaded until it expires
+	DataExportFailed  = "failed"  // Building the archive failed
+	DataExportExpired = "expired" // Archive was deleted after its retention
+)
+
+// DataExport is a job collecting everything the hub stores about a user into a ZIP archive
+type DataExport struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                // MongoDB Object ID
+	UserID      string              `json:"userId" bson:"userId"`                   // User whose data is exported
+	RequestedBy string              `json:"requestedBy" bson:"requestedBy"`         // The user or the admin acting for them
+	Status      string              `json:"status" bson:"status"`                   // pending, running, ready, failed or expired
+	Error       string              `json:"error,omitempty" bson:"error,omitempty"` // Failure reason
+	FileID      *primitive.ObjectID `json:"-" bson:"fileId,omitempty"`              // Archive in the exports bucket
+	Size        int64               `json:"size,omitempty" bson:"size,omitempty"`   // Archive size in bytes
+	CreatedAt   time.Time           `json:"createdAt" bson:"createdAt"`             // Request time
+	CompletedAt *time.Time          `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	ExpiresAt   *time.Time          `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"` // Archive and link are gone after this time
+	LeaseUntil  *time.Time          `json:"-" bson:"leaseUntil,omitempty"`                  // A running job whose lease passed is picked up again
+	LeaseToken  string              `json:"-" bson:"leaseToken,omitempty"`                  // Identifies the worker holding the lease
+	DownloadURL string              `json:"downloadUrl,omitempty" bson:"-"`                 // Signed link, set while the archive is ready
+}
+
+// DataExportManifest describes the content of an export archive
+type DataExportManifest struct {
+	UserID    string               `json:"userId"`
+	CreatedAt time.Time            `json:"createdAt"`
+	Counts    map[string]int       `json:"counts"` // Records per section
+	Files     []DataExportFileInfo `json:"files"`  // Every other file of the archive
+}
+
+// DataExportFileInfo is the checksum of one file in an export archive
+type DataExportFileInfo struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WatchEntry records how far a user got in a video
+type WatchEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`    // MongoDB Object ID
+	UserID    string             `json:"userId" bson:"userId"`       // Watching user
+	VideoID   primitive.ObjectID `json:"videoId" bson:"videoId"`     // Watched video
+	Position  float64            `json:"position" bson:"position"`   // Last reported playback position in seconds
+	Completed bool               `json:"completed" bson:"completed"` // Played to the end
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"` // Last heartbeat
+	ExpiresAt time.Time          `json:"-" bson:"expiresAt"`         // Entry is pruned after this time
+	Video     *VideoSummary      `json:"video,omitempty" bson:"-"`   // Watched video, filled in on read
+}
+
+// Heartbeat is the playback position reported by a player
+type Heartbeat struct {
+	Position float64 `json:"position"` // Playback position in seconds
+}
+
+// HistorySettings is the body used to pause or resume watch history
+type HistorySettings struct {
+	Paused bool `json:"paused"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ImportEntry describes a local file to import, as listed in an import manifest
+type ImportEntry struct {
+	Path        string   `json:"path"`                  // File path, relative to the manifest
+	Title       string   `json:"title,omitempty"`       // Defaults to the tagged title or the file name
+	Description string   `json:"description,omitempty"` // Video description
+	Tags        []string `json:"tags,omitempty"`        // Free-form tags
+	Visibility  string   `json:"visibility,omitempty"`  // public (default), unlisted or private
+}
+
+// Import journal statuses
+const (
+	ImportImported = "imported" // Uploaded as a new video
+	ImportSkipped  = "skipped"  // The owner already has a video with this content
+	ImportFailed   = "failed"   // Retried on the next run
+)
+
+// ImportRecord is one line of an import journal
+type ImportRecord struct {
+	Path    string    `json:"path"`
+	Status  string    `json:"status"`
+	VideoID string    `json:"videoId,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
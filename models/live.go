@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Live session status values
+const (
+	LiveStreaming = "live"   // The encoder is publishing
+	LiveSaving    = "saving" // The stream ended and its recording is being stored
+	LiveEnded     = "ended"  // The stream ended and its recording is a video
+	LiveFailed    = "failed" // The stream or its recording failed
+)
+
+// LiveSettings configures how a channel goes live
+type LiveSettings struct {
+	StreamKey     string   `json:"streamKey" bson:"streamKey"`                             // Secret key the encoder publishes with
+	Title         string   `json:"title,omitempty" bson:"title,omitempty"`                 // Title of streams and their recordings
+	Visibility    string   `json:"visibility" bson:"visibility"`                           // Who may watch, as for videos
+	AllowedUsers  []string `json:"allowedUsers,omitempty" bson:"allowedUsers,omitempty"`   // Viewers of restricted streams
+	AllowedGroups []string `json:"allowedGroups,omitempty" bson:"allowedGroups,omitempty"` // Viewer groups of restricted streams
+	IngestURL     string   `json:"ingestUrl" bson:"-"`                                     // RTMP server URL for the encoder
+}
+
+// LiveSettingsInput is the body used to change live settings
+type LiveSettingsInput struct {
+	Title         *string   `json:"title"`
+	Visibility    *string   `json:"visibility"`
+	AllowedUsers  *[]string `json:"allowedUsers"`
+	AllowedGroups *[]string `json:"allowedGroups"`
+	RotateKey     bool      `json:"rotateKey"` // Issue a new stream key; the old one stops working
+}
+
+// LiveSession is one broadcast of a channel
+type LiveSession struct {
+	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	ChannelID     primitive.ObjectID  `json:"channelId" bson:"channelId"`
+	OwnerID       string              `json:"ownerId" bson:"ownerId"` // Owner of the recording
+	Title         string              `json:"title" bson:"title"`
+	Visibility    string              `json:"visibility" bson:"visibility"`
+	AllowedUsers  []string            `json:"allowedUsers,omitempty" bson:"allowedUsers,omitempty"`
+	AllowedGroups []string            `json:"allowedGroups,omitempty" bson:"allowedGroups,omitempty"`
+	Status        string              `json:"status" bson:"status"` // live, saving, ended or failed
+	Error         string              `json:"error,omitempty" bson:"error,omitempty"`
+	StartedAt     time.Time           `json:"startedAt" bson:"startedAt"`
+	EndedAt       *time.Time          `json:"endedAt,omitempty" bson:"endedAt,omitempty"`
+	HeartbeatAt   time.Time           `json:"-" bson:"heartbeatAt"`                       // Refreshed by the serving process until the session is closed
+	VideoID       *primitive.ObjectID `json:"videoId,omitempty" bson:"videoId,omitempty"` // Recording
+}
+
+// LiveStatus tells whether a channel is live
+type LiveStatus struct {
+	Live     bool         `json:"live"`
+	Playlist string       `json:"playlist,omitempty"` // Live HLS playlist while streaming
+	Session  *LiveSession `json:"session,omitempty"`  // Running or most recent session
+}
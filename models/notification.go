@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification kinds
+const (
+	NotificationProcessingComplete = "processing_complete" // Every rendition of an upload was packaged
+	NotificationProcessingFailed   = "processing_failed"   // Packaging of an upload failed
+	NotificationComment            = "comment"             // Someone commented on a video or replied to a comment
+	NotificationSubscriber         = "subscriber"          // Someone subscribed to a channel
+	NotificationMention            = "mention"             // Someone mentioned the user in a comment
+	NotificationUpload             = "upload"              // A subscribed channel published a video
+	NotificationDataExport         = "data_export"         // A requested data export is ready to download
+)
+
+// Notification tells a user about something that happened
+type Notification struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                        // MongoDB Object ID
+	UserID    string              `json:"userId" bson:"userId"`                           // Recipient
+	Kind      string              `json:"kind" bson:"kind"`                               // One of the notification kinds
+	Message   string              `json:"message" bson:"message"`                         // Human-readable text
+	ActorID   string              `json:"actorId,omitempty" bson:"actorId,omitempty"`     // User who caused the notification
+	VideoID   *primitive.ObjectID `json:"videoId,omitempty" bson:"videoId,omitempty"`     // Related video
+	CommentID *primitive.ObjectID `json:"commentId,omitempty" bson:"commentId,omitempty"` // Related comment
+	ChannelID *primitive.ObjectID `json:"channelId,omitempty" bson:"channelId,omitempty"` // Related channel
+	Read      bool                `json:"read" bson:"read"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time           `json:"-" bson:"expiresAt"` // Removed by MongoDB after the retention period
+}
+
+// NotificationUpdate changes the read state of a notification
+type NotificationUpdate struct {
+	Read bool `json:"read"`
+}
+
+// UnreadCount reports the number of unread notifications
+type UnreadCount struct {
+	Unread int64 `json:"unread"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Playlist is an ordered list of videos owned by a user
+type Playlist struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`                                // MongoDB Object ID
+	OwnerID       string             `json:"ownerId" bson:"ownerId"`                                 // Owning user
+	Title         string             `json:"title" bson:"title"`                                     // Playlist title
+	Description   string             `json:"description" bson:"description"`                         // Playlist description
+	Visibility    string             `json:"visibility" bson:"visibility"`                           // public, unlisted or private
+	Collaborators []string           `json:"collaborators,omitempty" bson:"collaborators,omitempty"` // Users allowed to edit the items
+	Items         []PlaylistItem     `json:"items" bson:"items"`                                     // Items ordered by position
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PlaylistItem places a video in a playlist. Items sort by Position, a
+// fractional index, so moving one item only rewrites that item.
+type PlaylistItem struct {
+	VideoID  primitive.ObjectID `json:"videoId" bson:"videoId"`
+	Position float64            `json:"position" bson:"position"`
+	AddedBy  string             `json:"addedBy" bson:"addedBy"`
+	AddedAt  time.Time          `json:"addedAt" bson:"addedAt"`
+	Video    *VideoSummary      `json:"video,omitempty" bson:"-"` // Embedded in responses only
+}
+
+// VideoSummary is the short form of a video embedded in other resources
+type VideoSummary struct {
+	ID         primitive.ObjectID `json:"id"`
+	Title      string             `json:"title"`
+	OwnerID    string             `json:"ownerId,omitempty"`
+	Duration   float64            `json:"duration,omitempty"`
+	UploadDate string             `json:"uploadDate"`
+}
+
+// PlaylistInput is the body used to create or update a playlist
+type PlaylistInput struct {
+	Title         *string   `json:"title"`
+	Description   *string   `json:"description"`
+	Visibility    *string   `json:"visibility"`
+	Collaborators *[]string `json:"collaborators"`
+}
+
+// PlaylistItemInput adds or moves a playlist item. Index is the zero-based
+// target slot; Position sets the fractional index directly.
+type PlaylistItemInput struct {
+	VideoID  string   `json:"videoId"`
+	Index    *int     `json:"index"`
+	Position *float64 `json:"position"`
+}
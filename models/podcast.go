@@ -0,0 +1,66 @@
+package models
+
+import "encoding/xml"
+
+// ITunesNamespace is the namespace of the iTunes podcast extensions
+const ITunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// RSS is an RSS 2.0 document with iTunes podcast extensions
+type RSS struct {
+	XMLName xml.Name       `xml:"rss"`
+	Version string         `xml:"version,attr"`
+	ITunes  string         `xml:"xmlns:itunes,attr"`
+	Channel PodcastChannel `xml:"channel"`
+}
+
+// PodcastChannel describes the show of a podcast feed
+type PodcastChannel struct {
+	Title          string          `xml:"title"`
+	Link           string          `xml:"link"`
+	Description    string          `xml:"description"`
+	LastBuildDate  string          `xml:"lastBuildDate,omitempty"`
+	Generator      string          `xml:"generator"`
+	ITunesAuthor   string          `xml:"itunes:author,omitempty"`
+	ITunesSummary  string          `xml:"itunes:summary,omitempty"`
+	ITunesType     string          `xml:"itunes:type"`
+	ITunesExplicit string          `xml:"itunes:explicit"`
+	ITunesImage    *ITunesImage    `xml:"itunes:image,omitempty"`
+	ITunesCategory *ITunesCategory `xml:"itunes:category,omitempty"`
+	Items          []PodcastItem   `xml:"item"`
+}
+
+// PodcastItem is one episode of a podcast feed
+type PodcastItem struct {
+	Title          string       `xml:"title"`
+	Link           string       `xml:"link"`
+	Description    string       `xml:"description"`
+	GUID           PodcastGUID  `xml:"guid"`
+	PubDate        string       `xml:"pubDate"`
+	Enclosure      Enclosure    `xml:"enclosure"`
+	ITunesDuration string       `xml:"itunes:duration,omitempty"`
+	ITunesEpisode  int          `xml:"itunes:episode,omitempty"`
+	ITunesImage    *ITunesImage `xml:"itunes:image,omitempty"`
+}
+
+// PodcastGUID identifies an episode across feed refreshes
+type PodcastGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Enclosure points at the media file of an episode
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// ITunesImage is the artwork of a show or episode
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// ITunesCategory is the category a show is listed under
+type ITunesCategory struct {
+	Text string `xml:"text,attr"`
+}
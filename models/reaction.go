@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reaction kinds; a user has at most one reaction per video
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+	ReactionLove    = "love"
+	ReactionLaugh   = "laugh"
+	ReactionWow     = "wow"
+	ReactionSad     = "sad"
+)
+
+// ValidReaction reports whether kind is a known reaction
+func ValidReaction(kind string) bool {
+	switch kind {
+	case ReactionLike, ReactionDislike, ReactionLove, ReactionLaugh, ReactionWow, ReactionSad:
+		return true
+	}
+	return false
+}
+
+// Reaction is one user's reaction to a video
+type Reaction struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"` // MongoDB Object ID
+	VideoID   primitive.ObjectID `json:"videoId" bson:"videoId"`  // Video reacted to
+	UserID    string             `json:"userId" bson:"userId"`    // Reacting user
+	Kind      string             `json:"kind" bson:"kind"`        // like, dislike or an emoji reaction
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ReactionInput is the body used to react to a video
+type ReactionInput struct {
+	Kind string `json:"kind"`
+}
+
+// ReactionSummary is the aggregated engagement of a video
+type ReactionSummary struct {
+	Views     int64            `json:"views"`          // De-duplicated view count
+	Reactions map[string]int64 `json:"reactions"`      // Number of reactions per kind
+	Mine      string           `json:"mine,omitempty"` // Caller's reaction, if any
+}
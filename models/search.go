@@ -0,0 +1,14 @@
+package models
+
+// SearchResult is a video matching a search query
+type SearchResult struct {
+	Video      VideoSummary `json:"video"`      // Matching video
+	Score      float64      `json:"score"`      // Relevance, higher is better
+	Highlights []Highlight  `json:"highlights"` // Snippets of the matching fields
+}
+
+// Highlight is a snippet of a matching field with the matched words wrapped in <mark>
+type Highlight struct {
+	Field   string `json:"field"`   // title, description, tags or captions
+	Snippet string `json:"snippet"` // HTML-escaped text around the match
+}
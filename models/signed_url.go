@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SignedURLRequest describes the playback URL to mint
+type SignedURLRequest struct {
+	TTLSeconds int    `json:"ttlSeconds"` // Lifetime of the URL, defaults to one hour
+	IPRange    string `json:"ipRange"`    // Optional CIDR the client must connect from
+	ByteRange  string `json:"byteRange"`  // Optional "start-end" byte range the URL is limited to
+}
+
+// SignedURL is a time-limited playback URL that needs no auth headers
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
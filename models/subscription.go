@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification preferences of a subscription
+const (
+	NotifyAll  = "all"  // Notify about every new upload
+	NotifyNone = "none" // Only show uploads in the feed
+)
+
+// ValidNotify reports whether n is a known notification preference
+func ValidNotify(n string) bool {
+	return n == NotifyAll || n == NotifyNone
+}
+
+// Subscription records that a user follows a channel
+type Subscription struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`    // MongoDB Object ID
+	UserID    string             `json:"userId" bson:"userId"`       // Subscribed user
+	ChannelID primitive.ObjectID `json:"channelId" bson:"channelId"` // Followed channel
+	Notify    string             `json:"notify" bson:"notify"`       // all or none
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	Channel   *Channel           `json:"channel,omitempty" bson:"-"` // Followed channel, filled in on read
+}
+
+// SubscriptionInput is the body used to subscribe or change notification preferences
+type SubscriptionInput struct {
+	Notify string `json:"notify"` // all (default) or none
+}
+
+// Timeline is the cached feed of a user with many subscriptions
+type Timeline struct {
+	UserID    string               `bson:"_id"`       // Owner of the feed
+	VideoIDs  []primitive.ObjectID `bson:"videoIds"`  // Feed videos, newest first
+	ExpiresAt time.Time            `bson:"expiresAt"` // Rebuilt after this time
+}
+
+// FeedPage is a page of the subscription feed
+type FeedPage struct {
+	Videos     []Video `json:"videos"`
+	NextCursor string  `json:"nextCursor,omitempty"` // Pass as cursor to get the next page; empty on the last page
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTags is the number of tags a video may carry
+	MaxTags = 30
+
+	// MaxTagLength is the longest accepted tag in characters
+	MaxTagLength = 50
+)
+
+// NormalizeTag lower-cases a tag and joins its words with hyphens, so that
+// "Live Music", "#live-music" and "live_music" become the same tag. It returns
+// an empty string when nothing usable is left.
+func NormalizeTag(tag string) string {
+	words := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// NormalizeTags normalizes a list of tags, dropping empty ones and duplicates
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("a video can have at most %d tags", MaxTags)
+	}
+	return normalized, nil
+}
+
+// TagCount is a tag and the number of visible videos carrying it
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+// TagsUpdate is the body used to replace the tags of a video
+type TagsUpdate struct {
+	Tags []string `json:"tags"`
+}
+
+// Retag is the body of a bulk tag change. Videos are selected by ID or by a tag they carry.
+type Retag struct {
+	VideoIDs []string `json:"videoIds"` // Videos to change
+	Tag      string   `json:"tag"`      // Alternatively, every video carrying this tag
+	Add      []string `json:"add"`      // Tags to add
+	Remove   []string `json:"remove"`   // Tags to remove
+}
+
+// RetagResult reports the outcome of a bulk tag change
+type RetagResult struct {
+	Matched  int64 `json:"matched"`  // Videos selected that the caller may change
+	Modified int64 `json:"modified"` // Videos whose tags changed
+}
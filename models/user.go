@@ -2,10 +2,39 @@ package models
 
 import "time"
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        string    `json:"id" bson:"_id,omitempty"`
-	Name      string    `json:"name" bson:"name"`
-	Username  string    `json:"username" bson:"username"`
-	Password  string    `json:"password" bson:"password"`
-	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ID            string    `json:"id" bson:"_id,omitempty"`
+	Name          string    `json:"name" bson:"name"`
+	Username      string    `json:"username" bson:"username"`
+	Password      string    `json:"password" bson:"password"`
+	Role          string    `json:"role" bson:"role"`
+	Groups        []string  `json:"groups,omitempty" bson:"groups,omitempty"`               // Groups used by restricted video visibility
+	QuotaBytes    *int64    `json:"quotaBytes,omitempty" bson:"quotaBytes,omitempty"`       // Admin override of the role quota, 0 means unlimited
+	UsedBytes     int64     `json:"usedBytes" bson:"usedBytes"`                             // Storage used by the user's uploads
+	HistoryPaused bool      `json:"historyPaused,omitempty" bson:"historyPaused,omitempty"` // Watch history is not recorded
+	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Usage reports a user's storage consumption against their quota
+type Usage struct {
+	UsedBytes      int64 `json:"usedBytes"`      // Bytes used by the user's uploads
+	QuotaBytes     int64 `json:"quotaBytes"`     // Effective quota, 0 means unlimited
+	VideoCount     int64 `json:"videoCount"`     // Number of videos owned by the user
+	MaxUploadBytes int64 `json:"maxUploadBytes"` // Largest accepted single upload
+}
+
+// QuotaUpdate is the body of an admin quota override
+type QuotaUpdate struct {
+	QuotaBytes *int64 `json:"quotaBytes"` // New override, null to fall back to the role quota
+}
+
+// GroupsUpdate is the body of an admin group membership change
+type GroupsUpdate struct {
+	Groups []string `json:"groups"`
 }
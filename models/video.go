@@ -1,12 +1,87 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // Video represents a video file in the database
 type Video struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"` // MongoDB Object ID
-	Title       string             `json:"title" bson:"title"`      // Video title
-	Description string             `json:"description" bson:"description"` // Video description
-	FileName    string             `json:"fileName" bson:"fileName"` // File name in GridFS
-	UploadDate  string             `json:"uploadDate" bson:"uploadDate"` // Upload date
+	ID               primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                                      // MongoDB Object ID
+	Title            string              `json:"title" bson:"title"`                                           // Video title
+	Description      string              `json:"description" bson:"description"`                               // Video description
+	FileName         string              `json:"fileName" bson:"fileName"`                                     // File name in GridFS
+	MediaType        string              `json:"mediaType,omitempty" bson:"mediaType,omitempty"`               // video (default) or audio
+	Audio            *AudioInfo          `json:"audio,omitempty" bson:"audio,omitempty"`                       // Tags of an audio track
+	FileID           primitive.ObjectID  `json:"fileId" bson:"fileId"`                                         // Source file ID in GridFS
+	Size             int64               `json:"size" bson:"size"`                                             // Size of the uploaded file in bytes
+	OwnerID          string              `json:"ownerId,omitempty" bson:"ownerId,omitempty"`                   // Uploading user
+	Visibility       string              `json:"visibility" bson:"visibility,omitempty"`                       // public, unlisted, private or restricted
+	AllowedUsers     []string            `json:"allowedUsers,omitempty" bson:"allowedUsers,omitempty"`         // Users who may view a restricted video
+	AllowedGroups    []string            `json:"allowedGroups,omitempty" bson:"allowedGroups,omitempty"`       // Groups who may view a restricted video
+	CommentsDisabled bool                `json:"commentsDisabled,omitempty" bson:"commentsDisabled,omitempty"` // Owner turned comments off
+	ChannelID        *primitive.ObjectID `json:"channelId,omitempty" bson:"channelId,omitempty"`               // Channel the video is published on
+	Tags             []string            `json:"tags,omitempty" bson:"tags,omitempty"`                         // Normalized free-form tags
+	CategoryID       *primitive.ObjectID `json:"categoryId,omitempty" bson:"categoryId,omitempty"`             // Category in the taxonomy
+	Language         string              `json:"language,omitempty" bson:"language,omitempty"`                 // Spoken language, used for search stemming
+	CaptionText      string              `json:"-" bson:"captionText,omitempty"`                               // Text of all caption tracks, for search
+	UploadDate       string              `json:"uploadDate" bson:"uploadDate"`                                 // Upload date
+	Duration         float64             `json:"duration,omitempty" bson:"duration,omitempty"`                 // Probed duration in seconds
+	Chapters         []Chapter           `json:"chapters,omitempty" bson:"chapters,omitempty"`                 // Chapter markers ordered by start
+	Renditions       []Rendition         `json:"renditions,omitempty" bson:"renditions,omitempty"`             // Packaged streaming renditions
+	Views            int64               `json:"views" bson:"views,omitempty"`                                 // De-duplicated view count
+	Reactions        map[string]int64    `json:"reactions,omitempty" bson:"reactions,omitempty"`               // Number of reactions per kind
+	ResumeAt         *float64            `json:"resumeAt,omitempty" bson:"-"`                                  // Caller's saved playback position
+}
+
+// Visibility levels; videos without a level are public
+const (
+	VisibilityPublic     = "public"     // Listed and viewable by anyone
+	VisibilityUnlisted   = "unlisted"   // Viewable by anyone with the link, not listed
+	VisibilityPrivate    = "private"    // Viewable by the owner and admins only
+	VisibilityRestricted = "restricted" // Viewable by the named users and groups
+)
+
+// ValidVisibility reports whether v is a known visibility level
+func ValidVisibility(v string) bool {
+	switch v {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate, VisibilityRestricted:
+		return true
+	}
+	return false
+}
+
+// VisibilityUpdate is the body of a visibility change
+type VisibilityUpdate struct {
+	Visibility    string   `json:"visibility"`
+	AllowedUsers  []string `json:"allowedUsers"`
+	AllowedGroups []string `json:"allowedGroups"`
+}
+
+// Rendition status values
+const (
+	RenditionPending    = "pending"
+	RenditionProcessing = "processing"
+	RenditionReady      = "ready"
+	RenditionFailed     = "failed"
+)
+
+// Rendition represents one bitrate of a packaged video
+type Rendition struct {
+	Name         string    `json:"name" bson:"name"`                         // Rendition name, e.g. "720p"
+	Width        int       `json:"width" bson:"width"`                       // Output width in pixels
+	Height       int       `json:"height" bson:"height"`                     // Output height in pixels
+	Bandwidth    int       `json:"bandwidth" bson:"bandwidth"`               // Peak bandwidth in bit/s
+	Status       string    `json:"status" bson:"status"`                     // pending, processing, ready or failed
+	Error        string    `json:"error,omitempty" bson:"error,omitempty"`   // Failure reason
+	SegmentCount int       `json:"segmentCount" bson:"segmentCount"`         // Number of stored media segments
+	Codecs       string    `json:"codecs,omitempty" bson:"codecs,omitempty"` // RFC 6381 codecs read from the init segment
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`               // Last status change
+}
+
+// Chapter marks the start of a named section of a video
+type Chapter struct {
+	Start float64 `json:"start" bson:"start"` // Start offset in seconds
+	Title string  `json:"title" bson:"title"` // Chapter title
 }
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook events
+const (
+	EventVideoUploaded  = "video.uploaded"  // A video was uploaded
+	EventVideoProcessed = "video.processed" // Packaging of a video finished
+	EventVideoDeleted   = "video.deleted"   // A video was deleted
+	EventUserCreated    = "user.created"    // A user account was created
+	EventPing           = "ping"            // Test event sent on request
+	EventAll            = "*"               // Subscribes a webhook to every event
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []string{EventVideoUploaded, EventVideoProcessed, EventVideoDeleted, EventUserCreated}
+
+// ValidWebhookEvent reports whether a webhook can subscribe to the event
+func ValidWebhookEvent(event string) bool {
+	if event == EventAll {
+		return true
+	}
+	for _, known := range WebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery status values
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook posts signed events to an external URL
+type Webhook struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`        // MongoDB Object ID
+	URL         string             `json:"url" bson:"url"`                 // Receiver, http or https
+	Events      []string           `json:"events" bson:"events"`           // Subscribed events, or "*"
+	Secret      string             `json:"secret,omitempty" bson:"secret"` // HMAC key; only returned on creation
+	Active      bool               `json:"active" bson:"active"`           // Inactive webhooks receive nothing
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedBy   string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Wants reports whether the webhook subscribes to an event
+func (h Webhook) Wants(event string) bool {
+	for _, e := range h.Events {
+		if e == event || e == EventAll {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookInput is the body used to create or update a webhook
+type WebhookInput struct {
+	URL          *string   `json:"url"`
+	Events       *[]string `json:"events"`
+	Active       *bool     `json:"active"`
+	Description  *string   `json:"description"`
+	RotateSecret bool      `json:"rotateSecret"` // Issue a new secret on update
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"` // Also sent as X-Hub-Delivery
+	WebhookID      primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"` // JSON body
+	Status         string             `json:"status" bson:"status"`   // pending, succeeded or failed
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"` // HTTP status of the last attempt
+	ResponseBody   string             `json:"responseBody,omitempty" bson:"responseBody,omitempty"`     // Start of the last response body
+	Error          string             `json:"error,omitempty" bson:"error,omitempty"`                   // Failure of the last attempt
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastAttemptAt  *time.Time         `json:"lastAttemptAt,omitempty" bson:"lastAttemptAt,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt      time.Time          `json:"-" bson:"expiresAt"` // Removed from the log after the retention period
+}
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	ID        string      `json:"id"` // Delivery ID
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// VideoEventData describes the video of a video event
+type VideoEventData struct {
+	ID         primitive.ObjectID  `json:"id"`
+	Title      string              `json:"title"`
+	OwnerID    string              `json:"ownerId"`
+	ChannelID  *primitive.ObjectID `json:"channelId,omitempty"`
+	Visibility string              `json:"visibility"`
+	Size       int64               `json:"size"`
+	UploadDate string              `json:"uploadDate"`
+	Ready      int                 `json:"ready,omitempty"`  // Renditions packaged, for video.processed
+	Failed     int                 `json:"failed,omitempty"` // Renditions that failed, for video.processed
+}
+
+// UserEventData describes the user of a user event
+type UserEventData struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
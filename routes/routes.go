@@ -9,14 +9,145 @@ import (
 
 func RegisterRoutes(router *mux.Router) {
 	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(controller.RequestID)
 
 	// Define all routes here
 	api.HandleFunc("/users", controller.GetUsers).Methods(http.MethodGet)
 	api.HandleFunc("/users", controller.CreateUser).Methods(http.MethodPost)
+	api.HandleFunc("/users/me/usage", controller.GetMyUsage).Methods(http.MethodGet)
+	api.HandleFunc("/users/me/history", controller.GetMyHistory).Methods(http.MethodGet)
+	api.HandleFunc("/users/me/history", controller.ClearMyHistory).Methods(http.MethodDelete)
+	api.HandleFunc("/users/me/history/settings", controller.SetHistorySettings).Methods(http.MethodPut)
+	api.HandleFunc("/users/me/history/{videoId}", controller.DeleteHistoryEntry).Methods(http.MethodDelete)
+	api.HandleFunc("/users/me/continue-watching", controller.GetContinueWatching).Methods(http.MethodGet)
+	api.HandleFunc("/users/me/subscriptions", controller.GetMySubscriptions).Methods(http.MethodGet)
+	api.HandleFunc("/users/me/exports", controller.ListDataExports).Methods(http.MethodGet)
+	api.HandleFunc("/users/me/exports", controller.RequestDataExport).Methods(http.MethodPost)
+	api.HandleFunc("/users/{id}/quota", controller.SetUserQuota).Methods(http.MethodPut)
+	api.HandleFunc("/users/{id}/usage/recalculate", controller.RecalculateUsage).Methods(http.MethodPost)
+	api.HandleFunc("/users/{id}/groups", controller.SetUserGroups).Methods(http.MethodPut)
+	api.HandleFunc("/users/{id}/exports", controller.RequestUserDataExport).Methods(http.MethodPost)
+	api.HandleFunc("/exports/{id}/download", controller.DownloadDataExport).Methods(http.MethodGet)
 	api.HandleFunc("/login", controller.LoginHandler).Methods(http.MethodPost)
 	api.HandleFunc("/upload", controller.UploadVideo).Methods(http.MethodPost)
 	api.HandleFunc("/video/first", controller.GetFirstVideo).Methods(http.MethodGet)
 
 	// Video streaming route
-	api.HandleFunc("/video", controller.GetVideo).Methods("GET")
+	api.HandleFunc("/video/{id}", controller.GetVideo).Methods("GET")
+
+	// Catalog routes
+	api.HandleFunc("/videos", controller.ListVideos).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}", controller.GetVideoDetails).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}", controller.DeleteVideo).Methods(http.MethodDelete)
+	api.HandleFunc("/videos/{id}/visibility", controller.SetVideoVisibility).Methods(http.MethodPut)
+	api.HandleFunc("/videos/{id}/signed-url", controller.CreateSignedURL).Methods(http.MethodPost)
+
+	// Playlist routes
+	api.HandleFunc("/playlists", controller.CreatePlaylist).Methods(http.MethodPost)
+	api.HandleFunc("/playlists/{id}", controller.GetPlaylist).Methods(http.MethodGet)
+	api.HandleFunc("/playlists/{id}", controller.UpdatePlaylist).Methods(http.MethodPut)
+	api.HandleFunc("/playlists/{id}", controller.DeletePlaylist).Methods(http.MethodDelete)
+	api.HandleFunc("/playlists/{id}/feed.xml", controller.GetPlaylistPodcast).Methods(http.MethodGet)
+	api.HandleFunc("/playlists/{id}/items", controller.AddPlaylistItem).Methods(http.MethodPost)
+	api.HandleFunc("/playlists/{id}/items/{videoId}", controller.MovePlaylistItem).Methods(http.MethodPut)
+	api.HandleFunc("/playlists/{id}/items/{videoId}", controller.RemovePlaylistItem).Methods(http.MethodDelete)
+
+	// Comment routes
+	api.HandleFunc("/videos/{id}/comments", controller.ListComments).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/comments", controller.CreateComment).Methods(http.MethodPost)
+	api.HandleFunc("/videos/{id}/comments/settings", controller.SetCommentSettings).Methods(http.MethodPut)
+	api.HandleFunc("/comments/{id}", controller.UpdateComment).Methods(http.MethodPut)
+	api.HandleFunc("/comments/{id}", controller.DeleteComment).Methods(http.MethodDelete)
+	api.HandleFunc("/comments/{id}/replies", controller.ListReplies).Methods(http.MethodGet)
+	api.HandleFunc("/comments/{id}/moderation", controller.ModerateComment).Methods(http.MethodPut)
+
+	// Channel routes
+	api.HandleFunc("/channels", controller.ListChannels).Methods(http.MethodGet)
+	api.HandleFunc("/channels", controller.CreateChannel).Methods(http.MethodPost)
+	api.HandleFunc("/channels/{handle}", controller.GetChannel).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}", controller.UpdateChannel).Methods(http.MethodPut)
+	api.HandleFunc("/channels/{handle}", controller.DeleteChannel).Methods(http.MethodDelete)
+	api.HandleFunc("/channels/{handle}/videos", controller.ListChannelVideos).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/feed.xml", controller.GetChannelPodcast).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/subscription", controller.Subscribe).Methods(http.MethodPut)
+	api.HandleFunc("/channels/{handle}/subscription", controller.Unsubscribe).Methods(http.MethodDelete)
+	api.HandleFunc("/channels/{handle}/live", controller.GetLiveStatus).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/live/settings", controller.GetLiveSettings).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/live/settings", controller.UpdateLiveSettings).Methods(http.MethodPut)
+	api.HandleFunc("/channels/{handle}/live/{file}", controller.GetLiveFile).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/{kind:avatar|banner}", controller.GetChannelImage).Methods(http.MethodGet)
+	api.HandleFunc("/channels/{handle}/{kind:avatar|banner}", controller.UploadChannelImage).Methods(http.MethodPut)
+	api.HandleFunc("/videos/{id}/channel", controller.SetVideoChannel).Methods(http.MethodPut)
+	api.HandleFunc("/feed", controller.GetFeed).Methods(http.MethodGet)
+
+	// Notification routes
+	api.HandleFunc("/notifications", controller.ListNotifications).Methods(http.MethodGet)
+	api.HandleFunc("/notifications/unread-count", controller.GetUnreadCount).Methods(http.MethodGet)
+	api.HandleFunc("/notifications/stream", controller.StreamNotifications).Methods(http.MethodGet)
+	api.HandleFunc("/notifications/read", controller.MarkAllNotificationsRead).Methods(http.MethodPost)
+	api.HandleFunc("/notifications/{id}", controller.UpdateNotification).Methods(http.MethodPut)
+	api.HandleFunc("/notifications/{id}", controller.DeleteNotification).Methods(http.MethodDelete)
+
+	// Webhook routes
+	api.HandleFunc("/webhooks", controller.ListWebhooks).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks", controller.CreateWebhook).Methods(http.MethodPost)
+	api.HandleFunc("/webhooks/{id}", controller.UpdateWebhook).Methods(http.MethodPut)
+	api.HandleFunc("/webhooks/{id}", controller.DeleteWebhook).Methods(http.MethodDelete)
+	api.HandleFunc("/webhooks/{id}/deliveries", controller.ListWebhookDeliveries).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/{id}/test", controller.TestWebhook).Methods(http.MethodPost)
+
+	// Backup
+	api.HandleFunc("/export", controller.ExportArchive).Methods(http.MethodGet)
+
+	// Audit log
+	api.HandleFunc("/audit", controller.ListAuditEntries).Methods(http.MethodGet)
+	api.HandleFunc("/audit/verify", controller.VerifyAuditLog).Methods(http.MethodGet)
+
+	// Search
+	api.HandleFunc("/search", controller.SearchVideos).Methods(http.MethodGet)
+
+	// Tag and category routes
+	api.HandleFunc("/tags", controller.ListTags).Methods(http.MethodGet)
+	api.HandleFunc("/videos/retag", controller.RetagVideos).Methods(http.MethodPost)
+	api.HandleFunc("/videos/{id}/tags", controller.SetVideoTags).Methods(http.MethodPut)
+	api.HandleFunc("/videos/{id}/category", controller.SetVideoCategory).Methods(http.MethodPut)
+	api.HandleFunc("/categories", controller.ListCategories).Methods(http.MethodGet)
+	api.HandleFunc("/categories", controller.CreateCategory).Methods(http.MethodPost)
+	api.HandleFunc("/categories/{id}", controller.UpdateCategory).Methods(http.MethodPut)
+	api.HandleFunc("/categories/{id}", controller.DeleteCategory).Methods(http.MethodDelete)
+
+	// Audio library
+	api.HandleFunc("/videos/{id}/artwork", controller.GetArtwork).Methods(http.MethodGet)
+	api.HandleFunc("/audio/artists", controller.ListArtists).Methods(http.MethodGet)
+	api.HandleFunc("/audio/albums", controller.ListAlbums).Methods(http.MethodGet)
+	api.HandleFunc("/audio/tracks", controller.ListTracks).Methods(http.MethodGet)
+
+	// Playback position heartbeat
+	api.HandleFunc("/videos/{id}/heartbeat", controller.RecordHeartbeat).Methods(http.MethodPost)
+
+	// Reaction routes
+	api.HandleFunc("/videos/{id}/reactions", controller.GetReactions).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/reaction", controller.SetReaction).Methods(http.MethodPut)
+	api.HandleFunc("/videos/{id}/reaction", controller.DeleteReaction).Methods(http.MethodDelete)
+
+	// Caption routes
+	api.HandleFunc("/videos/{id}/captions", controller.UploadCaption).Methods(http.MethodPost)
+	api.HandleFunc("/videos/{id}/captions", controller.ListCaptions).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/captions/{captionId}", controller.GetCaption).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/captions/{captionId}", controller.DeleteCaption).Methods(http.MethodDelete)
+
+	// Chapter routes
+	api.HandleFunc("/videos/{id}/chapters", controller.GetChapters).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/chapters", controller.UpdateChapters).Methods(http.MethodPut)
+	api.HandleFunc("/videos/{id}/chapters/import", controller.ImportChapters).Methods(http.MethodPost)
+	api.HandleFunc("/videos/{id}/chapters.vtt", controller.GetChaptersVTT).Methods(http.MethodGet)
+
+	// Adaptive streaming routes
+	api.HandleFunc("/videos/{id}/hls", controller.PackageHLS).Methods(http.MethodPost)
+	api.HandleFunc("/videos/{id}/hls", controller.GetHLSStatus).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/hls/master.m3u8", controller.GetHLSMaster).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/hls/captions/{captionId}.m3u8", controller.GetSubtitlePlaylist).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/hls/{rendition}/{file}", controller.GetHLSFile).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/dash/manifest.mpd", controller.GetDASHManifest).Methods(http.MethodGet)
+	api.HandleFunc("/videos/{id}/dash/{rendition}/{file}", controller.GetDASHFile).Methods(http.MethodGet)
 }
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// span is the byte range of a word in a text
+type span struct {
+	start, end int
+	match      bool
+}
+
+// Highlight returns an HTML snippet of about width characters around the first
+// word of text matching the query, with every matching word wrapped in <mark>.
+// It reports false when no word matches. Words are matched by their English stem
+// whatever the language of the text.
+func Highlight(text, query string, width int) (string, bool) {
+	wanted := map[string]bool{}
+	for _, term := range Tokenize(query) {
+		wanted[term] = true
+	}
+
+	spans := wordSpans(text)
+	first := -1
+	for i := range spans {
+		word := strings.ToLower(text[spans[i].start:spans[i].end])
+		if wanted[Stem(word)] {
+			spans[i].match = true
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	// Keep some context before the first match and fill the rest after it
+	from, to := first, first
+	for from > 0 && utf8.RuneCountInString(text[spans[from-1].start:spans[first].end]) <= width/3 {
+		from--
+	}
+	for to+1 < len(spans) && utf8.RuneCountInString(text[spans[from].start:spans[to+1].end]) <= width {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	position := spans[from].start
+	for _, s := range spans[from : to+1] {
+		b.WriteString(html.EscapeString(text[position:s.start]))
+		word := html.EscapeString(text[s.start:s.end])
+		if s.match {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+		position = s.end
+	}
+	if to < len(spans)-1 {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// wordSpans finds the words of letters and digits in text
+func wordSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			spans = append(spans, span{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start: start, end: len(text)})
+	}
+	return spans
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Field is a piece of document text and the weight of its matches
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Hit is a document matching a query
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is an in-memory inverted index used where the database offers no
+// text index. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]float64 // Weighted term frequency per term and document
+	terms    map[string][]string           // Terms of each document, for removal
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[string]float64{},
+		terms:    map[string][]string{},
+	}
+}
+
+// Add indexes a document, replacing any earlier version of it
+func (idx *Index) Add(id string, fields ...Field) {
+	weights := map[string]float64{}
+	for _, field := range fields {
+		for _, term := range Tokenize(field.Text) {
+			weights[term] += field.Weight
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[string]float64{}
+		}
+		idx.postings[term][id] = weight
+		terms = append(terms, term)
+	}
+	idx.terms[id] = terms
+}
+
+// Remove drops a document from the index
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id string) {
+	for _, term := range idx.terms[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, id)
+}
+
+// Search returns the documents matching any term of the query, best first.
+// Scores are the weighted term frequencies scaled by inverse document frequency.
+func (idx *Index) Search(query string) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	total := float64(len(idx.terms))
+	scores := map[string]float64{}
+	for _, term := range Tokenize(query) {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(postings)))
+		for id, weight := range postings {
+			scores[id] += weight * idf
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+	return hits
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languages are the language names and ISO codes supported by the MongoDB text index
+var languages = map[string]bool{
+	"da": true, "danish": true,
+	"de": true, "german": true,
+	"en": true, "english": true,
+	"es": true, "spanish": true,
+	"fi": true, "finnish": true,
+	"fr": true, "french": true,
+	"hu": true, "hungarian": true,
+	"it": true, "italian": true,
+	"nb": true, "norwegian": true,
+	"nl": true, "dutch": true,
+	"pt": true, "portuguese": true,
+	"ro": true, "romanian": true,
+	"ru": true, "russian": true,
+	"sv": true, "swedish": true,
+	"tr": true, "turkish": true,
+	"none": true,
+}
+
+// SupportedLanguage reports whether lang can be used for text index stemming
+func SupportedLanguage(lang string) bool {
+	return languages[lang]
+}
+
+// FallbackLanguage reports whether the in-memory fallback can search in lang.
+// Tokenize and Stem only know English.
+func FallbackLanguage(lang string) bool {
+	return lang == "en" || lang == "english"
+}
+
+// stopWords are English words too common to be worth indexing
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"with": true,
+}
+
+// Tokenize splits text into lower-case stemmed terms, dropping English stop words.
+// Text in other languages is split the same way but stemmed as if it were English.
+func Tokenize(text string) []string {
+	var terms []string
+	for _, word := range words(text) {
+		if stopWords[word] {
+			continue
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+// words splits text into lower-case words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Stem reduces an English word to an approximate stem by stripping common
+// suffixes, so that "stories" and "story" or "games" and "game" meet. It is
+// deliberately light: the MongoDB text index does proper per-language
+// stemming, this only serves the in-memory fallback.
+func Stem(word string) string {
+	if len(word) <= 3 || strings.HasSuffix(word, "ss") {
+		return word
+	}
+	for _, rule := range []struct{ suffix, replacement string }{
+		{"ational", "ate"},
+		{"ization", "ize"},
+		{"fulness", "ful"},
+		{"iveness", "ive"},
+		{"ing", ""},
+		{"ies", "i"},
+		{"ied", "i"},
+		{"ed", ""},
+		{"ly", ""},
+		{"es", ""},
+		{"s", ""},
+	} {
+		if stem, ok := strings.CutSuffix(word, rule.suffix); ok && len(stem) >= 3 {
+			word = stem + rule.replacement
+			break
+		}
+	}
+	if stem, ok := strings.CutSuffix(word, "y"); ok && len(stem) >= 3 {
+		return stem + "i"
+	}
+	if stem, ok := strings.CutSuffix(word, "e"); ok && len(stem) >= 3 {
+		return stem
+	}
+	return word
+}
@@ -0,0 +1,134 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verify
+var (
+	ErrMissing    = errors.New("signature missing")
+	ErrMalformed  = errors.New("signed URL is malformed")
+	ErrUnknownKey = errors.New("signing key is unknown")
+	ErrSignature  = errors.New("signature does not match")
+	ErrExpired    = errors.New("signed URL has expired")
+	ErrIP         = errors.New("client address is not allowed")
+)
+
+// Grant is what a signed playback URL allows
+type Grant struct {
+	VideoID string     // Video the URL was minted for
+	FileID  string     // GridFS file to stream, so verification needs no lookup
+	Expires time.Time  // End of validity
+	IPRange *net.IPNet // Optional client network restriction
+	Range   *ByteRange // Optional byte range restriction
+}
+
+// ByteRange is an inclusive byte range
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// String formats the range as "start-end"
+func (b ByteRange) String() string {
+	return fmt.Sprintf("%d-%d", b.Start, b.End)
+}
+
+// ParseByteRange parses "start-end"
+func ParseByteRange(value string) (*ByteRange, error) {
+	start, end, ok := strings.Cut(value, "-")
+	s, errS := strconv.ParseInt(start, 10, 64)
+	e, errE := strconv.ParseInt(end, 10, 64)
+	if !ok || errS != nil || errE != nil || s < 0 || e < s {
+		return nil, fmt.Errorf("invalid byte range %q", value)
+	}
+	return &ByteRange{Start: s, End: e}, nil
+}
+
+// Sign returns the query parameters authorising the grant, signed with the given key
+func Sign(grant Grant, keyID string, secret []byte) url.Values {
+	query := url.Values{}
+	query.Set("f", grant.FileID)
+	query.Set("exp", strconv.FormatInt(grant.Expires.Unix(), 10))
+	query.Set("kid", keyID)
+	if grant.IPRange != nil {
+		query.Set("ip", grant.IPRange.String())
+	}
+	if grant.Range != nil {
+		query.Set("range", grant.Range.String())
+	}
+	query.Set("sig", signature(secret, grant.VideoID, query))
+	return query
+}
+
+// Verify checks the signed query parameters of a request for videoID against the known keys.
+// remoteIP is the client address, used when the grant is bound to a network.
+func Verify(query url.Values, videoID string, remoteIP net.IP, keys map[string][]byte, now time.Time) (Grant, error) {
+	var grant Grant
+	sig := query.Get("sig")
+	if sig == "" {
+		return grant, ErrMissing
+	}
+
+	secret, ok := keys[query.Get("kid")]
+	if !ok {
+		return grant, ErrUnknownKey
+	}
+	expected := signature(secret, videoID, query)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return grant, ErrSignature
+	}
+
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil {
+		return grant, ErrMalformed
+	}
+	grant = Grant{VideoID: videoID, FileID: query.Get("f"), Expires: time.Unix(exp, 0)}
+	if now.After(grant.Expires) {
+		return grant, ErrExpired
+	}
+
+	if ip := query.Get("ip"); ip != "" {
+		_, network, err := net.ParseCIDR(ip)
+		if err != nil {
+			return grant, ErrMalformed
+		}
+		if remoteIP == nil || !network.Contains(remoteIP) {
+			return grant, ErrIP
+		}
+		grant.IPRange = network
+	}
+
+	if value := query.Get("range"); value != "" {
+		grant.Range, err = ParseByteRange(value)
+		if err != nil {
+			return grant, ErrMalformed
+		}
+	}
+	return grant, nil
+}
+
+// signature computes the HMAC over the video ID and the signed parameters
+func signature(secret []byte, videoID string, query url.Values) string {
+	payload := strings.Join([]string{
+		"v1",
+		videoID,
+		query.Get("f"),
+		query.Get("exp"),
+		query.Get("kid"),
+		query.Get("ip"),
+		query.Get("range"),
+	}, "\n")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,165 @@
+package signing
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var (
+	oldSecret = []byte("old-secret-0123456789")
+	newSecret = []byte("new-secret-0123456789")
+	now       = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func testGrant() Grant {
+	return Grant{VideoID: "video1", FileID: "file1", Expires: now.Add(time.Hour)}
+}
+
+func TestSignVerify(t *testing.T) {
+	keys := map[string][]byte{"new": newSecret}
+	query := Sign(testGrant(), "new", newSecret)
+
+	grant, err := Verify(query, "video1", nil, keys, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grant.VideoID != "video1" || grant.FileID != "file1" || !grant.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("grant = %+v", grant)
+	}
+	if grant.IPRange != nil || grant.Range != nil {
+		t.Errorf("unrestricted grant came back with restrictions: %+v", grant)
+	}
+
+	// The URL survives being encoded into a link and parsed back
+	parsed, err := url.ParseQuery(query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(parsed, "video1", nil, keys, now); err != nil {
+		t.Errorf("verify after encoding: %v", err)
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	keys := map[string][]byte{"new": newSecret}
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	grant := testGrant()
+	grant.IPRange = network
+	grant.Range = &ByteRange{Start: 0, End: 99}
+
+	tests := []struct {
+		name    string
+		videoID string
+		change  func(url.Values)
+		err     error
+	}{
+		{"other video", "video2", func(url.Values) {}, ErrSignature},
+		{"other file", "video1", func(q url.Values) { q.Set("f", "file2") }, ErrSignature},
+		{"later expiry", "video1", func(q url.Values) { q.Set("exp", "9999999999") }, ErrSignature},
+		{"wider network", "video1", func(q url.Values) { q.Set("ip", "0.0.0.0/0") }, ErrSignature},
+		{"no network", "video1", func(q url.Values) { q.Del("ip") }, ErrSignature},
+		{"wider range", "video1", func(q url.Values) { q.Set("range", "0-999") }, ErrSignature},
+		{"no signature", "video1", func(q url.Values) { q.Del("sig") }, ErrMissing},
+		{"forged signature", "video1", func(q url.Values) { q.Set("sig", "AAAA") }, ErrSignature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := Sign(grant, "new", newSecret)
+			test.change(query)
+			if _, err := Verify(query, test.videoID, net.ParseIP("10.1.2.3"), keys, now); err != test.err {
+				t.Errorf("error = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	signedOld := Sign(testGrant(), "old", oldSecret)
+	signedNew := Sign(testGrant(), "new", newSecret)
+
+	// While the old key is kept, URLs signed with either key work
+	both := map[string][]byte{"old": oldSecret, "new": newSecret}
+	for name, query := range map[string]url.Values{"old": signedOld, "new": signedNew} {
+		if _, err := Verify(query, "video1", nil, both, now); err != nil {
+			t.Errorf("%s key during rotation: %v", name, err)
+		}
+	}
+
+	// Once it is retired, its URLs stop working
+	retired := map[string][]byte{"new": newSecret}
+	if _, err := Verify(signedOld, "video1", nil, retired, now); err != ErrUnknownKey {
+		t.Errorf("retired key: error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	// A key ID pointing at another secret does not verify
+	swapped := map[string][]byte{"old": newSecret, "new": oldSecret}
+	if _, err := Verify(signedNew, "video1", nil, swapped, now); err != ErrSignature {
+		t.Errorf("swapped secrets: error = %v, want %v", err, ErrSignature)
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	keys := map[string][]byte{"new": newSecret}
+	query := Sign(testGrant(), "new", newSecret)
+	expires := now.Add(time.Hour)
+
+	for _, test := range []struct {
+		at  time.Time
+		err error
+	}{
+		{now, nil},
+		{expires, nil},
+		{expires.Add(time.Second), ErrExpired},
+		{expires.Add(24 * time.Hour), ErrExpired},
+	} {
+		if _, err := Verify(query, "video1", nil, keys, test.at); err != test.err {
+			t.Errorf("at %s: error = %v, want %v", test.at, err, test.err)
+		}
+	}
+}
+
+func TestVerifyIPRange(t *testing.T) {
+	keys := map[string][]byte{"new": newSecret}
+	grant := testGrant()
+	_, grant.IPRange, _ = net.ParseCIDR("192.0.2.0/24")
+	query := Sign(grant, "new", newSecret)
+
+	for _, test := range []struct {
+		ip  string
+		err error
+	}{
+		{"192.0.2.10", nil},
+		{"192.0.3.10", ErrIP},
+		{"2001:db8::1", ErrIP},
+		{"", ErrIP},
+	} {
+		if _, err := Verify(query, "video1", net.ParseIP(test.ip), keys, now); err != test.err {
+			t.Errorf("from %q: error = %v, want %v", test.ip, err, test.err)
+		}
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	for value, want := range map[string]*ByteRange{
+		"0-99":    {0, 99},
+		"100-100": {100, 100},
+		"5-4":     nil,
+		"-5":      nil,
+		"5-":      nil,
+		"a-b":     nil,
+		"":        nil,
+	} {
+		got, err := ParseByteRange(value)
+		if want == nil {
+			if err == nil {
+				t.Errorf("ParseByteRange(%q) = %v, want an error", value, got)
+			}
+			continue
+		}
+		if err != nil || *got != *want {
+			t.Errorf("ParseByteRange(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SignPayload returns the signature of a webhook payload sent at the given
+// Unix time, formatted as "sha256=<hex>". The timestamp is covered so a
+// captured request cannot be replayed later with a fresh timestamp.
+func SignPayload(secret []byte, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPayload reports whether signature is the signature of a webhook payload
+func VerifyPayload(secret []byte, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignPayload(secret, timestamp, payload)), []byte(signature))
+}